@@ -0,0 +1,86 @@
+// Command backtest replays the undervaluation scoring model over the stored history and
+// prints the result as JSON. It connects to the same database as the API server, configured
+// by the DB_HOST, DB_PORT, DB_USER, DB_PASSWORD and DB_NAME environment variables; the
+// benchmark defaults to BENCHMARK_SYMBOL.
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"time"
+
+	_ "github.com/lib/pq" // PostgreSQL driver
+
+	"stockpick-backend/pkg/backtest"
+	"stockpick-backend/pkg/database"
+)
+
+func main() {
+	cfg := backtest.DefaultConfig()
+	start := flag.String("start", cfg.Start.Format("2006-01-02"), "first day (YYYY-MM-DD)")
+	end := flag.String("end", cfg.End.Format("2006-01-02"), "last day (YYYY-MM-DD)")
+	flag.StringVar(&cfg.Rebalance, "rebalance", cfg.Rebalance, "rebalance schedule: weekly, monthly, quarterly or annually")
+	flag.StringVar(&cfg.Method, "method", cfg.Method, "portfolio construction: top_n, threshold or sector_neutral")
+	flag.IntVar(&cfg.TopN, "top", cfg.TopN, "holdings of top_n and sector_neutral portfolios")
+	flag.Float64Var(&cfg.Threshold, "threshold", cfg.Threshold, "minimum score of threshold portfolios")
+	flag.Float64Var(&cfg.CostBps, "cost-bps", cfg.CostBps, "transaction cost per unit of traded weight, in basis points")
+	flag.StringVar(&cfg.Mode, "mode", cfg.Mode, "scoring mode: absolute or relative")
+	flag.StringVar(&cfg.GroupBy, "group", cfg.GroupBy, "peer grouping of relative scoring: sector or industry")
+	flag.StringVar(&cfg.Fundamentals, "fundamentals", cfg.Fundamentals, "fundamentals basis: ttm or annual")
+	flag.Float64Var(&cfg.RiskFreeRate, "risk-free", cfg.RiskFreeRate, "annual risk-free rate for the Sharpe ratio")
+	defaultBenchmark := backtest.DefaultBenchmark
+	if env := os.Getenv("BENCHMARK_SYMBOL"); env != "" {
+		defaultBenchmark = env
+	}
+	benchmark := flag.String("benchmark", defaultBenchmark, "benchmark symbol, empty for none")
+	symbols := flag.String("symbols", "", "comma-separated universe, all tracked stocks when empty")
+	equity := flag.Bool("equity", false, "include the daily equity curve and every rebalance in the output")
+	flag.Parse()
+
+	var err error
+	if cfg.Start, err = time.Parse("2006-01-02", *start); err != nil {
+		log.Fatalf("Invalid -start: %v", err)
+	}
+	if cfg.End, err = time.Parse("2006-01-02", *end); err != nil {
+		log.Fatalf("Invalid -end: %v", err)
+	}
+	if err := cfg.Validate(); err != nil {
+		log.Fatalf("Invalid configuration: %v", err)
+	}
+	var universe []string
+	for _, s := range strings.Split(*symbols, ",") {
+		if s = strings.TrimSpace(s); s != "" {
+			universe = append(universe, strings.ToUpper(s))
+		}
+	}
+
+	connStr := fmt.Sprintf("host=%s port=%s user=%s password=%s dbname=%s sslmode=disable",
+		os.Getenv("DB_HOST"), os.Getenv("DB_PORT"), os.Getenv("DB_USER"), os.Getenv("DB_PASSWORD"), os.Getenv("DB_NAME"))
+	db, err := database.NewDB(connStr)
+	if err != nil {
+		log.Fatalf("Error initializing database: %v", err)
+	}
+
+	dataset, err := backtest.Load(db, universe, strings.ToUpper(*benchmark), cfg.Start, cfg.End)
+	if err != nil {
+		log.Fatalf("Error loading backtest data: %v", err)
+	}
+	result, err := backtest.Run(dataset, cfg)
+	if err != nil {
+		log.Fatalf("Error running backtest: %v", err)
+	}
+	if !*equity {
+		result.Equity = nil
+		result.Rebalances = nil
+	}
+
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(result); err != nil {
+		log.Fatalf("Error writing result: %v", err)
+	}
+}
@@ -1,26 +1,55 @@
 package main
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
+	"math"
 	"net/http"
+	"net/url"
 	"os"
+	"sort"
+	"strconv"
+	"strings"
 	"time"
+	_ "time/tzdata" // Time zone data for SEC acceptance times in minimal images
 
+	"github.com/google/uuid"
 	"github.com/gorilla/mux"
 	_ "github.com/lib/pq" // PostgreSQL driver
 
+	"stockpick-backend/pkg/backtest"
+	"stockpick-backend/pkg/calendar"
 	"stockpick-backend/pkg/database"
+	"stockpick-backend/pkg/dataquality"
+	"stockpick-backend/pkg/dcf"
+	"stockpick-backend/pkg/dividends"
+	"stockpick-backend/pkg/estimates"
+	"stockpick-backend/pkg/factors"
 	"stockpick-backend/pkg/fmp"
+	"stockpick-backend/pkg/fundamentals"
+	"stockpick-backend/pkg/graham"
+	"stockpick-backend/pkg/growth"
 	"stockpick-backend/pkg/models"
+	"stockpick-backend/pkg/momentum"
+	"stockpick-backend/pkg/peers"
+	"stockpick-backend/pkg/quality"
+	"stockpick-backend/pkg/ratios"
+	"stockpick-backend/pkg/risk"
+	"stockpick-backend/pkg/screener"
+	"stockpick-backend/pkg/sentiment"
+	"stockpick-backend/pkg/technicals"
 	"stockpick-backend/pkg/undervaluation"
 )
 
 type App struct {
-	Router *mux.Router
-	DB     *database.DB
-	FMP    *fmp.Client
+	Router    *mux.Router
+	DB        *database.DB
+	FMP       *fmp.Client
+	Benchmark string // Symbol risk statistics and backtests are measured against
 }
 
 func (a *App) Initialize(dbHost, dbPort, dbUser, dbPassword, dbName, fmpAPIKey string) {
@@ -31,9 +60,15 @@ func (a *App) Initialize(dbHost, dbPort, dbUser, dbPassword, dbName, fmpAPIKey s
 	if err != nil {
 		log.Fatalf("Error initializing database: %v", err)
 	}
+	if err := db.Migrate(); err != nil {
+		log.Fatalf("Error migrating database: %v", err)
+	}
 	a.DB = db
 
 	a.FMP = fmp.NewClient(fmpAPIKey)
+	if a.Benchmark == "" {
+		a.Benchmark = backtest.DefaultBenchmark
+	}
 	a.Router = mux.NewRouter()
 	a.initializeRoutes()
 }
@@ -46,9 +81,37 @@ func (a *App) Run(addr string) {
 func (a *App) initializeRoutes() {
 	a.Router.HandleFunc("/api/health", a.healthCheckHandler).Methods("GET")
 	a.Router.HandleFunc("/api/ingest/historical-prices/{symbol}", a.ingestHistoricalPricesHandler).Methods("POST")
+	a.Router.HandleFunc("/api/ingest/intraday-prices/{symbol}", a.ingestIntradayPricesHandler).Methods("POST")
+	a.Router.HandleFunc("/api/ingest/financial-statements/{symbol}", a.ingestFinancialStatementsHandler).Methods("POST")
+	a.Router.HandleFunc("/api/ingest/ratios/{symbol}", a.recomputeRatiosHandler).Methods("POST")
+	a.Router.HandleFunc("/api/ingest/analyst-estimates/{symbol}", a.ingestAnalystEstimatesHandler).Methods("POST")
+	a.Router.HandleFunc("/api/ingest/corporate-actions/{symbol}", a.ingestCorporateActionsHandler).Methods("POST")
+	a.Router.HandleFunc("/api/stocks/{symbol}", a.getStockDetailHandler).Methods("GET")
 	a.Router.HandleFunc("/api/stocks/{symbol}/history", a.getHistoricalPricesHandler).Methods("GET")
+	a.Router.HandleFunc("/api/stocks/{symbol}/indicators", a.getIndicatorsHandler).Methods("GET")
+	a.Router.HandleFunc("/api/stocks/{symbol}/risk", a.getRiskHandler).Methods("GET")
+	a.Router.HandleFunc("/api/stocks/{symbol}/ratios", a.getRatiosHandler).Methods("GET")
+	a.Router.HandleFunc("/api/stocks/{symbol}/statements", a.getStatementsHandler).Methods("GET")
+	a.Router.HandleFunc("/api/stocks/{symbol}/peers", a.getPeersHandler).Methods("GET")
+	a.Router.HandleFunc("/api/stocks/{symbol}/dcf", a.getDCFHandler).Methods("GET")
+	a.Router.HandleFunc("/api/stocks/{symbol}/dcf/simulation", a.getDCFSimulationHandler).Methods("GET")
 	a.Router.HandleFunc("/api/stocks", a.getStocksHandler).Methods("GET")
 	a.Router.HandleFunc("/api/undervalued", a.getUndervaluedStocksHandler).Methods("GET")
+	a.Router.HandleFunc("/api/screens/graham", a.getGrahamScreenHandler).Methods("GET")
+	a.Router.HandleFunc("/api/screen", a.runScreenHandler).Methods("POST")
+	a.Router.HandleFunc("/api/data-quality/price-gaps", a.scanPriceGapsHandler).Methods("POST")
+	a.Router.HandleFunc("/api/backfill-jobs", a.getBackfillJobsHandler).Methods("GET")
+	a.Router.HandleFunc("/api/backfill-jobs/run", a.runBackfillJobsHandler).Methods("POST")
+	a.Router.HandleFunc("/api/backtest", a.runBacktestHandler).Methods("POST")
+	a.Router.HandleFunc("/api/factors/analyses", a.getFactorAnalysesHandler).Methods("GET")
+	a.Router.HandleFunc("/api/factors/analyses", a.runFactorAnalysisHandler).Methods("POST")
+	a.Router.HandleFunc("/api/factors/analyses/{id}", a.getFactorAnalysisHandler).Methods("GET")
+	a.Router.HandleFunc("/api/screen/fields", a.getScreenFieldsHandler).Methods("GET")
+	a.Router.HandleFunc("/api/saved-screens", a.getSavedScreensHandler).Methods("GET")
+	a.Router.HandleFunc("/api/saved-screens", a.saveScreenHandler).Methods("POST")
+	a.Router.HandleFunc("/api/saved-screens/{id}", a.getSavedScreenHandler).Methods("GET")
+	a.Router.HandleFunc("/api/saved-screens/{id}", a.deleteSavedScreenHandler).Methods("DELETE")
+	a.Router.HandleFunc("/api/saved-screens/{id}/run", a.runSavedScreenHandler).Methods("GET")
 }
 
 func (a *App) healthCheckHandler(w http.ResponseWriter, r *http.Request) {
@@ -56,6 +119,43 @@ func (a *App) healthCheckHandler(w http.ResponseWriter, r *http.Request) {
 	fmt.Fprintf(w, "API is healthy!")
 }
 
+// getOrCreateStock looks up a stock by symbol, creating it from its FMP company profile
+// when it is not tracked yet.
+func (a *App) getOrCreateStock(symbol string) (*models.Stock, error) {
+	stock, err := a.DB.GetStockBySymbol(symbol)
+	if err != nil {
+		return nil, err
+	}
+	if stock != nil {
+		return stock, nil
+	}
+
+	// Attempt to get company profile to populate stock details
+	profiles, err := a.FMP.GetCompanyProfile(symbol)
+	if err != nil {
+		return nil, fmt.Errorf("could not get company profile for new stock: %w", err)
+	}
+	if len(profiles) == 0 {
+		return nil, fmt.Errorf("no company profile found for new stock")
+	}
+	profile := profiles[0]
+
+	stock = &models.Stock{
+		Symbol:      symbol,
+		CompanyName: profile.CompanyName,
+		Exchange:    profile.Exchange,
+		Sector:      profile.Sector,
+		Industry:    profile.Industry,
+		Currency:    "USD", // FMP usually provides USD for US stocks
+		IsActive:    true,
+	}
+	if err := a.DB.InsertStock(stock); err != nil {
+		return nil, err
+	}
+	log.Printf("Inserted new stock: %s (%s)", stock.CompanyName, stock.Symbol)
+	return stock, nil
+}
+
 func (a *App) ingestHistoricalPricesHandler(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
 	symbol := vars["symbol"]
@@ -78,42 +178,23 @@ func (a *App) ingestHistoricalPricesHandler(w http.ResponseWriter, r *http.Reque
 	}
 
 	// First, get or create the stock in our DB
-	stock, err := a.DB.GetStockBySymbol(symbol)
+	stock, err := a.getOrCreateStock(symbol)
 	if err != nil {
-		log.Printf("Error getting stock by symbol %s: %v", symbol, err)
+		log.Printf("Error getting or creating stock %s: %v", symbol, err)
 		http.Error(w, "Failed to process stock", http.StatusInternalServerError)
 		return
 	}
 
-	if stock == nil {
-		// Attempt to get company profile to populate stock details
-		profiles, err := a.FMP.GetCompanyProfile(symbol)
-		if err != nil || len(profiles) == 0 {
-			log.Printf("Could not get company profile for new stock %s: %v", symbol, err)
-			http.Error(w, "Failed to get company profile for new stock", http.StatusInternalServerError)
-			return
-		}
-		profile := profiles[0]
+	// Insert historical prices into DB
+	a.storeHistoricalPrices(stock, fmpPrices)
 
-		stock = &models.Stock{
-			Symbol:      symbol,
-			CompanyName: profile.CompanyName,
-			Exchange:    profile.Exchange,
-			Sector:      profile.Sector,
-			Industry:    profile.Industry,
-			Currency:    "USD", // FMP usually provides USD for US stocks
-			IsActive:    true,
-		}
-		err = a.DB.InsertStock(stock)
-		if err != nil {
-			log.Printf("Error inserting new stock %s: %v", symbol, err)
-			http.Error(w, "Failed to insert new stock", http.StatusInternalServerError)
-			return
-		}
-		log.Printf("Inserted new stock: %s (%s)", stock.CompanyName, stock.Symbol)
-	}
+	w.WriteHeader(http.StatusOK)
+	fmt.Fprintf(w, "Successfully ingested historical prices for %s", symbol)
+}
 
-	// Insert historical prices into DB
+// storeHistoricalPrices inserts daily bars fetched from FMP and returns how many were stored
+func (a *App) storeHistoricalPrices(stock *models.Stock, fmpPrices []fmp.HistoricalPriceFMP) int {
+	stored := 0
 	for _, p := range fmpPrices {
 		priceTime, err := time.Parse("2006-01-02", p.Date)
 		if err != nil {
@@ -121,29 +202,45 @@ func (a *App) ingestHistoricalPricesHandler(w http.ResponseWriter, r *http.Reque
 			continue
 		}
 		hp := &models.HistoricalPrice{
-			Time:       priceTime,
-			StockID:    stock.StockID,
-			OpenPrice:  p.Open,
-			HighPrice:  p.High,
-			LowPrice:   p.Low,
-			ClosePrice: p.Close,
-			Volume:     p.Volume,
-			VWAP:       p.VWAP,
+			Time:        priceTime,
+			StockID:     stock.StockID,
+			OpenPrice:   p.Open,
+			HighPrice:   p.High,
+			LowPrice:    p.Low,
+			ClosePrice:  p.Close,
+			Volume:      p.Volume,
+			VWAP:        p.VWAP,
 			PriceChange: p.Change,
-			PctChange:  p.PctChange,
+			PctChange:   p.PctChange,
 		}
 		err = a.DB.InsertHistoricalPrice(hp)
 		if err != nil {
-			log.Printf("Error inserting historical price for %s on %s: %v", symbol, p.Date, err)
+			log.Printf("Error inserting historical price for %s on %s: %v", stock.Symbol, p.Date, err)
 			// Continue to next price, don't stop the whole ingestion
+			continue
 		}
+		stored++
 	}
+	return stored
+}
 
-	w.WriteHeader(http.StatusOK)
-	fmt.Fprintf(w, "Successfully ingested historical prices for %s", symbol)
+// dailyInterval is the bar interval of historical_prices
+const dailyInterval = "1d"
+
+// intradayIntervals maps the supported intraday bar intervals to FMP chart intervals
+var intradayIntervals = map[string]string{
+	"1m":  "1min",
+	"5m":  "5min",
+	"15m": "15min",
+	"1h":  "1hour",
 }
 
-func (a *App) getHistoricalPricesHandler(w http.ResponseWriter, r *http.Request) {
+// intradayDefaultDays is the default range of intraday ingestion and history, in days
+const intradayDefaultDays = 5
+
+// ingestIntradayPricesHandler stores the intraday bars of a stock for an interval (1m, 5m,
+// 15m or 1h; 5m by default) between from and to (YYYY-MM-DD, the last five days by default)
+func (a *App) ingestIntradayPricesHandler(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
 	symbol := vars["symbol"]
 	if symbol == "" {
@@ -151,112 +248,2474 @@ func (a *App) getHistoricalPricesHandler(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
-	stock, err := a.DB.GetStockBySymbol(symbol)
+	q := r.URL.Query()
+	interval := q.Get("interval")
+	if interval == "" {
+		interval = "5m"
+	}
+	fmpInterval, ok := intradayIntervals[interval]
+	if !ok {
+		http.Error(w, "interval must be 1m, 5m, 15m or 1h", http.StatusBadRequest)
+		return
+	}
+	from, to, err := dateRangeFromQuery(q, time.Now().AddDate(0, 0, -intradayDefaultDays))
 	if err != nil {
-		log.Printf("Error getting stock by symbol %s: %v", symbol, err)
-		http.Error(w, "Failed to retrieve stock data", http.StatusInternalServerError)
+		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
 	}
 
-	if stock == nil {
-		http.Error(w, "Stock not found", http.StatusNotFound)
+	log.Printf("Ingesting %s intraday prices for %s", interval, symbol)
+
+	bars, err := a.FMP.GetIntradayPrices(symbol, fmpInterval, from, to)
+	if err != nil {
+		log.Printf("Error fetching intraday prices from FMP for %s: %v", symbol, err)
+		http.Error(w, "Failed to fetch intraday prices", http.StatusInternalServerError)
 		return
 	}
 
-	// For simplicity, retrieve data for the last year
-	to := time.Now()
-	from := to.AddDate(-1, 0, 0)
-
-	prices, err := a.DB.GetHistoricalPrices(stock.StockID, from, to)
+	stock, err := a.getOrCreateStock(symbol)
 	if err != nil {
-		log.Printf("Error retrieving historical prices for %s: %v", symbol, err)
-		http.Error(w, "Failed to retrieve historical prices", http.StatusInternalServerError)
+		log.Printf("Error getting or creating stock %s: %v", symbol, err)
+		http.Error(w, "Failed to process stock", http.StatusInternalServerError)
 		return
 	}
 
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(prices)
+	prices := make([]models.HistoricalPrice, 0, len(bars))
+	for _, b := range bars {
+		barTime, err := time.ParseInLocation("2006-01-02 15:04:05", b.Date, easternTime)
+		if err != nil {
+			log.Printf("Error parsing intraday date %s: %v", b.Date, err)
+			continue
+		}
+		prices = append(prices, models.HistoricalPrice{
+			Time:       barTime,
+			StockID:    stock.StockID,
+			OpenPrice:  b.Open,
+			HighPrice:  b.High,
+			LowPrice:   b.Low,
+			ClosePrice: b.Close,
+			Volume:     b.Volume,
+		})
+	}
+	if err := a.DB.InsertIntradayPrices(interval, prices); err != nil {
+		log.Printf("Error inserting intraday prices for %s: %v", symbol, err)
+		http.Error(w, "Failed to store intraday prices", http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	fmt.Fprintf(w, "Successfully ingested %d %s intraday prices for %s", len(prices), interval, symbol)
 }
 
-func (a *App) getStocksHandler(w http.ResponseWriter, r *http.Request) {
-	stocks, err := a.DB.GetAllStocks()
+// ingestCorporateActionsHandler stores the split and dividend history of a stock, which
+// price reads use to adjust the stored prices
+func (a *App) ingestCorporateActionsHandler(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	symbol := vars["symbol"]
+	if symbol == "" {
+		http.Error(w, "Symbol is required", http.StatusBadRequest)
+		return
+	}
+
+	log.Printf("Ingesting splits and dividends for %s", symbol)
+
+	splits, err := a.FMP.GetStockSplits(symbol)
 	if err != nil {
-		log.Printf("Error retrieving all stocks: %v", err)
-		http.Error(w, "Failed to retrieve stocks", http.StatusInternalServerError)
+		log.Printf("Error fetching stock splits from FMP for %s: %v", symbol, err)
+		http.Error(w, "Failed to fetch stock splits", http.StatusInternalServerError)
+		return
+	}
+	dividends, err := a.FMP.GetDividends(symbol)
+	if err != nil {
+		log.Printf("Error fetching dividends from FMP for %s: %v", symbol, err)
+		http.Error(w, "Failed to fetch dividends", http.StatusInternalServerError)
 		return
 	}
 
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(stocks)
+	stock, err := a.getOrCreateStock(symbol)
+	if err != nil {
+		log.Printf("Error getting or creating stock %s: %v", symbol, err)
+		http.Error(w, "Failed to process stock", http.StatusInternalServerError)
+		return
+	}
+
+	splitCount := 0
+	for _, s := range splits {
+		date, err := time.Parse("2006-01-02", s.Date)
+		if err != nil {
+			log.Printf("Error parsing split date %s: %v", s.Date, err)
+			continue
+		}
+		if s.Numerator <= 0 || s.Denominator <= 0 {
+			log.Printf("Ignoring split of %s on %s with ratio %v:%v", symbol, s.Date, s.Numerator, s.Denominator)
+			continue
+		}
+		split := &models.StockSplit{StockID: stock.StockID, Date: date, Numerator: s.Numerator, Denominator: s.Denominator}
+		if err := a.DB.UpsertStockSplit(split); err != nil {
+			log.Printf("Error inserting split for %s on %s: %v", symbol, s.Date, err)
+			continue
+		}
+		splitCount++
+	}
+
+	dividendCount := 0
+	for _, d := range dividends {
+		exDate, err := time.Parse("2006-01-02", d.Date)
+		if err != nil {
+			log.Printf("Error parsing dividend date %s: %v", d.Date, err)
+			continue
+		}
+		if d.Dividend <= 0 && d.AdjDividend <= 0 {
+			continue
+		}
+		dividend := &models.Dividend{
+			StockID:         stock.StockID,
+			ExDate:          exDate,
+			Amount:          d.Dividend,
+			AdjustedAmount:  d.AdjDividend,
+			RecordDate:      optionalDate(d.RecordDate),
+			PaymentDate:     optionalDate(d.PaymentDate),
+			DeclarationDate: optionalDate(d.DeclarationDate),
+		}
+		if dividend.Amount <= 0 {
+			dividend.Amount = d.AdjDividend
+		}
+		if dividend.AdjustedAmount <= 0 {
+			dividend.AdjustedAmount = dividend.Amount
+		}
+		if err := a.DB.UpsertDividend(dividend); err != nil {
+			log.Printf("Error inserting dividend for %s on %s: %v", symbol, d.Date, err)
+			continue
+		}
+		dividendCount++
+	}
+
+	w.WriteHeader(http.StatusOK)
+	fmt.Fprintf(w, "Successfully ingested %d splits and %d dividends for %s", splitCount, dividendCount, symbol)
 }
 
-func (a *App) getUndervaluedStocksHandler(w http.ResponseWriter, r *http.Request) {
-	allStocks, err := a.DB.GetAllStocks()
+// optionalDate parses an optional FMP date, nil when it is empty or malformed
+func optionalDate(raw string) *time.Time {
+	t, err := time.Parse("2006-01-02", raw)
 	if err != nil {
-		log.Printf("Error retrieving all stocks for undervaluation: %v", err)
-		http.Error(w, "Failed to retrieve stocks for undervaluation", http.StatusInternalServerError)
-		return
+		return nil
 	}
+	return &t
+}
 
-	var undervaluedStocks []undervaluation.UndervaluationScore
-	for _, stock := range allStocks {
-		// Fetch latest price (from historical prices)
-		// For simplicity, get the very last closing price
-		prices, err := a.DB.GetHistoricalPrices(stock.StockID, time.Now().AddDate(-1, 0, 0), time.Now())
-		if err != nil || len(prices) == 0 {
-			log.Printf("Could not get latest price for %s: %v", stock.Symbol, err)
-			continue // Skip if no price data
+// statementPeriods maps FMP statement periods to the period names stored in financial_statements
+var statementPeriods = []struct {
+	FMP string
+	DB  string
+}{
+	{FMP: "annual", DB: "annual"},
+	{FMP: "quarter", DB: "quarterly"},
+}
+
+// statementFromFMP converts a merged FMP statement into a financial statement record
+func statementFromFMP(stockID uuid.UUID, s fmp.FinancialStatementFMP, period string) (models.FinancialStatement, error) {
+	date, err := time.Parse("2006-01-02", s.Date)
+	if err != nil {
+		return models.FinancialStatement{}, fmt.Errorf("error parsing date %s: %w", s.Date, err)
+	}
+	totalDebt := s.TotalDebt
+	if totalDebt == 0 {
+		totalDebt = s.Debt
+	}
+	filingDate, acceptedDate := filingTimes(s)
+	return models.FinancialStatement{
+		StockID:            stockID,
+		Date:               date,
+		Period:             period,
+		FilingDate:         filingDate,
+		AcceptedDate:       acceptedDate,
+		Revenue:            s.Revenue,
+		NetIncome:          s.NetIncome,
+		EPS:                s.EPS,
+		TotalAssets:        s.TotalAssets,
+		TotalLiabilities:   s.TotalLiabilities,
+		TotalEquity:        s.TotalEquity,
+		FreeCashFlow:       s.FreeCashFlow,
+		GrossProfit:        s.GrossProfit,
+		OperatingIncome:    s.OperatingIncome,
+		EBITDA:             s.EBITDA,
+		IncomeBeforeTax:    s.IncomeBeforeTax,
+		IncomeTaxExpense:   s.IncomeTaxExpense,
+		TotalDebt:          totalDebt,
+		CashAndEquivalents: s.CashAndCashEquivalents,
+		SharesOutstanding:  s.WeightedAverageShsOut,
+		CurrentAssets:      s.TotalCurrentAssets,
+		CurrentLiabilities: s.TotalCurrentLiabilities,
+		LongTermDebt:       s.LongTermDebt,
+		RetainedEarnings:   s.RetainedEarnings,
+		OperatingCashFlow:  s.OperatingCashFlow,
+	}, nil
+}
+
+// easternTime is the US Eastern time zone, in which FMP reports SEC acceptance times and
+// intraday bar times
+var easternTime = func() *time.Location {
+	loc, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		log.Printf("Could not load US Eastern time zone, using UTC: %v", err)
+		return time.UTC
+	}
+	return loc
+}()
+
+// filingTimes parses the filing date and acceptance time of an FMP statement. Missing or
+// malformed values are returned as nil so the statement falls back to the reporting lag.
+func filingTimes(s fmp.FinancialStatementFMP) (*time.Time, *time.Time) {
+	var filingDate, acceptedDate *time.Time
+	if s.FillingDate != "" {
+		if t, err := time.Parse("2006-01-02", s.FillingDate); err == nil {
+			filingDate = &t
+		} else {
+			log.Printf("Ignoring malformed filing date %q for %s: %v", s.FillingDate, s.Date, err)
+		}
+	}
+	if s.AcceptedDate != "" {
+		if t, err := time.ParseInLocation("2006-01-02 15:04:05", s.AcceptedDate, easternTime); err == nil {
+			acceptedDate = &t
+		} else {
+			log.Printf("Ignoring malformed accepted date %q for %s: %v", s.AcceptedDate, s.Date, err)
 		}
-		latestPrice := prices[len(prices)-1].ClosePrice
+	}
+	return filingDate, acceptedDate
+}
+
+// applyStatementRatios computes a statement's ratios at the closing price on its period end date
+func (a *App) applyStatementRatios(fs *models.FinancialStatement) {
+	price, err := a.DB.GetClosePriceOnOrBefore(fs.StockID, fs.Date)
+	if err != nil {
+		log.Printf("Could not get price on %s for ratios: %v", fs.Date.Format("2006-01-02"), err)
+	}
+	ratios.Compute(*fs, price).ApplyTo(fs)
+}
+
+func (a *App) ingestFinancialStatementsHandler(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	symbol := vars["symbol"]
+	if symbol == "" {
+		http.Error(w, "Symbol is required", http.StatusBadRequest)
+		return
+	}
+
+	log.Printf("Ingesting financial statements for %s", symbol)
+
+	stock, err := a.getOrCreateStock(symbol)
+	if err != nil {
+		log.Printf("Error getting or creating stock %s: %v", symbol, err)
+		http.Error(w, "Failed to process stock", http.StatusInternalServerError)
+		return
+	}
 
-		// Fetch latest financial statements (annual)
-		financialStatements, err := a.DB.GetFinancialStatements(stock.StockID, "annual")
+	inserted := 0
+	for _, period := range statementPeriods {
+		statements, err := a.FMP.GetFullFinancialStatements(symbol, period.FMP)
 		if err != nil {
-			log.Printf("Could not get financial statements for %s: %v", stock.Symbol, err)
-			financialStatements = []models.FinancialStatement{} // Ensure it's not nil for calculator
+			log.Printf("Error fetching %s financial statements from FMP for %s: %v", period.FMP, symbol, err)
+			http.Error(w, "Failed to fetch financial statements", http.StatusInternalServerError)
+			return
+		}
+
+		for _, s := range statements {
+			fs, err := statementFromFMP(stock.StockID, s, period.DB)
+			if err != nil {
+				log.Printf("Error converting financial statement for %s: %v", symbol, err)
+				continue
+			}
+			a.applyStatementRatios(&fs)
+			if err := a.DB.InsertFinancialStatement(&fs); err != nil {
+				log.Printf("Error inserting financial statement for %s on %s: %v", symbol, s.Date, err)
+				continue
+			}
+			inserted++
 		}
+	}
+
+	w.WriteHeader(http.StatusOK)
+	fmt.Fprintf(w, "Successfully ingested %d financial statements for %s", inserted, symbol)
+}
+
+// ingestAnalystEstimatesHandler stores today's snapshot of the annual and quarterly consensus
+// estimates. Snapshots are kept so that estimate revisions can be tracked over time.
+func (a *App) ingestAnalystEstimatesHandler(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	symbol := vars["symbol"]
+	if symbol == "" {
+		http.Error(w, "Symbol is required", http.StatusBadRequest)
+		return
+	}
+
+	log.Printf("Ingesting analyst estimates for %s", symbol)
+
+	stock, err := a.getOrCreateStock(symbol)
+	if err != nil {
+		log.Printf("Error getting or creating stock %s: %v", symbol, err)
+		http.Error(w, "Failed to process stock", http.StatusInternalServerError)
+		return
+	}
 
-		// Fetch latest analyst targets
-		analystTargets, err := a.DB.GetAnalystTargets(stock.StockID)
+	asOf := time.Now().UTC().Truncate(24 * time.Hour)
+	inserted := 0
+	for _, period := range statementPeriods {
+		fmpEstimates, err := a.FMP.GetAnalystEstimates(symbol, period.FMP)
 		if err != nil {
-			log.Printf("Could not get analyst targets for %s: %v", stock.Symbol, err)
-			analystTargets = []models.AnalystTarget{} // Ensure it's not nil
+			log.Printf("Error fetching %s analyst estimates from FMP for %s: %v", period.FMP, symbol, err)
+			http.Error(w, "Failed to fetch analyst estimates", http.StatusInternalServerError)
+			return
+		}
+
+		for _, e := range fmpEstimates {
+			fiscalDate, err := time.Parse("2006-01-02", e.Date)
+			if err != nil {
+				log.Printf("Error parsing date %s for analyst estimate: %v", e.Date, err)
+				continue
+			}
+			estimate := &models.AnalystEstimate{
+				StockID:         stock.StockID,
+				FiscalDate:      fiscalDate,
+				Period:          period.DB,
+				AsOf:            asOf,
+				RevenueLow:      e.EstimatedRevenueLow,
+				RevenueAvg:      e.EstimatedRevenueAvg,
+				RevenueHigh:     e.EstimatedRevenueHigh,
+				EBITDAAvg:       e.EstimatedEbitdaAvg,
+				NetIncomeAvg:    e.EstimatedNetIncomeAvg,
+				EPSLow:          e.EstimatedEpsLow,
+				EPSAvg:          e.EstimatedEpsAvg,
+				EPSHigh:         e.EstimatedEpsHigh,
+				RevenueAnalysts: e.NumberAnalystEstimatedRevenue,
+				EPSAnalysts:     e.NumberAnalystsEstimatedEps,
+			}
+			if err := a.DB.InsertAnalystEstimate(estimate); err != nil {
+				log.Printf("Error inserting analyst estimate for %s on %s: %v", symbol, e.Date, err)
+				continue
+			}
+			inserted++
 		}
+	}
+
+	w.WriteHeader(http.StatusOK)
+	fmt.Fprintf(w, "Successfully ingested %d analyst estimates for %s", inserted, symbol)
+}
+
+// recomputeRatiosHandler refreshes the stored ratios of every statement, e.g. after
+// historical prices covering older statement dates have been ingested.
+func (a *App) recomputeRatiosHandler(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	symbol := vars["symbol"]
+	if symbol == "" {
+		http.Error(w, "Symbol is required", http.StatusBadRequest)
+		return
+	}
+
+	stock, err := a.DB.GetStockBySymbol(symbol)
+	if err != nil {
+		log.Printf("Error getting stock by symbol %s: %v", symbol, err)
+		http.Error(w, "Failed to retrieve stock data", http.StatusInternalServerError)
+		return
+	}
+	if stock == nil {
+		http.Error(w, "Stock not found", http.StatusNotFound)
+		return
+	}
 
-		// Fetch latest sentiment scores (e.g., overall source)
-		sentimentScores, err := a.DB.GetSentimentScores(stock.StockID, time.Now().AddDate(0, 0, -7), time.Now(), "Overall")
+	updated := 0
+	for _, period := range statementPeriods {
+		statements, err := a.DB.GetFinancialStatements(stock.StockID, period.DB)
 		if err != nil {
-			log.Printf("Could not get sentiment scores for %s: %v", stock.Symbol, err)
-			sentimentScores = []models.SentimentScore{} // Ensure it's not nil
+			log.Printf("Error retrieving %s financial statements for %s: %v", period.DB, symbol, err)
+			http.Error(w, "Failed to retrieve financial statements", http.StatusInternalServerError)
+			return
 		}
+		for i := range statements {
+			a.applyStatementRatios(&statements[i])
+			if err := a.DB.UpdateFinancialStatementRatios(&statements[i]); err != nil {
+				log.Printf("Error updating ratios for %s: %v", symbol, err)
+				continue
+			}
+			updated++
+		}
+	}
+
+	w.WriteHeader(http.StatusOK)
+	fmt.Fprintf(w, "Successfully recomputed ratios for %d financial statements of %s", updated, symbol)
+}
+
+// statementRatios pairs a statement's period with the ratios at its period end price
+type statementRatios struct {
+	Date   time.Time     `json:"date"`
+	Period string        `json:"period"`
+	Ratios ratios.Ratios `json:"ratios"`
+}
+
+func (a *App) getRatiosHandler(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	symbol := vars["symbol"]
+	if symbol == "" {
+		http.Error(w, "Symbol is required", http.StatusBadRequest)
+		return
+	}
+
+	period := r.URL.Query().Get("period")
+	if period == "" {
+		period = "annual"
+	}
+
+	stock, err := a.DB.GetStockBySymbol(symbol)
+	if err != nil {
+		log.Printf("Error getting stock by symbol %s: %v", symbol, err)
+		http.Error(w, "Failed to retrieve stock data", http.StatusInternalServerError)
+		return
+	}
+	if stock == nil {
+		http.Error(w, "Stock not found", http.StatusNotFound)
+		return
+	}
+
+	statements, err := a.DB.GetFinancialStatements(stock.StockID, period)
+	if err != nil {
+		log.Printf("Error retrieving financial statements for %s: %v", symbol, err)
+		http.Error(w, "Failed to retrieve financial statements", http.StatusInternalServerError)
+		return
+	}
+
+	response := struct {
+		Symbol  string            `json:"symbol"`
+		Current *ratios.Ratios    `json:"current"`
+		History []statementRatios `json:"history"`
+	}{Symbol: stock.Symbol, History: []statementRatios{}}
 
-		score, err := undervaluation.CalculateUndervaluation(&stock, latestPrice, financialStatements, analystTargets, sentimentScores)
+	for _, fs := range statements {
+		price, err := a.DB.GetClosePriceOnOrBefore(stock.StockID, fs.Date)
 		if err != nil {
-			log.Printf("Error calculating undervaluation for %s: %v", stock.Symbol, err)
-			continue
+			log.Printf("Could not get price on %s for %s: %v", fs.Date.Format("2006-01-02"), symbol, err)
 		}
+		response.History = append(response.History, statementRatios{
+			Date:   fs.Date,
+			Period: fs.Period,
+			Ratios: ratios.Compute(fs, price),
+		})
+	}
 
-		// Define a threshold for "undervalued"
-		if score.Score >= 50 { // Example threshold
-			undervaluedStocks = append(undervaluedStocks, *score)
+	if len(statements) > 0 {
+		latestPrice, err := a.DB.GetClosePriceOnOrBefore(stock.StockID, time.Now())
+		if err != nil {
+			log.Printf("Could not get latest price for %s: %v", symbol, err)
 		}
+		current := ratios.Compute(statements[0], latestPrice)
+		response.Current = &current
 	}
 
 	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(undervaluedStocks)
+	json.NewEncoder(w).Encode(response)
 }
 
+// getHistoricalPricesHandler returns the prices of a stock between from and to (YYYY-MM-DD).
+// interval is 1d (default), 1w or 1mo, which default to the last year, or an intraday
+// interval (1m, 5m, 15m or 1h, the last five days by default). Weekly and monthly bars as
+// traded come from the continuous aggregates, adjusted ones are built from adjusted daily
+// bars; intraday bars are returned as traded.
+func (a *App) getHistoricalPricesHandler(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	symbol := vars["symbol"]
+	if symbol == "" {
+		http.Error(w, "Symbol is required", http.StatusBadRequest)
+		return
+	}
 
-func main() {
-	app := App{}
-	app.Initialize(
-		os.Getenv("DB_HOST"),
-		os.Getenv("DB_PORT"),
-		os.Getenv("DB_USER"),
-		os.Getenv("DB_PASSWORD"),
-		os.Getenv("DB_NAME"),
-		os.Getenv("FMP_API_KEY"),
-	)
+	q := r.URL.Query()
+	interval := q.Get("interval")
+	if interval == "" {
+		interval = dailyInterval
+	}
+	_, intraday := intradayIntervals[interval]
+	aggregated := interval == database.WeeklyInterval || interval == database.MonthlyInterval
+	if interval != dailyInterval && !intraday && !aggregated {
+		http.Error(w, "interval must be 1d, 1w, 1mo, 1m, 5m, 15m or 1h", http.StatusBadRequest)
+		return
+	}
+	defaultFrom := time.Now().AddDate(-1, 0, 0)
+	if intraday {
+		defaultFrom = time.Now().AddDate(0, 0, -intradayDefaultDays)
+	}
+	from, to, err := dateRangeFromQuery(q, defaultFrom)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	stock, err := a.DB.GetStockBySymbol(symbol)
+	if err != nil {
+		log.Printf("Error getting stock by symbol %s: %v", symbol, err)
+		http.Error(w, "Failed to retrieve stock data", http.StatusInternalServerError)
+		return
+	}
+
+	if stock == nil {
+		http.Error(w, "Stock not found", http.StatusNotFound)
+		return
+	}
+
+	// Split- and dividend-adjusted by default; adjusted=false returns prices as traded
+	adjusted := q.Get("adjusted") != "false"
+	var prices []models.HistoricalPrice
+	switch {
+	case intraday:
+		prices, err = a.DB.GetIntradayPrices(stock.StockID, interval, from, to)
+	case aggregated && adjusted:
+		prices, err = a.DB.GetAdjustedAggregatedPrices(stock.StockID, interval, from, to, true)
+	case aggregated:
+		prices, err = a.DB.GetAggregatedPrices(stock.StockID, interval, from, to)
+	case adjusted:
+		prices, err = a.DB.GetAdjustedHistoricalPrices(stock.StockID, from, to, true)
+	default:
+		prices, err = a.DB.GetHistoricalPrices(stock.StockID, from, to)
+	}
+	if err != nil {
+		log.Printf("Error retrieving historical prices for %s: %v", symbol, err)
+		http.Error(w, "Failed to retrieve historical prices", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(prices)
+}
+
+func (a *App) getStocksHandler(w http.ResponseWriter, r *http.Request) {
+	stocks, err := a.DB.GetAllStocks()
+	if err != nil {
+		log.Printf("Error retrieving all stocks: %v", err)
+		http.Error(w, "Failed to retrieve stocks", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(stocks)
+}
+
+// errNoPriceData is returned by scoringInputs for stocks without a recent close
+var errNoPriceData = errors.New("no price data")
+
+// scoringInputs loads everything the undervaluation calculator scores a stock on.
+// statementPeriod selects the fundamentals basis; "ttm" falls back to annual figures
+// when fewer than four consecutive quarters are stored.
+// benchmark holds the benchmark's adjusted prices over the last year (see scoringBenchmark).
+func (a *App) scoringInputs(stock models.Stock, statementPeriod string, benchmark []models.HistoricalPrice) (*undervaluation.Inputs, error) {
+	// Fetch latest price (from historical prices)
+	// For simplicity, get the very last closing price. Adjusted prices keep the latest close
+	// as traded while making returns over the year comparable across splits and dividends.
+	prices, err := a.DB.GetAdjustedHistoricalPrices(stock.StockID, time.Now().AddDate(-1, 0, 0), time.Now(), true)
+	if err != nil {
+		return nil, err
+	}
+	if len(prices) == 0 {
+		return nil, errNoPriceData
+	}
+	latestPrice := prices[len(prices)-1].ClosePrice
+	latestPriceTime := prices[len(prices)-1].Time
+
+	// Annual statements back the growth metrics whatever the scoring basis
+	annualStatements, err := a.DB.GetFinancialStatements(stock.StockID, "annual")
+	if err != nil {
+		log.Printf("Could not get annual financial statements for %s: %v", stock.Symbol, err)
+		annualStatements = []models.FinancialStatement{}
+	}
+
+	// Fetch latest financial statements
+	financialStatements := annualStatements
+	if statementPeriod != "annual" {
+		financialStatements, err = a.DB.GetFinancialStatements(stock.StockID, statementPeriod)
+		if err == nil && len(financialStatements) == 0 && statementPeriod == fundamentals.TTMPeriod {
+			// Fewer than four consecutive quarters stored, fall back to annual figures
+			financialStatements = annualStatements
+		}
+		if err != nil {
+			log.Printf("Could not get financial statements for %s: %v", stock.Symbol, err)
+			financialStatements = []models.FinancialStatement{} // Ensure it's not nil for calculator
+		}
+	}
+
+	currentPE := 0.0
+	if len(financialStatements) > 0 {
+		// Score on ratios at the current price rather than at the statement date.
+		// Copy first so the annual history keeps its statement-date ratios.
+		financialStatements = append([]models.FinancialStatement(nil), financialStatements...)
+		ratios.Compute(financialStatements[0], latestPrice).ApplyTo(&financialStatements[0])
+		currentPE = financialStatements[0].PERatio
+	}
+	growthMetrics := growth.Compute(annualStatements, currentPE)
+	qualityScores := quality.Compute(annualStatements, latestPrice)
+
+	// Forward metrics from the annual consensus estimates
+	var forward *estimates.Metrics
+	estimateSnapshots, err := a.DB.GetAnalystEstimates(stock.StockID, "annual")
+	if err != nil {
+		log.Printf("Could not get analyst estimates for %s: %v", stock.Symbol, err)
+	} else if m, ok := estimates.Compute(estimateSnapshots, annualStatements, latestPrice, time.Now()); ok {
+		forward = &m
+	}
+
+	// Payout ratios against the scoring basis, or annual figures when scoring on a single quarter
+	payoutBasis := financialStatements
+	if statementPeriod == "quarterly" {
+		payoutBasis = annualStatements
+	}
+	dividendMetrics := a.dividendMetrics(stock, payoutBasis, latestPrice)
+
+	// Benchmark prices for beta, matched by trading day
+	if stock.Symbol == a.Benchmark {
+		benchmark = nil
+	}
+
+	// Fetch latest analyst targets
+	analystTargets, err := a.DB.GetAnalystTargets(stock.StockID)
+	if err != nil {
+		log.Printf("Could not get analyst targets for %s: %v", stock.Symbol, err)
+		analystTargets = []models.AnalystTarget{} // Ensure it's not nil
+	}
+
+	// Fetch recent sentiment scores of every source; the calculator time-weights and blends them.
+	// Two weeks leaves an earlier baseline for sentiment momentum.
+	sentimentScores, err := a.DB.GetSentimentScores(stock.StockID, time.Now().AddDate(0, 0, -14), time.Now(), "")
+	if err != nil {
+		log.Printf("Could not get sentiment scores for %s: %v", stock.Symbol, err)
+		sentimentScores = []models.SentimentScore{} // Ensure it's not nil
+	}
+
+	return &undervaluation.Inputs{
+		Stock:               &stock,
+		LatestPrice:         latestPrice,
+		LatestPriceTime:     latestPriceTime,
+		Prices:              prices,
+		Benchmark:           benchmark,
+		FinancialStatements: financialStatements,
+		AnalystTargets:      analystTargets,
+		SentimentScores:     sentimentScores,
+		Growth:              &growthMetrics,
+		Quality:             &qualityScores,
+		Estimates:           forward,
+		Dividends:           dividendMetrics,
+	}, nil
+}
+
+// scoringBenchmark loads the benchmark prices of the last year once for all the stocks a
+// request scores, nil when the benchmark is not tracked
+func (a *App) scoringBenchmark() []models.HistoricalPrice {
+	benchmark, err := a.benchmarkPrices(time.Now().AddDate(-1, 0, 0), time.Now())
+	if err != nil {
+		log.Printf("Could not get benchmark prices for %s: %v", a.Benchmark, err)
+	}
+	return benchmark
+}
+
+// dividendMetrics computes the dividend metrics of a stock from its stored dividend history,
+// nil when it has none
+func (a *App) dividendMetrics(stock models.Stock, statements []models.FinancialStatement, price float64) *dividends.Metrics {
+	history, err := a.DB.GetDividends(stock.StockID)
+	if err != nil {
+		log.Printf("Could not get dividends for %s: %v", stock.Symbol, err)
+		return nil
+	}
+	m, ok := dividends.Compute(history, statements, price, time.Now())
+	if !ok {
+		return nil
+	}
+	return &m
+}
+
+func (a *App) getUndervaluedStocksHandler(w http.ResponseWriter, r *http.Request) {
+	allStocks, err := a.DB.GetAllStocks()
+	if err != nil {
+		log.Printf("Error retrieving all stocks for undervaluation: %v", err)
+		http.Error(w, "Failed to retrieve stocks for undervaluation", http.StatusInternalServerError)
+		return
+	}
+
+	// Score on trailing-twelve-month fundamentals by default; ?period=annual uses the last fiscal year
+	statementPeriod := r.URL.Query().Get("period")
+	if statementPeriod == "" {
+		statementPeriod = fundamentals.TTMPeriod
+	}
+
+	// Optional quality filters; stocks whose score cannot be computed are excluded
+	q := r.URL.Query()
+	minFScore, err := floatParam(q, "min_fscore", 0)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	minZScore, err := floatParam(q, "min_zscore", 0)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	// min_confidence drops stocks scored on too little or too stale data
+	minConfidence, err := floatParam(q, "min_confidence", 0)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	sentimentConfig, err := sentimentConfigFromQuery(q)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	// mode=relative ranks metrics within each stock's sector (or group=industry)
+	// instead of scoring them against absolute thresholds
+	mode := q.Get("mode")
+	if mode != "" && mode != "absolute" && mode != "relative" {
+		http.Error(w, "mode must be absolute or relative", http.StatusBadRequest)
+		return
+	}
+	groupBy := q.Get("group")
+	if groupBy == "" {
+		groupBy = undervaluation.GroupSector
+	}
+	if groupBy != undervaluation.GroupSector && groupBy != undervaluation.GroupIndustry {
+		http.Error(w, "group must be sector or industry", http.StatusBadRequest)
+		return
+	}
+
+	// trend_filter=off|penalize|exclude adds a momentum overlay against falling knives
+	trendConfig, err := trendConfigFromQuery(q)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	withTrend := q.Get("trend_filter") != ""
+	// drawdown_penalty=true scales fundamental scores down for deep drawdowns
+	drawdownPenalty := q.Get("drawdown_penalty") == "true"
+
+	var universe []undervaluation.Inputs
+	inputsByStock := make(map[string]undervaluation.Inputs)
+	benchmark := a.scoringBenchmark()
+	for _, stock := range allStocks {
+		inputs, err := a.scoringInputs(stock, statementPeriod, benchmark)
+		if err != nil {
+			log.Printf("Could not build scoring inputs for %s: %v", stock.Symbol, err)
+			continue // Skip stocks without price data or whose data failed to load
+		}
+		inputs.SentimentConfig = &sentimentConfig
+		inputs.DrawdownPenalty = drawdownPenalty
+		universe = append(universe, *inputs)
+		inputsByStock[stock.StockID.String()] = *inputs
+	}
+
+	var scores []undervaluation.UndervaluationScore
+	if mode == "relative" {
+		relative, err := undervaluation.CalculateRelative(universe, groupBy)
+		if err != nil {
+			log.Printf("Error calculating relative undervaluation: %v", err)
+			http.Error(w, "Failed to calculate undervaluation", http.StatusInternalServerError)
+			return
+		}
+		for i, score := range relative {
+			if score == nil {
+				log.Printf("Skipping %s in the relative ranking: latest price is missing", universe[i].Stock.Symbol)
+				continue
+			}
+			scores = append(scores, *score)
+		}
+	} else {
+		for _, inputs := range universe {
+			score, err := undervaluation.Calculate(inputs)
+			if err != nil {
+				log.Printf("Error calculating undervaluation for %s: %v", inputs.Stock.Symbol, err)
+				continue
+			}
+			scores = append(scores, *score)
+		}
+	}
+
+	var undervaluedStocks []undervaluation.UndervaluationScore
+	for i := range scores {
+		score := &scores[i]
+		if q.Get("min_fscore") != "" && (score.FScore == nil || float64(*score.FScore) < minFScore) {
+			continue
+		}
+		if q.Get("min_zscore") != "" && (score.ZScore == nil || *score.ZScore < minZScore) {
+			continue
+		}
+		if score.Confidence < minConfidence {
+			continue
+		}
+		if withTrend && !undervaluation.ApplyTrend(score, inputsByStock[score.StockID], trendConfig) {
+			continue
+		}
+
+		// Define a threshold for "undervalued"
+		if score.Score >= 50 { // Example threshold
+			undervaluedStocks = append(undervaluedStocks, *score)
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(undervaluedStocks)
+}
+
+// stockDetail is the response of the stock detail endpoint
+type stockDetail struct {
+	Stock          models.Stock                        `json:"stock"`
+	LatestPrice    float64                             `json:"latest_price"`
+	Ratios         *ratios.Ratios                      `json:"ratios"`
+	Growth         *growth.Metrics                     `json:"growth"`
+	Quality        *quality.Scores                     `json:"quality"`
+	Estimates      *estimates.Metrics                  `json:"estimates"`
+	Graham         *graham.Metrics                     `json:"graham"`
+	Undervaluation *undervaluation.UndervaluationScore `json:"undervaluation"`
+}
+
+func (a *App) getStockDetailHandler(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	symbol := vars["symbol"]
+	if symbol == "" {
+		http.Error(w, "Symbol is required", http.StatusBadRequest)
+		return
+	}
+
+	stock, err := a.DB.GetStockBySymbol(symbol)
+	if err != nil {
+		log.Printf("Error getting stock by symbol %s: %v", symbol, err)
+		http.Error(w, "Failed to retrieve stock data", http.StatusInternalServerError)
+		return
+	}
+	if stock == nil {
+		http.Error(w, "Stock not found", http.StatusNotFound)
+		return
+	}
+
+	sentimentConfig, err := sentimentConfigFromQuery(r.URL.Query())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	detail := stockDetail{Stock: *stock}
+
+	inputs, err := a.scoringInputs(*stock, fundamentals.TTMPeriod, a.scoringBenchmark())
+	if err != nil && err != errNoPriceData {
+		log.Printf("Error loading scoring inputs for %s: %v", symbol, err)
+		http.Error(w, "Failed to retrieve stock data", http.StatusInternalServerError)
+		return
+	}
+	if inputs != nil {
+		inputs.SentimentConfig = &sentimentConfig
+		annual, err := a.DB.GetFinancialStatements(stock.StockID, "annual")
+		if err != nil {
+			log.Printf("Could not get annual financial statements for %s: %v", symbol, err)
+		}
+		grahamMetrics := graham.Compute(annual, inputs.LatestPrice, inputs.Dividends)
+		detail.Graham = &grahamMetrics
+
+		detail.LatestPrice = inputs.LatestPrice
+		detail.Growth = inputs.Growth
+		detail.Quality = inputs.Quality
+		detail.Estimates = inputs.Estimates
+		if len(inputs.FinancialStatements) > 0 {
+			current := ratios.Compute(inputs.FinancialStatements[0], inputs.LatestPrice)
+			detail.Ratios = &current
+		}
+		score, err := undervaluation.Calculate(*inputs)
+		if err != nil {
+			log.Printf("Error calculating undervaluation for %s: %v", symbol, err)
+		}
+		detail.Undervaluation = score
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(detail)
+}
+
+// sentimentConfigFromQuery overrides the default sentiment aggregation with query parameters:
+// sentiment_half_life (a duration such as 36h), sentiment_weights (Reddit:0.5,Twitter:0.3,...)
+// and sentiment_abnormal_volume (RelativeIndex threshold).
+func sentimentConfigFromQuery(q url.Values) (sentiment.Config, error) {
+	cfg := sentiment.DefaultConfig()
+	if raw := q.Get("sentiment_half_life"); raw != "" {
+		halfLife, err := time.ParseDuration(raw)
+		if err != nil || halfLife <= 0 {
+			return cfg, fmt.Errorf("invalid sentiment_half_life: %q", raw)
+		}
+		cfg.HalfLife = halfLife
+	}
+	if raw := q.Get("sentiment_weights"); raw != "" {
+		weights, err := sentiment.ParseSourceWeights(raw)
+		if err != nil {
+			return cfg, err
+		}
+		cfg.SourceWeights = weights
+	}
+	abnormal, err := floatParam(q, "sentiment_abnormal_volume", cfg.AbnormalVolume)
+	if err != nil {
+		return cfg, err
+	}
+	cfg.AbnormalVolume = abnormal
+	return cfg, nil
+}
+
+// trendConfigFromQuery reads the momentum overlay of the undervalued ranking, e.g.
+// trend_filter=penalize&trend_lookbacks=3,6,12&trend_threshold=-0.2&trend_penalty=0.3
+func trendConfigFromQuery(q url.Values) (momentum.Config, error) {
+	cfg := momentum.DefaultConfig()
+	if raw := q.Get("trend_filter"); raw != "" {
+		cfg.Filter = raw
+	}
+	lookbacks, err := floatListParam(q, "trend_lookbacks")
+	if err != nil {
+		return cfg, err
+	}
+	if len(lookbacks) > 0 {
+		cfg.Lookbacks = nil
+		for _, l := range lookbacks {
+			if l != float64(int(l)) {
+				return cfg, fmt.Errorf("invalid trend_lookbacks: %q", q.Get("trend_lookbacks"))
+			}
+			cfg.Lookbacks = append(cfg.Lookbacks, int(l))
+		}
+	}
+	if cfg.Threshold, err = floatParam(q, "trend_threshold", cfg.Threshold); err != nil {
+		return cfg, err
+	}
+	if cfg.Penalty, err = floatParam(q, "trend_penalty", cfg.Penalty); err != nil {
+		return cfg, err
+	}
+	return cfg, cfg.Validate()
+}
+
+// floatParam parses an optional finite float query parameter, returning def when it is absent
+func floatParam(q url.Values, name string, def float64) (float64, error) {
+	raw := q.Get(name)
+	if raw == "" {
+		return def, nil
+	}
+	v, err := strconv.ParseFloat(raw, 64)
+	if err != nil || math.IsNaN(v) || math.IsInf(v, 0) {
+		return 0, fmt.Errorf("invalid %s: %q", name, raw)
+	}
+	return v, nil
+}
+
+// floatListParam parses an optional comma-separated list of finite floats
+func floatListParam(q url.Values, name string) ([]float64, error) {
+	raw := q.Get(name)
+	if raw == "" {
+		return nil, nil
+	}
+	var values []float64
+	for _, part := range strings.Split(raw, ",") {
+		v, err := strconv.ParseFloat(strings.TrimSpace(part), 64)
+		if err != nil || math.IsNaN(v) || math.IsInf(v, 0) {
+			return nil, fmt.Errorf("invalid %s: %q", name, raw)
+		}
+		values = append(values, v)
+	}
+	return values, nil
+}
+
+// dateRangeFromQuery parses the optional from and to query parameters (YYYY-MM-DD, to
+// inclusive), defaulting to defaultFrom and now
+func dateRangeFromQuery(q url.Values, defaultFrom time.Time) (time.Time, time.Time, error) {
+	to := time.Now()
+	from := defaultFrom
+	for _, d := range []struct {
+		name     string
+		value    *time.Time
+		endOfDay bool
+	}{{"from", &from, false}, {"to", &to, true}} {
+		raw := q.Get(d.name)
+		if raw == "" {
+			continue
+		}
+		t, err := time.Parse("2006-01-02", raw)
+		if err != nil {
+			return from, to, fmt.Errorf("invalid %s: %q, expected YYYY-MM-DD", d.name, raw)
+		}
+		if d.endOfDay {
+			t = t.AddDate(0, 0, 1).Add(-time.Nanosecond)
+		}
+		*d.value = t
+	}
+	if !to.After(from) {
+		return from, to, fmt.Errorf("to must be after from")
+	}
+	return from, to, nil
+}
+
+// dcfAssumptionsFromQuery overrides default DCF assumptions with query parameters.
+// Stages are given as parallel lists, e.g. growth=0.12,0.06&years=5,5.
+func dcfAssumptionsFromQuery(q url.Values, assumptions dcf.Assumptions) (dcf.Assumptions, error) {
+	growthRates, err := floatListParam(q, "growth")
+	if err != nil {
+		return assumptions, err
+	}
+	years, err := floatListParam(q, "years")
+	if err != nil {
+		return assumptions, err
+	}
+	if len(growthRates) > 0 {
+		if len(years) == 0 {
+			years = make([]float64, len(growthRates))
+			for i := range years {
+				years[i] = 5
+			}
+		}
+		if len(years) != len(growthRates) {
+			return assumptions, fmt.Errorf("growth and years must have the same number of stages")
+		}
+		assumptions.Stages = nil
+		for i, g := range growthRates {
+			if years[i] < 1 || years[i] > 50 {
+				return assumptions, fmt.Errorf("stage years must be between 1 and 50")
+			}
+			if years[i] != float64(int(years[i])) {
+				return assumptions, fmt.Errorf("stage years must be whole numbers")
+			}
+			assumptions.Stages = append(assumptions.Stages, dcf.Stage{Years: int(years[i]), Growth: g})
+		}
+	} else if len(years) > 0 {
+		return assumptions, fmt.Errorf("years requires growth")
+	}
+
+	params := []struct {
+		name  string
+		value *float64
+	}{
+		{"terminal_growth", &assumptions.TerminalGrowth},
+		{"risk_free_rate", &assumptions.RiskFreeRate},
+		{"equity_risk_premium", &assumptions.EquityRiskPremium},
+		{"beta", &assumptions.Beta},
+		{"cost_of_debt", &assumptions.CostOfDebt},
+		{"tax_rate", &assumptions.TaxRate},
+		{"discount_rate", &assumptions.DiscountRate},
+		{"base_fcf", &assumptions.BaseFCF},
+		{"fcf_margin", &assumptions.FCFMargin},
+	}
+	for _, p := range params {
+		v, err := floatParam(q, p.name, *p.value)
+		if err != nil {
+			return assumptions, err
+		}
+		*p.value = v
+	}
+	return assumptions, nil
+}
+
+// dcfInputs loads the company figures, latest close and request assumptions of a DCF valuation.
+// Beta comes from the FMP company profile unless the request overrides it.
+func (a *App) dcfInputs(r *http.Request, stock *models.Stock) (dcf.Company, float64, dcf.Assumptions, int, error) {
+	annual, err := a.DB.GetFinancialStatements(stock.StockID, "annual")
+	if err != nil {
+		return dcf.Company{}, 0, dcf.Assumptions{}, http.StatusInternalServerError, err
+	}
+	company, err := dcf.CompanyFromStatements(annual)
+	if err != nil {
+		return dcf.Company{}, 0, dcf.Assumptions{}, http.StatusUnprocessableEntity, err
+	}
+	price, err := a.DB.GetClosePriceOnOrBefore(stock.StockID, time.Now())
+	if err != nil {
+		return dcf.Company{}, 0, dcf.Assumptions{}, http.StatusInternalServerError, err
+	}
+	if price == 0 {
+		return dcf.Company{}, 0, dcf.Assumptions{}, http.StatusUnprocessableEntity, errNoPriceData
+	}
+
+	assumptions := dcf.DefaultAssumptions(annual)
+	q := r.URL.Query()
+	if q.Get("beta") == "" {
+		profiles, err := a.FMP.GetCompanyProfile(stock.Symbol)
+		if err != nil || len(profiles) == 0 || profiles[0].Beta <= 0 {
+			log.Printf("Could not get beta for %s, using default: %v", stock.Symbol, err)
+		} else {
+			assumptions.Beta = profiles[0].Beta
+		}
+	}
+	assumptions, err = dcfAssumptionsFromQuery(q, assumptions)
+	if err != nil {
+		return dcf.Company{}, 0, dcf.Assumptions{}, http.StatusBadRequest, err
+	}
+	return company, price, assumptions, http.StatusOK, nil
+}
+
+func (a *App) getDCFHandler(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	symbol := vars["symbol"]
+	if symbol == "" {
+		http.Error(w, "Symbol is required", http.StatusBadRequest)
+		return
+	}
+
+	stock, err := a.DB.GetStockBySymbol(symbol)
+	if err != nil {
+		log.Printf("Error getting stock by symbol %s: %v", symbol, err)
+		http.Error(w, "Failed to retrieve stock data", http.StatusInternalServerError)
+		return
+	}
+	if stock == nil {
+		http.Error(w, "Stock not found", http.StatusNotFound)
+		return
+	}
+
+	company, price, assumptions, status, err := a.dcfInputs(r, stock)
+	if err != nil {
+		log.Printf("Error preparing DCF for %s: %v", symbol, err)
+		http.Error(w, fmt.Sprintf("Failed to prepare DCF valuation: %v", err), status)
+		return
+	}
+
+	result, err := dcf.Evaluate(company, price, assumptions)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("DCF valuation failed: %v", err), http.StatusUnprocessableEntity)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(result)
+}
+
+// getDCFSimulationHandler runs a Monte Carlo DCF around the request's assumptions.
+// Besides the DCF parameters it accepts iterations, seed and the standard deviations
+// growth_sd, margin_sd, discount_rate_sd and terminal_growth_sd. Without a positive base FCF,
+// historical or from base_fcf or fcf_margin, it responds 422 like the DCF.
+func (a *App) getDCFSimulationHandler(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	symbol := vars["symbol"]
+	if symbol == "" {
+		http.Error(w, "Symbol is required", http.StatusBadRequest)
+		return
+	}
+
+	stock, err := a.DB.GetStockBySymbol(symbol)
+	if err != nil {
+		log.Printf("Error getting stock by symbol %s: %v", symbol, err)
+		http.Error(w, "Failed to retrieve stock data", http.StatusInternalServerError)
+		return
+	}
+	if stock == nil {
+		http.Error(w, "Stock not found", http.StatusNotFound)
+		return
+	}
+
+	company, price, assumptions, status, err := a.dcfInputs(r, stock)
+	if err != nil {
+		log.Printf("Error preparing DCF for %s: %v", symbol, err)
+		http.Error(w, fmt.Sprintf("Failed to prepare DCF valuation: %v", err), status)
+		return
+	}
+
+	cfg, err := dcf.DefaultSimulationConfig(company, price, assumptions)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("DCF simulation failed: %v", err), http.StatusUnprocessableEntity)
+		return
+	}
+	q := r.URL.Query()
+	if raw := q.Get("seed"); raw != "" {
+		cfg.Seed, err = strconv.ParseInt(raw, 10, 64)
+		if err != nil {
+			http.Error(w, fmt.Sprintf("invalid seed: %q", raw), http.StatusBadRequest)
+			return
+		}
+	}
+	iterations, err := floatParam(q, "iterations", float64(cfg.Iterations))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	cfg.Iterations = int(iterations)
+
+	stdDevs := []struct {
+		name  string
+		value *float64
+	}{
+		{"growth_sd", &cfg.Growth.StdDev},
+		{"discount_rate_sd", &cfg.DiscountRate.StdDev},
+		{"terminal_growth_sd", &cfg.TerminalGrowth.StdDev},
+	}
+	if cfg.FCFMargin != nil {
+		stdDevs = append(stdDevs, struct {
+			name  string
+			value *float64
+		}{"margin_sd", &cfg.FCFMargin.StdDev})
+	}
+	for _, sd := range stdDevs {
+		v, err := floatParam(q, sd.name, *sd.value)
+		if err != nil || v < 0 {
+			http.Error(w, fmt.Sprintf("invalid %s", sd.name), http.StatusBadRequest)
+			return
+		}
+		*sd.value = v
+	}
+
+	result, err := dcf.Simulate(company, price, assumptions, cfg)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("DCF simulation failed: %v", err), http.StatusUnprocessableEntity)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(result)
+}
+
+// grahamScreenResult is one stock passing the Graham screen
+type grahamScreenResult struct {
+	Symbol      string `json:"symbol"`
+	CompanyName string `json:"company_name"`
+	graham.Metrics
+}
+
+// getGrahamScreenHandler lists stocks trading below a Graham value. criterion is
+// graham_number (default), ncav or net_net; min_checks requires a number of passed
+// defensive-investor checks.
+func (a *App) getGrahamScreenHandler(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	criterion := q.Get("criterion")
+	if criterion == "" {
+		criterion = "graham_number"
+	}
+	if criterion != "graham_number" && criterion != "ncav" && criterion != "net_net" {
+		http.Error(w, "criterion must be graham_number, ncav or net_net", http.StatusBadRequest)
+		return
+	}
+	minChecks, err := floatParam(q, "min_checks", 0)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	allStocks, err := a.DB.GetAllStocks()
+	if err != nil {
+		log.Printf("Error retrieving all stocks for Graham screen: %v", err)
+		http.Error(w, "Failed to retrieve stocks", http.StatusInternalServerError)
+		return
+	}
+
+	results := []grahamScreenResult{}
+	for _, stock := range allStocks {
+		price, err := a.DB.GetClosePriceOnOrBefore(stock.StockID, time.Now())
+		if err != nil {
+			log.Printf("Could not get latest price for %s: %v", stock.Symbol, err)
+			continue
+		}
+		if price == 0 {
+			continue // No price data
+		}
+		annual, err := a.DB.GetFinancialStatements(stock.StockID, "annual")
+		if err != nil || len(annual) == 0 {
+			log.Printf("Could not get annual financial statements for %s: %v", stock.Symbol, err)
+			continue
+		}
+
+		m := graham.Compute(annual, price, a.dividendMetrics(stock, annual, price))
+		passes := false
+		switch criterion {
+		case "graham_number":
+			passes = m.BelowGraham
+		case "ncav":
+			passes = m.BelowNCAV
+		case "net_net":
+			passes = m.NetNet
+		}
+		if passes && float64(m.ChecksPassed) >= minChecks {
+			results = append(results, grahamScreenResult{Symbol: stock.Symbol, CompanyName: stock.CompanyName, Metrics: m})
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(results)
+}
+
+// minIndustryPeers is the fewest industry peers before the comparison widens to the sector
+const minIndustryPeers = 3
+
+// getPeersHandler compares a stock with its peers. By default peers are tracked stocks
+// of the same industry, widened to the sector when the industry has too few, within a
+// market cap band (?band=4 keeps a quarter to four times the stock's market cap).
+// ?source=fmp uses FMP's peer list instead. ?limit caps the number of peers.
+func (a *App) getPeersHandler(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	symbol := vars["symbol"]
+	if symbol == "" {
+		http.Error(w, "Symbol is required", http.StatusBadRequest)
+		return
+	}
+
+	q := r.URL.Query()
+	source := q.Get("source")
+	if source != "" && source != "local" && source != "fmp" {
+		http.Error(w, "source must be local or fmp", http.StatusBadRequest)
+		return
+	}
+	band, err := floatParam(q, "band", peers.DefaultMarketCapBand)
+	if err != nil || band < 1 {
+		http.Error(w, "band must be a number of at least 1", http.StatusBadRequest)
+		return
+	}
+	limit, err := floatParam(q, "limit", 10)
+	if err != nil || limit < 1 {
+		http.Error(w, "limit must be a positive number", http.StatusBadRequest)
+		return
+	}
+
+	stock, err := a.DB.GetStockBySymbol(symbol)
+	if err != nil {
+		log.Printf("Error getting stock by symbol %s: %v", symbol, err)
+		http.Error(w, "Failed to retrieve stock data", http.StatusInternalServerError)
+		return
+	}
+	if stock == nil {
+		http.Error(w, "Stock not found", http.StatusNotFound)
+		return
+	}
+
+	benchmark := a.scoringBenchmark()
+	targetInputs, err := a.scoringInputs(*stock, fundamentals.TTMPeriod, benchmark)
+	if err != nil {
+		log.Printf("Error loading scoring inputs for %s: %v", symbol, err)
+		http.Error(w, "Failed to load stock data for comparison", http.StatusUnprocessableEntity)
+		return
+	}
+	targetScore, err := undervaluation.Calculate(*targetInputs)
+	if err != nil {
+		log.Printf("Error calculating undervaluation for %s: %v", symbol, err)
+	}
+	comparison := peers.Comparison{
+		Stock: peers.RowFromInputs(*targetInputs, targetScore),
+		Peers: []peers.Row{},
+	}
+
+	// rowsFor scores candidates, keeping those within the market cap band when filtering
+	rowsFor := func(candidates []models.Stock, filterBand bool) []peers.Row {
+		rows := []peers.Row{}
+		for _, candidate := range candidates {
+			inputs, err := a.scoringInputs(candidate, fundamentals.TTMPeriod, benchmark)
+			if err != nil {
+				log.Printf("Skipping peer %s: %v", candidate.Symbol, err)
+				continue
+			}
+			score, err := undervaluation.Calculate(*inputs)
+			if err != nil {
+				log.Printf("Error calculating undervaluation for peer %s: %v", candidate.Symbol, err)
+			}
+			row := peers.RowFromInputs(*inputs, score)
+			if filterBand && !peers.WithinBand(comparison.Stock.MarketCap, row.MarketCap, band) {
+				continue
+			}
+			rows = append(rows, row)
+		}
+		return rows
+	}
+
+	if source == "fmp" {
+		comparison.GroupedBy = "fmp"
+		symbols, err := a.FMP.GetStockPeers(symbol)
+		if err != nil {
+			log.Printf("Error fetching FMP peers for %s: %v", symbol, err)
+			http.Error(w, "Failed to fetch peers", http.StatusBadGateway)
+			return
+		}
+		var candidates []models.Stock
+		for _, peerSymbol := range symbols {
+			peer, err := a.DB.GetStockBySymbol(peerSymbol)
+			if err != nil || peer == nil {
+				comparison.Untracked = append(comparison.Untracked, peerSymbol)
+				continue
+			}
+			candidates = append(candidates, *peer)
+		}
+		comparison.Peers = rowsFor(candidates, false)
+	} else {
+		allStocks, err := a.DB.GetAllStocks()
+		if err != nil {
+			log.Printf("Error retrieving all stocks for peers: %v", err)
+			http.Error(w, "Failed to retrieve stocks", http.StatusInternalServerError)
+			return
+		}
+		var industry, sector []models.Stock
+		for _, candidate := range allStocks {
+			if candidate.StockID == stock.StockID {
+				continue
+			}
+			if stock.Industry != "" && candidate.Industry == stock.Industry {
+				industry = append(industry, candidate)
+			}
+			if stock.Sector != "" && candidate.Sector == stock.Sector {
+				sector = append(sector, candidate)
+			}
+		}
+
+		// Without a market cap of its own the stock cannot be banded, so all peers qualify
+		filterBand := comparison.Stock.MarketCap != nil && *comparison.Stock.MarketCap > 0
+		comparison.GroupedBy = "industry"
+		comparison.Peers = rowsFor(industry, filterBand)
+		if len(comparison.Peers) < minIndustryPeers {
+			comparison.GroupedBy = "sector"
+			comparison.Peers = rowsFor(sector, filterBand)
+		}
+	}
+
+	comparison.Peers = peers.ClosestByMarketCap(comparison.Stock.MarketCap, comparison.Peers, int(limit))
+	comparison.PeerMedians = peers.Medians(comparison.Peers)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(comparison)
+}
+
+// screenResponse is the result of running a screen
+type screenResponse struct {
+	Screen  screener.Screen   `json:"screen"` // With ordering defaults filled in
+	Count   int               `json:"count"`  // Matches before the limit
+	Results []screener.Record `json:"results"`
+}
+
+// runScreen evaluates a compiled screen over the tracked stocks. Conditions on stored
+// stock columns narrow the universe in the database; every other metric is computed
+// from the scoring inputs and evaluated in memory.
+func (a *App) runScreen(c *screener.Compiled) (*screenResponse, error) {
+	var stocks []models.Stock
+	var err error
+	if condition, args, ok := c.SQLFilter(); ok {
+		stocks, err = a.DB.GetStocksWhere(condition, args...)
+	} else {
+		stocks, err = a.DB.GetAllStocks()
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	matches := []screener.Record{}
+	benchmark := a.scoringBenchmark()
+	for _, stock := range stocks {
+		inputs, err := a.scoringInputs(stock, fundamentals.TTMPeriod, benchmark)
+		if err != nil {
+			log.Printf("Could not build scoring inputs for %s: %v", stock.Symbol, err)
+			continue
+		}
+		score, err := undervaluation.Calculate(*inputs)
+		if err != nil {
+			log.Printf("Error calculating undervaluation for %s: %v", stock.Symbol, err)
+		}
+		record := screener.NewRecord(*inputs, score)
+		if c.Match(record) {
+			matches = append(matches, record)
+		}
+	}
+
+	return &screenResponse{Screen: c.Screen, Count: len(matches), Results: c.Rank(matches)}, nil
+}
+
+// runScreenHandler runs an ad-hoc screen, e.g.
+// {"query": "sector = \"Technology\" AND pe < 15 AND roic > 0.12", "sort_by": "pe", "order": "asc", "limit": 20}
+func (a *App) runScreenHandler(w http.ResponseWriter, r *http.Request) {
+	var screen screener.Screen
+	if err := json.NewDecoder(r.Body).Decode(&screen); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	compiled, err := screener.Compile(screen)
+	if err != nil {
+		http.Error(w, "Invalid screen: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	result, err := a.runScreen(compiled)
+	if err != nil {
+		log.Printf("Error running screen %q: %v", screen.Query, err)
+		http.Error(w, "Failed to run screen", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(result)
+}
+
+// getScreenFieldsHandler lists the metrics screens can reference
+func (a *App) getScreenFieldsHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(screener.Catalog())
+}
+
+// requestUserID identifies the user owning saved screens
+func requestUserID(w http.ResponseWriter, r *http.Request) (string, bool) {
+	userID := strings.TrimSpace(r.Header.Get("X-User-ID"))
+	if userID == "" {
+		http.Error(w, "X-User-ID header is required", http.StatusUnauthorized)
+		return "", false
+	}
+	return userID, true
+}
+
+// savedScreenFromRequest loads the user's saved screen named by the {id} route variable,
+// writing the error response when it cannot
+func (a *App) savedScreenFromRequest(w http.ResponseWriter, r *http.Request) (*models.SavedScreen, bool) {
+	userID, ok := requestUserID(w, r)
+	if !ok {
+		return nil, false
+	}
+	screenID, err := uuid.Parse(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "Invalid screen id", http.StatusBadRequest)
+		return nil, false
+	}
+	screen, err := a.DB.GetSavedScreen(userID, screenID)
+	if err != nil {
+		log.Printf("Error getting saved screen %s: %v", screenID, err)
+		http.Error(w, "Failed to retrieve saved screen", http.StatusInternalServerError)
+		return nil, false
+	}
+	if screen == nil {
+		http.Error(w, "Saved screen not found", http.StatusNotFound)
+		return nil, false
+	}
+	return screen, true
+}
+
+func (a *App) getSavedScreensHandler(w http.ResponseWriter, r *http.Request) {
+	userID, ok := requestUserID(w, r)
+	if !ok {
+		return
+	}
+	screens, err := a.DB.GetSavedScreens(userID)
+	if err != nil {
+		log.Printf("Error retrieving saved screens for %s: %v", userID, err)
+		http.Error(w, "Failed to retrieve saved screens", http.StatusInternalServerError)
+		return
+	}
+	if screens == nil {
+		screens = []models.SavedScreen{}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(screens)
+}
+
+// saveScreenHandler validates and stores a screen under a name, replacing any of the same name
+func (a *App) saveScreenHandler(w http.ResponseWriter, r *http.Request) {
+	userID, ok := requestUserID(w, r)
+	if !ok {
+		return
+	}
+	var body struct {
+		Name string `json:"name"`
+		screener.Screen
+	}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	body.Name = strings.TrimSpace(body.Name)
+	if body.Name == "" {
+		http.Error(w, "Name is required", http.StatusBadRequest)
+		return
+	}
+	compiled, err := screener.Compile(body.Screen)
+	if err != nil {
+		http.Error(w, "Invalid screen: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	screen := &models.SavedScreen{
+		UserID:      userID,
+		Name:        body.Name,
+		Query:       compiled.Query,
+		SortBy:      compiled.SortBy,
+		SortOrder:   compiled.Order,
+		ResultLimit: compiled.Limit,
+	}
+	if err := a.DB.UpsertSavedScreen(screen); err != nil {
+		log.Printf("Error saving screen %q for %s: %v", body.Name, userID, err)
+		http.Error(w, "Failed to save screen", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(screen)
+}
+
+func (a *App) getSavedScreenHandler(w http.ResponseWriter, r *http.Request) {
+	screen, ok := a.savedScreenFromRequest(w, r)
+	if !ok {
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(screen)
+}
+
+func (a *App) deleteSavedScreenHandler(w http.ResponseWriter, r *http.Request) {
+	userID, ok := requestUserID(w, r)
+	if !ok {
+		return
+	}
+	screenID, err := uuid.Parse(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "Invalid screen id", http.StatusBadRequest)
+		return
+	}
+	deleted, err := a.DB.DeleteSavedScreen(userID, screenID)
+	if err != nil {
+		log.Printf("Error deleting saved screen %s: %v", screenID, err)
+		http.Error(w, "Failed to delete saved screen", http.StatusInternalServerError)
+		return
+	}
+	if !deleted {
+		http.Error(w, "Saved screen not found", http.StatusNotFound)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (a *App) runSavedScreenHandler(w http.ResponseWriter, r *http.Request) {
+	saved, ok := a.savedScreenFromRequest(w, r)
+	if !ok {
+		return
+	}
+	compiled, err := screener.Compile(screener.Screen{
+		Query:  saved.Query,
+		SortBy: saved.SortBy,
+		Order:  saved.SortOrder,
+		Limit:  saved.ResultLimit,
+	})
+	if err != nil {
+		// The catalog may have changed since the screen was saved
+		http.Error(w, "Saved screen is no longer valid: "+err.Error(), http.StatusUnprocessableEntity)
+		return
+	}
+
+	result, err := a.runScreen(compiled)
+	if err != nil {
+		log.Printf("Error running saved screen %s: %v", saved.ScreenID, err)
+		http.Error(w, "Failed to run screen", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(result)
+}
+
+// runBacktestHandler replays the scoring model over stored history, e.g.
+// {"start": "2019-01-01", "end": "2024-01-01", "method": "top_n", "top_n": 20, "rebalance": "monthly", "cost_bps": 10}
+// Omitted settings take the backtest defaults; the benchmark defaults to BENCHMARK_SYMBOL (SPY)
+// and "" disables it.
+func (a *App) runBacktestHandler(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		backtest.Config
+		Start     string   `json:"start"`
+		End       string   `json:"end"`
+		Symbols   []string `json:"symbols"`
+		Benchmark *string  `json:"benchmark"`
+	}
+	req.Config = backtest.DefaultConfig()
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	cfg := req.Config
+	for _, d := range []struct {
+		name  string
+		raw   string
+		value *time.Time
+	}{{"start", req.Start, &cfg.Start}, {"end", req.End, &cfg.End}} {
+		if d.raw == "" {
+			continue
+		}
+		t, err := time.Parse("2006-01-02", d.raw)
+		if err != nil {
+			http.Error(w, fmt.Sprintf("invalid %s: %q, expected YYYY-MM-DD", d.name, d.raw), http.StatusBadRequest)
+			return
+		}
+		*d.value = t
+	}
+	if err := cfg.Validate(); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	benchmark := a.Benchmark
+	if req.Benchmark != nil {
+		benchmark = strings.ToUpper(*req.Benchmark)
+	}
+
+	dataset, err := backtest.Load(a.DB, req.Symbols, benchmark, cfg.Start, cfg.End)
+	if errors.Is(err, backtest.ErrUnknownSymbol) {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err != nil {
+		log.Printf("Error loading backtest data: %v", err)
+		http.Error(w, "Failed to load backtest data", http.StatusInternalServerError)
+		return
+	}
+
+	result, err := backtest.Run(dataset, cfg)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusUnprocessableEntity)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(result)
+}
+
+// getStatementsHandler returns the financial statements of a stock. With as_of=YYYY-MM-DD only
+// the statements known by the end of that day are returned, in the revision current then;
+// with revisions=true every revision is listed instead of only the latest per period.
+func (a *App) getStatementsHandler(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	symbol := vars["symbol"]
+	if symbol == "" {
+		http.Error(w, "Symbol is required", http.StatusBadRequest)
+		return
+	}
+
+	q := r.URL.Query()
+	period := q.Get("period")
+	if period == "" {
+		period = "annual"
+	}
+	if period != "annual" && period != "quarterly" && period != fundamentals.TTMPeriod {
+		http.Error(w, "period must be annual, quarterly or ttm", http.StatusBadRequest)
+		return
+	}
+	var asOf *time.Time
+	if raw := q.Get("as_of"); raw != "" {
+		day, err := time.Parse("2006-01-02", raw)
+		if err != nil {
+			http.Error(w, fmt.Sprintf("invalid as_of: %q, expected YYYY-MM-DD", raw), http.StatusBadRequest)
+			return
+		}
+		endOfDay := day.AddDate(0, 0, 1).Add(-time.Nanosecond)
+		asOf = &endOfDay
+	}
+	allRevisions := q.Get("revisions") == "true"
+	if allRevisions && period == fundamentals.TTMPeriod {
+		http.Error(w, "revisions are only available for annual and quarterly statements", http.StatusBadRequest)
+		return
+	}
+
+	stock, err := a.DB.GetStockBySymbol(symbol)
+	if err != nil {
+		log.Printf("Error getting stock by symbol %s: %v", symbol, err)
+		http.Error(w, "Failed to retrieve stock data", http.StatusInternalServerError)
+		return
+	}
+	if stock == nil {
+		http.Error(w, "Stock not found", http.StatusNotFound)
+		return
+	}
+
+	var statements []models.FinancialStatement
+	switch {
+	case allRevisions:
+		statements, err = a.DB.GetFinancialStatementRevisions(stock.StockID, period)
+		if err == nil && asOf != nil {
+			known := statements[:0]
+			for _, fs := range statements {
+				if !fs.KnownSince.After(*asOf) {
+					known = append(known, fs)
+				}
+			}
+			statements = known
+		}
+	case asOf != nil:
+		statements, err = a.DB.GetFinancialStatementsAsOf(stock.StockID, period, *asOf)
+	default:
+		statements, err = a.DB.GetFinancialStatements(stock.StockID, period)
+	}
+	if err != nil {
+		log.Printf("Error retrieving financial statements for %s: %v", symbol, err)
+		http.Error(w, "Failed to retrieve financial statements", http.StatusInternalServerError)
+		return
+	}
+	if statements == nil {
+		statements = []models.FinancialStatement{}
+	}
+
+	response := struct {
+		Symbol     string                      `json:"symbol"`
+		Period     string                      `json:"period"`
+		AsOf       *time.Time                  `json:"as_of,omitempty"`
+		Statements []models.FinancialStatement `json:"statements"`
+	}{stock.Symbol, period, asOf, statements}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+// factorRequest is the normalized request of a factor analysis; its hash is the cache key
+type factorRequest struct {
+	Config  factors.Config `json:"config"`
+	Symbols []string       `json:"symbols"` // Every tracked stock when empty
+}
+
+// runFactorAnalysisHandler evaluates the scoring metrics against forward returns, e.g.
+// {"start": "2015-01-01", "end": "2023-01-01", "horizons": [1, 3, 12], "factors": ["score", "pe"]}
+// Omitted settings take the analysis defaults. Results are cached per request and returned
+// from the cache unless "refresh" is true.
+func (a *App) runFactorAnalysisHandler(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		factors.Config
+		Start   string   `json:"start"`
+		End     string   `json:"end"`
+		Symbols []string `json:"symbols"`
+		Refresh bool     `json:"refresh"`
+	}
+	req.Config = factors.DefaultConfig()
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	cfg := req.Config
+	for _, d := range []struct {
+		name  string
+		raw   string
+		value *time.Time
+	}{{"start", req.Start, &cfg.Start}, {"end", req.End, &cfg.End}} {
+		if d.raw == "" {
+			continue
+		}
+		t, err := time.Parse("2006-01-02", d.raw)
+		if err != nil {
+			http.Error(w, fmt.Sprintf("invalid %s: %q, expected YYYY-MM-DD", d.name, d.raw), http.StatusBadRequest)
+			return
+		}
+		*d.value = t
+	}
+	if err := cfg.Validate(); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	normalized := factorRequest{Config: cfg, Symbols: []string{}}
+	for _, symbol := range req.Symbols {
+		normalized.Symbols = append(normalized.Symbols, strings.ToUpper(symbol))
+	}
+	sort.Strings(normalized.Symbols)
+	sort.Strings(normalized.Config.Factors)
+	sort.Ints(normalized.Config.Horizons)
+	request, err := json.Marshal(normalized)
+	if err != nil {
+		log.Printf("Error encoding factor analysis request: %v", err)
+		http.Error(w, "Failed to run factor analysis", http.StatusInternalServerError)
+		return
+	}
+	sum := sha256.Sum256(request)
+	cacheKey := hex.EncodeToString(sum[:])
+
+	if !req.Refresh {
+		cached, err := a.DB.GetFactorAnalysisByKey(cacheKey)
+		if err != nil {
+			log.Printf("Error reading cached factor analysis: %v", err)
+		} else if cached != nil {
+			w.Header().Set("Content-Type", "application/json")
+			json.NewEncoder(w).Encode(cached)
+			return
+		}
+	}
+
+	// Forward returns of the last dates need prices after the end of the analysis
+	to := cfg.End.AddDate(0, cfg.MaxHorizon(), 0)
+	dataset, err := backtest.Load(a.DB, normalized.Symbols, "", cfg.Start, to)
+	if errors.Is(err, backtest.ErrUnknownSymbol) {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err != nil {
+		log.Printf("Error loading factor analysis data: %v", err)
+		http.Error(w, "Failed to load factor analysis data", http.StatusInternalServerError)
+		return
+	}
+
+	result, err := factors.Run(dataset, normalized.Config)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusUnprocessableEntity)
+		return
+	}
+	encoded, err := json.Marshal(result)
+	if err != nil {
+		log.Printf("Error encoding factor analysis result: %v", err)
+		http.Error(w, "Failed to run factor analysis", http.StatusInternalServerError)
+		return
+	}
+
+	analysis := &models.FactorAnalysis{CacheKey: cacheKey, Request: request, Result: encoded}
+	if err := a.DB.UpsertFactorAnalysis(analysis); err != nil {
+		// The result is still returned, it just will not be served from the cache
+		log.Printf("Error caching factor analysis: %v", err)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(analysis)
+}
+
+func (a *App) getFactorAnalysesHandler(w http.ResponseWriter, r *http.Request) {
+	analyses, err := a.DB.GetFactorAnalyses()
+	if err != nil {
+		log.Printf("Error retrieving factor analyses: %v", err)
+		http.Error(w, "Failed to retrieve factor analyses", http.StatusInternalServerError)
+		return
+	}
+	if analyses == nil {
+		analyses = []models.FactorAnalysis{}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(analyses)
+}
+
+// getFactorAnalysisHandler returns a cached factor analysis. The result can be narrowed with
+// factor= and category= (comma-separated) and horizon= (months); series=false drops the
+// per-date coefficients.
+func (a *App) getFactorAnalysisHandler(w http.ResponseWriter, r *http.Request) {
+	analysisID, err := uuid.Parse(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "Invalid analysis id", http.StatusBadRequest)
+		return
+	}
+
+	q := r.URL.Query()
+	horizon := 0
+	if raw := q.Get("horizon"); raw != "" {
+		if horizon, err = strconv.Atoi(raw); err != nil || horizon <= 0 {
+			http.Error(w, "horizon must be a positive number of months", http.StatusBadRequest)
+			return
+		}
+	}
+	names := map[string]bool{}
+	for _, name := range strings.Split(q.Get("factor"), ",") {
+		if name = strings.TrimSpace(name); name != "" {
+			names[name] = true
+		}
+	}
+	categories := map[string]bool{}
+	for _, category := range strings.Split(q.Get("category"), ",") {
+		if category = strings.TrimSpace(category); category != "" {
+			categories[category] = true
+		}
+	}
+
+	analysis, err := a.DB.GetFactorAnalysis(analysisID)
+	if err != nil {
+		log.Printf("Error retrieving factor analysis %s: %v", analysisID, err)
+		http.Error(w, "Failed to retrieve factor analysis", http.StatusInternalServerError)
+		return
+	}
+	if analysis == nil {
+		http.Error(w, "Factor analysis not found", http.StatusNotFound)
+		return
+	}
+
+	var result factors.Result
+	if err := json.Unmarshal(analysis.Result, &result); err != nil {
+		log.Printf("Error decoding factor analysis %s: %v", analysisID, err)
+		http.Error(w, "Failed to retrieve factor analysis", http.StatusInternalServerError)
+		return
+	}
+	filtered := result.Factors[:0]
+	for _, f := range result.Factors {
+		if (len(names) > 0 && !names[f.Name]) || (len(categories) > 0 && !categories[f.Category]) {
+			continue
+		}
+		horizons := f.Horizons[:0]
+		for _, h := range f.Horizons {
+			if horizon != 0 && h.Months != horizon {
+				continue
+			}
+			if q.Get("series") == "false" {
+				h.Series = nil
+			}
+			horizons = append(horizons, h)
+		}
+		f.Horizons = horizons
+		filtered = append(filtered, f)
+	}
+	result.Factors = filtered
+
+	response := struct {
+		AnalysisID uuid.UUID       `json:"analysis_id"`
+		Request    json.RawMessage `json:"request"`
+		Result     factors.Result  `json:"result"`
+		CreatedAt  time.Time       `json:"created_at"`
+		UpdatedAt  time.Time       `json:"updated_at"`
+	}{analysis.AnalysisID, analysis.Request, result, analysis.CreatedAt, analysis.UpdatedAt}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+// defaultIndicators are charted when no names are requested
+const defaultIndicators = "sma_50,sma_200,rsi_14,macd"
+
+// getIndicatorsHandler returns technical indicators aligned with the daily closes between
+// from and to (YYYY-MM-DD, the last year by default), e.g. ?names=sma_50,bbands_20_2,macd.
+// Earlier history is loaded so the indicators are defined from the first date.
+func (a *App) getIndicatorsHandler(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	symbol := vars["symbol"]
+	if symbol == "" {
+		http.Error(w, "Symbol is required", http.StatusBadRequest)
+		return
+	}
+
+	q := r.URL.Query()
+	names := q.Get("names")
+	if names == "" {
+		names = defaultIndicators
+	}
+	var indicators []technicals.Indicator
+	lookback := 0
+	for _, name := range strings.Split(names, ",") {
+		if strings.TrimSpace(name) == "" {
+			continue
+		}
+		ind, err := technicals.ParseIndicator(name)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		indicators = append(indicators, ind)
+		if ind.Lookback() > lookback {
+			lookback = ind.Lookback()
+		}
+	}
+
+	from, to, err := dateRangeFromQuery(q, time.Now().AddDate(-1, 0, 0))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	stock, err := a.DB.GetStockBySymbol(symbol)
+	if err != nil {
+		log.Printf("Error getting stock by symbol %s: %v", symbol, err)
+		http.Error(w, "Failed to retrieve stock data", http.StatusInternalServerError)
+		return
+	}
+	if stock == nil {
+		http.Error(w, "Stock not found", http.StatusNotFound)
+		return
+	}
+
+	// Trading bars to calendar days, with a margin for holidays
+	warmup := time.Duration(lookback*7/5+10) * 24 * time.Hour
+	prices, err := a.DB.GetAdjustedHistoricalPrices(stock.StockID, from.Add(-warmup), to, true)
+	if err != nil {
+		log.Printf("Error retrieving historical prices for %s: %v", symbol, err)
+		http.Error(w, "Failed to retrieve historical prices", http.StatusInternalServerError)
+		return
+	}
+	first := sort.Search(len(prices), func(i int) bool { return !prices[i].Time.Before(from) })
+
+	response := struct {
+		Symbol     string                                  `json:"symbol"`
+		Dates      []time.Time                             `json:"dates"`
+		Close      []float64                               `json:"close"`
+		Indicators map[string]map[string]technicals.Series `json:"indicators"`
+	}{Symbol: stock.Symbol, Dates: []time.Time{}, Close: []float64{}, Indicators: map[string]map[string]technicals.Series{}}
+	for _, p := range prices[first:] {
+		response.Dates = append(response.Dates, p.Time)
+		response.Close = append(response.Close, p.ClosePrice)
+	}
+	for _, ind := range indicators {
+		lines := ind.Compute(prices)
+		for line, series := range lines {
+			lines[line] = series[first:]
+		}
+		response.Indicators[ind.Name] = lines
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+// benchmarkPrices returns the adjusted daily prices of the benchmark symbol, or nil when it is not tracked
+func (a *App) benchmarkPrices(from, to time.Time) ([]models.HistoricalPrice, error) {
+	stock, err := a.DB.GetStockBySymbol(a.Benchmark)
+	if err != nil || stock == nil {
+		return nil, err
+	}
+	return a.DB.GetAdjustedHistoricalPrices(stock.StockID, from, to, true)
+}
+
+// getRiskHandler returns the risk statistics of a stock's daily closes between from and to
+// (YYYY-MM-DD, the last year by default) against the benchmark (BENCHMARK_SYMBOL unless
+// ?benchmark= is given). With rolling=true it adds the statistics of every trailing window
+// of window trading days (63 by default); risk_free_rate is annual, 0 by default.
+func (a *App) getRiskHandler(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	symbol := vars["symbol"]
+	if symbol == "" {
+		http.Error(w, "Symbol is required", http.StatusBadRequest)
+		return
+	}
+
+	q := r.URL.Query()
+	from, to, err := dateRangeFromQuery(q, time.Now().AddDate(-1, 0, 0))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	window := risk.DefaultWindow
+	if raw := q.Get("window"); raw != "" {
+		n, err := strconv.Atoi(raw)
+		if err != nil || n < 20 {
+			http.Error(w, "window must be at least 20 trading days", http.StatusBadRequest)
+			return
+		}
+		window = n
+	}
+	riskFreeRate := 0.0
+	if raw := q.Get("risk_free_rate"); raw != "" {
+		v, err := strconv.ParseFloat(raw, 64)
+		if err != nil {
+			http.Error(w, "risk_free_rate must be a number", http.StatusBadRequest)
+			return
+		}
+		riskFreeRate = v
+	}
+	benchmarkSymbol := a.Benchmark
+	if _, ok := q["benchmark"]; ok {
+		benchmarkSymbol = strings.ToUpper(q.Get("benchmark"))
+	}
+
+	stock, err := a.DB.GetStockBySymbol(symbol)
+	if err != nil {
+		log.Printf("Error getting stock by symbol %s: %v", symbol, err)
+		http.Error(w, "Failed to retrieve stock data", http.StatusInternalServerError)
+		return
+	}
+	if stock == nil {
+		http.Error(w, "Stock not found", http.StatusNotFound)
+		return
+	}
+
+	prices, err := a.DB.GetAdjustedHistoricalPrices(stock.StockID, from, to, true)
+	if err != nil {
+		log.Printf("Error retrieving historical prices for %s: %v", symbol, err)
+		http.Error(w, "Failed to retrieve historical prices", http.StatusInternalServerError)
+		return
+	}
+
+	var benchmark []models.HistoricalPrice
+	if benchmarkSymbol != "" && benchmarkSymbol != stock.Symbol {
+		bench, err := a.DB.GetStockBySymbol(benchmarkSymbol)
+		if err != nil {
+			log.Printf("Error getting benchmark %s: %v", benchmarkSymbol, err)
+			http.Error(w, "Failed to retrieve benchmark data", http.StatusInternalServerError)
+			return
+		}
+		if bench == nil {
+			http.Error(w, "Benchmark not found", http.StatusNotFound)
+			return
+		}
+		if benchmark, err = a.DB.GetAdjustedHistoricalPrices(bench.StockID, from, to, true); err != nil {
+			log.Printf("Error retrieving benchmark prices for %s: %v", benchmarkSymbol, err)
+			http.Error(w, "Failed to retrieve benchmark data", http.StatusInternalServerError)
+			return
+		}
+	}
+
+	metrics, ok := risk.Compute(prices, benchmark, riskFreeRate)
+	if !ok {
+		http.Error(w, "Not enough price history for risk statistics", http.StatusUnprocessableEntity)
+		return
+	}
+
+	response := struct {
+		Symbol    string       `json:"symbol"`
+		Benchmark string       `json:"benchmark,omitempty"`
+		Metrics   risk.Metrics `json:"metrics"`
+		Penalty   float64      `json:"drawdown_penalty"` // Share of the fundamental score forfeited for the drawdown
+		Window    int          `json:"window,omitempty"`
+		Rolling   []risk.Point `json:"rolling,omitempty"`
+	}{Symbol: stock.Symbol, Benchmark: benchmarkSymbol, Metrics: metrics, Penalty: risk.DrawdownPenalty(metrics.MaxDrawdown)}
+	if q.Get("rolling") == "true" {
+		response.Window = window
+		response.Rolling = risk.Rolling(prices, benchmark, window, riskFreeRate)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+// observedSessionShare is the share of an exchange's stocks that must have a bar on a day
+// for it to count as a session when the exchange has no calendar rules
+const observedSessionShare = 0.5
+
+// backfillBatch is how many backfill jobs a run claims by default
+const backfillBatch = 20
+
+// priceGapReport is the result of checking one stock's daily bars
+type priceGapReport struct {
+	Symbol string `json:"symbol"`
+	dataquality.Report
+	Queued int `json:"queued"` // Backfill jobs queued for the gaps
+}
+
+// scanPriceGaps checks the daily bars of stocks against the calendars of their exchanges
+// between two days, from each stock's first bar and up to yesterday (or the last bar of an
+// inactive stock). It returns the reports of the stocks with missing sessions and, with
+// queue, queues a backfill for each gap.
+func (a *App) scanPriceGaps(stocks []models.Stock, from, to time.Time, queue bool) ([]priceGapReport, int, error) {
+	yesterday := calendar.Date(time.Now()).AddDate(0, 0, -1)
+	if to.After(yesterday) {
+		to = yesterday
+	}
+	observed := map[string]calendar.Calendar{}
+
+	reports := []priceGapReport{}
+	queued := 0
+	for _, stock := range stocks {
+		first, last, ok, err := a.DB.GetPriceRange(stock.StockID)
+		if err != nil {
+			return nil, 0, err
+		}
+		if !ok {
+			continue
+		}
+		start, end := from, to
+		if first.After(start) {
+			start = first
+		}
+		if !stock.IsActive && last.Before(end) {
+			end = last
+		}
+		start, end = calendar.Date(start), calendar.Date(end)
+		if end.Before(start) {
+			continue
+		}
+
+		cal, ok := calendar.ForExchange(stock.Exchange)
+		if !ok {
+			if cal, ok = observed[stock.Exchange]; !ok {
+				counts, err := a.DB.GetTradingDayCounts(stock.Exchange, calendar.Date(from), to.AddDate(0, 0, 1))
+				if err != nil {
+					return nil, 0, err
+				}
+				cal = calendar.Observed(stock.Exchange, dataquality.ObservedSessions(counts, observedSessionShare))
+				observed[stock.Exchange] = cal
+			}
+		}
+
+		// Only the bar dates are checked, so the prices need no adjustment
+		prices, err := a.DB.GetHistoricalPrices(stock.StockID, start, end.AddDate(0, 0, 1))
+		if err != nil {
+			return nil, 0, err
+		}
+		bars := make([]time.Time, len(prices))
+		for i, p := range prices {
+			bars[i] = p.Time
+		}
+		report := priceGapReport{Symbol: stock.Symbol, Report: dataquality.Check(cal, bars, start, end)}
+		if report.Missing == 0 {
+			continue
+		}
+
+		if queue {
+			for _, gap := range report.Gaps {
+				job := &models.BackfillJob{StockID: stock.StockID, Symbol: stock.Symbol, DataType: models.BackfillDailyPrices,
+					RangeStart: gap.From, RangeEnd: gap.To}
+				added, err := a.DB.QueueBackfillJob(job)
+				if err != nil {
+					return nil, 0, err
+				}
+				if added {
+					report.Queued++
+				}
+			}
+			queued += report.Queued
+		}
+		reports = append(reports, report)
+	}
+	return reports, queued, nil
+}
+
+// runBackfillJobs claims up to limit pending backfill jobs and fetches their ranges from FMP
+func (a *App) runBackfillJobs(limit int) ([]models.BackfillJob, error) {
+	jobs, err := a.DB.ClaimBackfillJobs(limit)
+	if err != nil {
+		return nil, err
+	}
+	for i := range jobs {
+		job := &jobs[i]
+		rows, jobErr := a.backfill(job)
+		if jobErr != nil {
+			log.Printf("Backfill of %s %s from %s to %s failed: %v", job.Symbol, job.DataType,
+				job.RangeStart.Format("2006-01-02"), job.RangeEnd.Format("2006-01-02"), jobErr)
+			job.Status, job.LastError = models.BackfillFailed, jobErr.Error()
+		} else {
+			job.Status, job.LastError = models.BackfillDone, ""
+		}
+		job.RowsIngested = rows
+		if err := a.DB.FinishBackfillJob(job.JobID, rows, jobErr); err != nil {
+			return nil, err
+		}
+	}
+	return jobs, nil
+}
+
+// backfill ingests the range of a backfill job and returns the number of rows stored
+func (a *App) backfill(job *models.BackfillJob) (int, error) {
+	switch job.DataType {
+	case models.BackfillDailyPrices:
+		fmpPrices, err := a.FMP.GetHistoricalPrices(job.Symbol, job.RangeStart, job.RangeEnd)
+		if err != nil {
+			return 0, err
+		}
+		stock := &models.Stock{StockID: job.StockID, Symbol: job.Symbol}
+		return a.storeHistoricalPrices(stock, fmpPrices), nil
+	}
+	return 0, fmt.Errorf("unknown backfill data type %q", job.DataType)
+}
+
+// scanPriceGapsHandler checks the daily bars of all stocks, or of ?symbol=, for sessions
+// without a bar between from and to (YYYY-MM-DD, the last year by default) and queues a
+// backfill for each gap unless queue=false
+func (a *App) scanPriceGapsHandler(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	from, to, err := dateRangeFromQuery(q, time.Now().AddDate(-1, 0, 0))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	var stocks []models.Stock
+	if symbol := strings.ToUpper(q.Get("symbol")); symbol != "" {
+		stock, err := a.DB.GetStockBySymbol(symbol)
+		if err != nil {
+			log.Printf("Error getting stock by symbol %s: %v", symbol, err)
+			http.Error(w, "Failed to retrieve stock data", http.StatusInternalServerError)
+			return
+		}
+		if stock == nil {
+			http.Error(w, "Stock not found", http.StatusNotFound)
+			return
+		}
+		stocks = []models.Stock{*stock}
+	} else if stocks, err = a.DB.GetAllStocks(); err != nil {
+		log.Printf("Error retrieving stocks: %v", err)
+		http.Error(w, "Failed to retrieve stocks", http.StatusInternalServerError)
+		return
+	}
+
+	reports, queued, err := a.scanPriceGaps(stocks, from, to, q.Get("queue") != "false")
+	if err != nil {
+		log.Printf("Error scanning price gaps: %v", err)
+		http.Error(w, "Failed to scan price gaps", http.StatusInternalServerError)
+		return
+	}
+
+	response := struct {
+		Scanned int              `json:"scanned"`
+		Queued  int              `json:"queued"`
+		Stocks  []priceGapReport `json:"stocks"` // Stocks with missing sessions
+	}{Scanned: len(stocks), Queued: queued, Stocks: reports}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+// getBackfillJobsHandler lists backfill jobs, newest first, optionally with a ?status=
+func (a *App) getBackfillJobsHandler(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	status := q.Get("status")
+	switch status {
+	case "", models.BackfillPending, models.BackfillRunning, models.BackfillDone, models.BackfillFailed:
+	default:
+		http.Error(w, "status must be pending, running, done or failed", http.StatusBadRequest)
+		return
+	}
+	limit, err := floatParam(q, "limit", 100)
+	if err != nil || limit < 1 {
+		http.Error(w, "limit must be a positive number", http.StatusBadRequest)
+		return
+	}
+
+	jobs, err := a.DB.GetBackfillJobs(status, int(limit))
+	if err != nil {
+		log.Printf("Error retrieving backfill jobs: %v", err)
+		http.Error(w, "Failed to retrieve backfill jobs", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(jobs)
+}
+
+// runBackfillJobsHandler processes up to ?limit= pending backfill jobs and returns them
+// with their outcome
+func (a *App) runBackfillJobsHandler(w http.ResponseWriter, r *http.Request) {
+	limit, err := floatParam(r.URL.Query(), "limit", backfillBatch)
+	if err != nil || limit < 1 {
+		http.Error(w, "limit must be a positive number", http.StatusBadRequest)
+		return
+	}
+
+	jobs, err := a.runBackfillJobs(int(limit))
+	if err != nil {
+		log.Printf("Error running backfill jobs: %v", err)
+		http.Error(w, "Failed to run backfill jobs", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(jobs)
+}
+
+// runDataQuality scans all stocks for price gaps over the last year and works off the
+// backfill queue at every interval
+func (a *App) runDataQuality(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for range ticker.C {
+		stocks, err := a.DB.GetAllStocks()
+		if err != nil {
+			log.Printf("Data quality: error retrieving stocks: %v", err)
+			continue
+		}
+		to := time.Now()
+		reports, queued, err := a.scanPriceGaps(stocks, to.AddDate(-1, 0, 0), to, true)
+		if err != nil {
+			log.Printf("Data quality: error scanning price gaps: %v", err)
+			continue
+		}
+		log.Printf("Data quality: %d of %d stocks have price gaps, %d backfills queued", len(reports), len(stocks), queued)
+
+		for {
+			jobs, err := a.runBackfillJobs(backfillBatch)
+			if err != nil {
+				log.Printf("Data quality: error running backfill jobs: %v", err)
+				break
+			}
+			if len(jobs) == 0 {
+				break
+			}
+		}
+	}
+}
+
+func main() {
+	app := App{Benchmark: strings.ToUpper(os.Getenv("BENCHMARK_SYMBOL"))}
+	app.Initialize(
+		os.Getenv("DB_HOST"),
+		os.Getenv("DB_PORT"),
+		os.Getenv("DB_USER"),
+		os.Getenv("DB_PASSWORD"),
+		os.Getenv("DB_NAME"),
+		os.Getenv("FMP_API_KEY"),
+	)
+
+	// DATA_QUALITY_INTERVAL schedules the price gap scan and backfills, e.g. 24h
+	if raw := os.Getenv("DATA_QUALITY_INTERVAL"); raw != "" {
+		interval, err := time.ParseDuration(raw)
+		if err != nil || interval <= 0 {
+			log.Fatalf("Invalid DATA_QUALITY_INTERVAL %q", raw)
+		}
+		go app.runDataQuality(interval)
+	}
 
 	port := os.Getenv("PORT")
 	if port == "" {
@@ -0,0 +1,133 @@
+package analyst
+
+import (
+	"math"
+	"time"
+
+	"stockpick-backend/pkg/models"
+)
+
+// FullCoverageAnalysts is the analyst count at which the consensus gets full weight
+const FullCoverageAnalysts = 10
+
+// MinCountWeight is the weight of a consensus backed by a single analyst
+const MinCountWeight = 0.25
+
+// snapshotTolerance is how much older than the lookback date a snapshot may be
+const snapshotTolerance = 15 * 24 * time.Hour
+
+// ratingChangeThreshold is the move in consensus rating value counted as an upgrade or downgrade
+const ratingChangeThreshold = 0.1
+
+// Factors summarize how the analyst consensus is moving and how much it can be trusted.
+// Changes are nil when no snapshot close to the lookback date is stored.
+type Factors struct {
+	Date            time.Time `json:"date"`              // Date of the latest consensus
+	TargetChange30D *float64  `json:"target_change_30d"` // Relative change of the consensus target
+	TargetChange90D *float64  `json:"target_change_90d"`
+	RatingChange90D *float64  `json:"rating_change_90d"` // Change of the consensus rating value, positive is better
+	Upgrades        int       `json:"upgrades"`          // Rating value increases between snapshots in the last 90 days
+	Downgrades      int       `json:"downgrades"`
+	Dispersion      *float64  `json:"dispersion"`    // (high - low) / consensus target
+	AnalystCount    int       `json:"analyst_count"` // 0 when unknown
+	CountWeight     float64   `json:"count_weight"`  // 0-1 confidence in the consensus from the analyst count
+}
+
+// Compute derives the factors from consensus snapshots, newest first. It returns false
+// without any snapshot.
+func Compute(targets []models.AnalystTarget) (Factors, bool) {
+	if len(targets) == 0 {
+		return Factors{}, false
+	}
+	latest := targets[0]
+	f := Factors{Date: latest.Date}
+
+	if prior, ok := snapshotBefore(targets, latest.Date.AddDate(0, 0, -30)); ok {
+		f.TargetChange30D = relativeChange(latest.ConsensusPriceTarget, prior.ConsensusPriceTarget)
+	}
+	if prior, ok := snapshotBefore(targets, latest.Date.AddDate(0, 0, -90)); ok {
+		f.TargetChange90D = relativeChange(latest.ConsensusPriceTarget, prior.ConsensusPriceTarget)
+		if latest.ConsensusRatingValue > 0 && prior.ConsensusRatingValue > 0 {
+			change := latest.ConsensusRatingValue - prior.ConsensusRatingValue
+			f.RatingChange90D = &change
+		}
+	}
+
+	// Count rating moves between consecutive snapshots of the last 90 days
+	since := latest.Date.AddDate(0, 0, -90)
+	for i := 0; i+1 < len(targets) && !targets[i+1].Date.Before(since); i++ {
+		newer, older := targets[i].ConsensusRatingValue, targets[i+1].ConsensusRatingValue
+		if newer <= 0 || older <= 0 {
+			continue
+		}
+		if newer-older >= ratingChangeThreshold {
+			f.Upgrades++
+		} else if older-newer >= ratingChangeThreshold {
+			f.Downgrades++
+		}
+	}
+
+	if latest.ConsensusPriceTarget > 0 && latest.HighPriceTarget >= latest.LowPriceTarget && latest.LowPriceTarget > 0 {
+		d := (latest.HighPriceTarget - latest.LowPriceTarget) / latest.ConsensusPriceTarget
+		f.Dispersion = &d
+	}
+
+	f.AnalystCount = latest.TotalAnalystsContributing
+	if f.AnalystCount == 0 {
+		f.AnalystCount = latest.BuyRatingsCount + latest.HoldRatingsCount + latest.SellRatingsCount
+	}
+	f.CountWeight = CountWeight(f.AnalystCount)
+	return f, true
+}
+
+// CountWeight scales confidence in a consensus with the number of analysts behind it.
+// An unknown count (0) is not penalized.
+func CountWeight(count int) float64 {
+	if count <= 0 {
+		return 1
+	}
+	return math.Max(MinCountWeight, math.Min(1, float64(count)/FullCoverageAnalysts))
+}
+
+// UncertaintyPenalty is the share of the analyst score forfeited for wide disagreement
+// between the highest and lowest targets: nothing up to a dispersion of 0.5, at most 0.3.
+func (f Factors) UncertaintyPenalty() float64 {
+	if f.Dispersion == nil {
+		return 0
+	}
+	return math.Min(math.Max(*f.Dispersion-0.5, 0)*0.5, 0.3)
+}
+
+// TargetRevision returns the 90-day consensus target change, falling back to 30 days
+func (f Factors) TargetRevision() (float64, bool) {
+	if f.TargetChange90D != nil {
+		return *f.TargetChange90D, true
+	}
+	if f.TargetChange30D != nil {
+		return *f.TargetChange30D, true
+	}
+	return 0, false
+}
+
+// snapshotBefore returns the newest snapshot on or before the date, provided it is not
+// much older than the date
+func snapshotBefore(targets []models.AnalystTarget, date time.Time) (models.AnalystTarget, bool) {
+	for _, t := range targets {
+		if t.Date.After(date) {
+			continue
+		}
+		if date.Sub(t.Date) > snapshotTolerance {
+			break
+		}
+		return t, true
+	}
+	return models.AnalystTarget{}, false
+}
+
+func relativeChange(current, prior float64) *float64 {
+	if current <= 0 || prior <= 0 {
+		return nil
+	}
+	change := current/prior - 1
+	return &change
+}
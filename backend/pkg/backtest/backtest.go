@@ -0,0 +1,456 @@
+package backtest
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"time"
+
+	"stockpick-backend/pkg/fundamentals"
+	"stockpick-backend/pkg/models"
+	"stockpick-backend/pkg/undervaluation"
+)
+
+// Portfolio construction methods
+const (
+	MethodTopN          = "top_n"          // The TopN highest scores, equally weighted
+	MethodThreshold     = "threshold"      // Every stock scoring at least Threshold, equally weighted
+	MethodSectorNeutral = "sector_neutral" // Best scores per sector, sectors weighted as in the universe
+)
+
+// Rebalance schedules
+const (
+	RebalanceWeekly    = "weekly"
+	RebalanceMonthly   = "monthly"
+	RebalanceQuarterly = "quarterly"
+	RebalanceAnnually  = "annually"
+)
+
+// Scoring modes
+const (
+	ModeAbsolute = "absolute"
+	ModeRelative = "relative"
+)
+
+// Config describes a backtest
+type Config struct {
+	Start        time.Time `json:"start"`
+	End          time.Time `json:"end"`
+	Rebalance    string    `json:"rebalance"`
+	Method       string    `json:"method"`
+	TopN         int       `json:"top_n"`
+	Threshold    float64   `json:"threshold"`
+	CostBps      float64   `json:"cost_bps"` // Transaction cost per unit of traded weight, in basis points
+	Mode         string    `json:"mode"`     // "absolute" or "relative" scoring
+	GroupBy      string    `json:"group_by"` // Peer grouping of relative scoring
+	Fundamentals string    `json:"fundamentals"`
+	RiskFreeRate float64   `json:"risk_free_rate"` // Annual, for the Sharpe ratio
+}
+
+// DefaultConfig returns a monthly top-20 backtest over the last five years
+func DefaultConfig() Config {
+	end := time.Now().UTC().Truncate(24 * time.Hour)
+	return Config{
+		Start:        end.AddDate(-5, 0, 0),
+		End:          end,
+		Rebalance:    RebalanceMonthly,
+		Method:       MethodTopN,
+		TopN:         20,
+		Threshold:    50,
+		CostBps:      10,
+		Mode:         ModeAbsolute,
+		GroupBy:      undervaluation.GroupSector,
+		Fundamentals: fundamentals.TTMPeriod,
+	}
+}
+
+// Validate checks the configuration
+func (c Config) Validate() error {
+	if !c.End.After(c.Start) {
+		return fmt.Errorf("end must be after start")
+	}
+	switch c.Rebalance {
+	case RebalanceWeekly, RebalanceMonthly, RebalanceQuarterly, RebalanceAnnually:
+	default:
+		return fmt.Errorf("rebalance must be weekly, monthly, quarterly or annually")
+	}
+	switch c.Method {
+	case MethodTopN, MethodSectorNeutral:
+		if c.TopN <= 0 {
+			return fmt.Errorf("top_n must be positive")
+		}
+	case MethodThreshold:
+	default:
+		return fmt.Errorf("method must be top_n, threshold or sector_neutral")
+	}
+	if c.Mode != ModeAbsolute && c.Mode != ModeRelative {
+		return fmt.Errorf("mode must be absolute or relative")
+	}
+	if c.Mode == ModeRelative && c.GroupBy != undervaluation.GroupSector && c.GroupBy != undervaluation.GroupIndustry {
+		return fmt.Errorf("group_by must be sector or industry")
+	}
+	if c.Fundamentals != "annual" && c.Fundamentals != fundamentals.TTMPeriod {
+		return fmt.Errorf("fundamentals must be annual or ttm")
+	}
+	if c.CostBps < 0 {
+		return fmt.Errorf("cost_bps must not be negative")
+	}
+	return nil
+}
+
+// Holding is a position taken at a rebalance
+type Holding struct {
+	Symbol string  `json:"symbol"`
+	Sector string  `json:"sector"`
+	Weight float64 `json:"weight"`
+	Score  float64 `json:"score"`
+}
+
+// Rebalance records the portfolio formed on a rebalance day
+type Rebalance struct {
+	Date     time.Time `json:"date"`
+	Scored   int       `json:"scored"` // Stocks with a score that day
+	Holdings []Holding `json:"holdings"`
+	Turnover float64   `json:"turnover"` // One-way share of the portfolio traded
+	Cost     float64   `json:"cost"`     // Share of the portfolio value paid in costs
+}
+
+// Point is the value of the portfolio and benchmark on a day, both starting at 1
+type Point struct {
+	Date      time.Time `json:"date"`
+	Value     float64   `json:"value"`
+	Benchmark *float64  `json:"benchmark,omitempty"`
+}
+
+// Result is the outcome of a backtest
+type Result struct {
+	Config     Config      `json:"config"`
+	Metrics    Metrics     `json:"metrics"`
+	Benchmark  *Metrics    `json:"benchmark,omitempty"`
+	ExcessCAGR *float64    `json:"excess_cagr,omitempty"`
+	HitRate    *float64    `json:"hit_rate"` // Share of positions that beat the benchmark (or 0) while held
+	Equity     []Point     `json:"equity"`
+	Rebalances []Rebalance `json:"rebalances"`
+}
+
+// position is an open holding during the replay
+type position struct {
+	value      float64 // Current value as a share of the starting capital
+	entryPrice float64
+}
+
+// Run replays the dataset day by day. On each rebalance day every stock is scored on
+// point-in-time data and the portfolio is re-formed, paying costs on the traded weight.
+// Between rebalances holdings drift with their daily close-to-close returns.
+func Run(ds *Dataset, cfg Config) (*Result, error) {
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+	days := ds.TradingDays(cfg.Start, cfg.End)
+	if len(days) < 2 {
+		return nil, fmt.Errorf("not enough price history between %s and %s", cfg.Start.Format("2006-01-02"), cfg.End.Format("2006-01-02"))
+	}
+
+	closes := make([]map[string]float64, len(ds.Stocks))
+	for i, s := range ds.Stocks {
+		closes[i] = closeMap(s.Prices)
+	}
+	var benchCloses map[string]float64
+	if ds.Benchmark != nil {
+		benchCloses = closeMap(ds.Benchmark.Prices)
+	}
+
+	last := make([]float64, len(ds.Stocks)) // Last known close of each stock
+	for i, s := range ds.Stocks {
+		for _, p := range s.Prices {
+			if p.Time.Before(days[0]) {
+				last[i] = p.ClosePrice
+			}
+		}
+	}
+	benchLast, benchStart, benchEntry := 0.0, 0.0, 0.0
+
+	result := &Result{Config: cfg}
+	positions := map[int]*position{}
+	cash := 1.0
+	var hits, trials int
+	// closePeriod scores the holding period ending now for the hit rate
+	closePeriod := func() {
+		for i, p := range positions {
+			if p.entryPrice <= 0 || last[i] <= 0 {
+				continue
+			}
+			benchReturn := 0.0
+			if benchEntry > 0 && benchLast > 0 {
+				benchReturn = benchLast/benchEntry - 1
+			}
+			trials++
+			if last[i]/p.entryPrice-1 > benchReturn {
+				hits++
+			}
+		}
+	}
+
+	for d, day := range days {
+		for i := range ds.Stocks {
+			if c, ok := closes[i][dayKey(day)]; ok && c > 0 {
+				if p := positions[i]; p != nil && last[i] > 0 {
+					p.value *= c / last[i]
+				}
+				last[i] = c
+			}
+		}
+		if c, ok := benchCloses[dayKey(day)]; ok && c > 0 {
+			benchLast = c
+			if benchStart == 0 {
+				benchStart = c
+			}
+		}
+
+		if d == 0 || NewPeriod(days[d-1], day, cfg.Rebalance) {
+			closePeriod()
+
+			reb, err := rebalance(ds, cfg, day)
+			if err != nil {
+				return nil, err
+			}
+
+			nav := cash
+			for _, p := range positions {
+				nav += p.value
+			}
+			target := map[int]float64{}
+			for _, h := range reb.holdings {
+				target[h.index] = h.Weight
+			}
+			traded := 0.0 // Buys plus sells as a share of the portfolio
+			for i, p := range positions {
+				traded += math.Abs(target[i] - p.value/nav)
+			}
+			for i, w := range target {
+				if positions[i] == nil {
+					traded += w
+				}
+			}
+			cost := traded * cfg.CostBps / 10000
+			nav *= 1 - cost
+
+			positions = map[int]*position{}
+			cash = nav
+			for i, w := range target {
+				positions[i] = &position{value: nav * w, entryPrice: last[i]}
+				cash -= nav * w
+			}
+			benchEntry = benchLast
+
+			reb.Rebalance.Date = day
+			reb.Rebalance.Turnover = traded / 2
+			reb.Rebalance.Cost = cost
+			result.Rebalances = append(result.Rebalances, reb.Rebalance)
+		}
+
+		value := cash
+		for _, p := range positions {
+			value += p.value
+		}
+		point := Point{Date: day, Value: value}
+		if benchStart > 0 {
+			b := benchLast / benchStart
+			point.Benchmark = &b
+		}
+		result.Equity = append(result.Equity, point)
+	}
+
+	closePeriod()
+	if trials > 0 {
+		rate := float64(hits) / float64(trials)
+		result.HitRate = &rate
+	}
+
+	values := make([]float64, len(result.Equity))
+	for i, p := range result.Equity {
+		values[i] = p.Value
+	}
+	result.Metrics = computeMetrics(days, values, cfg.RiskFreeRate)
+	result.Metrics.Turnover = annualTurnover(result.Rebalances, days)
+
+	if benchStart > 0 {
+		bench := make([]float64, len(result.Equity))
+		for i, p := range result.Equity {
+			bench[i] = 1
+			if p.Benchmark != nil {
+				bench[i] = *p.Benchmark
+			}
+		}
+		m := computeMetrics(days, bench, cfg.RiskFreeRate)
+		result.Benchmark = &m
+		excess := result.Metrics.CAGR - m.CAGR
+		result.ExcessCAGR = &excess
+	}
+	return result, nil
+}
+
+// selection is a rebalance's portfolio with the dataset index of every holding
+type selection struct {
+	Rebalance
+	holdings []indexedHolding
+}
+
+type indexedHolding struct {
+	Holding
+	index int
+}
+
+// rebalance scores the universe on the day and forms the target portfolio
+func rebalance(ds *Dataset, cfg Config, day time.Time) (selection, error) {
+	var universe []undervaluation.Inputs
+	var indexes []int
+	for i := range ds.Stocks {
+		in, ok := ds.Stocks[i].InputsAt(day, cfg.Fundamentals)
+		if !ok {
+			continue
+		}
+		universe = append(universe, in)
+		indexes = append(indexes, i)
+	}
+
+	var candidates []indexedHolding
+	if cfg.Mode == ModeRelative && len(universe) > 0 {
+		scores, err := undervaluation.CalculateRelative(universe, cfg.GroupBy)
+		if err != nil {
+			return selection{}, err
+		}
+		for j, s := range scores {
+			if s != nil {
+				candidates = append(candidates, candidate(ds, indexes[j], s.Score))
+			}
+		}
+	} else {
+		for j, in := range universe {
+			s, err := undervaluation.Calculate(in)
+			if err != nil {
+				continue
+			}
+			candidates = append(candidates, candidate(ds, indexes[j], s.Score))
+		}
+	}
+	sort.SliceStable(candidates, func(i, j int) bool { return candidates[i].Score > candidates[j].Score })
+
+	var picked []indexedHolding
+	switch cfg.Method {
+	case MethodTopN:
+		picked = candidates
+		if len(picked) > cfg.TopN {
+			picked = picked[:cfg.TopN]
+		}
+		equalWeight(picked, 1)
+	case MethodThreshold:
+		for _, c := range candidates {
+			if c.Score >= cfg.Threshold {
+				picked = append(picked, c)
+			}
+		}
+		equalWeight(picked, 1)
+	case MethodSectorNeutral:
+		picked = sectorNeutral(candidates, cfg.TopN)
+	}
+
+	sel := selection{Rebalance: Rebalance{Scored: len(candidates), Holdings: []Holding{}}, holdings: picked}
+	for _, h := range picked {
+		sel.Rebalance.Holdings = append(sel.Rebalance.Holdings, h.Holding)
+	}
+	return sel, nil
+}
+
+func candidate(ds *Dataset, index int, score float64) indexedHolding {
+	s := ds.Stocks[index].Stock
+	return indexedHolding{Holding: Holding{Symbol: s.Symbol, Sector: s.Sector, Score: score}, index: index}
+}
+
+// sectorNeutral gives every sector its share of the scored universe and fills it with the
+// sector's best scores, about topN holdings in total. Candidates are sorted by score.
+func sectorNeutral(candidates []indexedHolding, topN int) []indexedHolding {
+	bySector := map[string][]indexedHolding{}
+	var sectors []string
+	for _, c := range candidates {
+		if _, ok := bySector[c.Sector]; !ok {
+			sectors = append(sectors, c.Sector)
+		}
+		bySector[c.Sector] = append(bySector[c.Sector], c)
+	}
+
+	var picked []indexedHolding
+	for _, sector := range sectors {
+		members := bySector[sector]
+		share := float64(len(members)) / float64(len(candidates))
+		n := int(math.Max(1, math.Round(share*float64(topN))))
+		if n > len(members) {
+			n = len(members)
+		}
+		chosen := append([]indexedHolding(nil), members[:n]...)
+		equalWeight(chosen, share)
+		picked = append(picked, chosen...)
+	}
+	return picked
+}
+
+func equalWeight(holdings []indexedHolding, total float64) {
+	for i := range holdings {
+		holdings[i].Weight = total / float64(len(holdings))
+	}
+}
+
+// TradingDays lists the dates with a close between start and end, from the benchmark
+// when there is one and otherwise from any stock
+func (ds *Dataset) TradingDays(start, end time.Time) []time.Time {
+	seen := map[string]time.Time{}
+	add := func(s *Series) {
+		for _, p := range s.Prices {
+			if !p.Time.Before(start) && !p.Time.After(end) {
+				seen[dayKey(p.Time)] = p.Time
+			}
+		}
+	}
+	if ds.Benchmark != nil && len(ds.Benchmark.Prices) > 0 {
+		add(ds.Benchmark)
+	} else {
+		for i := range ds.Stocks {
+			add(&ds.Stocks[i])
+		}
+	}
+
+	days := make([]time.Time, 0, len(seen))
+	for _, d := range seen {
+		days = append(days, d)
+	}
+	sort.Slice(days, func(i, j int) bool { return days[i].Before(days[j]) })
+	return days
+}
+
+func closeMap(prices []models.HistoricalPrice) map[string]float64 {
+	m := make(map[string]float64, len(prices))
+	for _, p := range prices {
+		m[dayKey(p.Time)] = p.ClosePrice
+	}
+	return m
+}
+
+// dayKey identifies a trading day independently of the time's location
+func dayKey(t time.Time) string {
+	return t.Format("2006-01-02")
+}
+
+// NewPeriod reports whether day starts a new period of the rebalance schedule after prev
+func NewPeriod(prev, day time.Time, schedule string) bool {
+	switch schedule {
+	case RebalanceWeekly:
+		py, pw := prev.ISOWeek()
+		y, w := day.ISOWeek()
+		return py != y || pw != w
+	case RebalanceQuarterly:
+		return prev.Year() != day.Year() || (int(prev.Month())-1)/3 != (int(day.Month())-1)/3
+	case RebalanceAnnually:
+		return prev.Year() != day.Year()
+	}
+	return prev.Year() != day.Year() || prev.Month() != day.Month()
+}
@@ -0,0 +1,197 @@
+package backtest
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/google/uuid"
+
+	"stockpick-backend/pkg/estimates"
+	"stockpick-backend/pkg/fundamentals"
+	"stockpick-backend/pkg/growth"
+	"stockpick-backend/pkg/models"
+	"stockpick-backend/pkg/quality"
+	"stockpick-backend/pkg/ratios"
+	"stockpick-backend/pkg/undervaluation"
+)
+
+// sentimentLookback matches the window the live scoring reads sentiment over
+const sentimentLookback = 14 * 24 * time.Hour
+
+// DefaultBenchmark is the benchmark symbol when none is given
+const DefaultBenchmark = "SPY"
+
+// ErrUnknownSymbol is returned by Load for symbols that are not tracked
+var ErrUnknownSymbol = errors.New("unknown symbol")
+
+// Store is the data access the backtester needs; *database.DB satisfies it
+type Store interface {
+	GetAllStocks() ([]models.Stock, error)
+	GetStockBySymbol(symbol string) (*models.Stock, error)
+	GetHistoricalPrices(stockID uuid.UUID, from, to time.Time) ([]models.HistoricalPrice, error)
+	GetAdjustedHistoricalPrices(stockID uuid.UUID, from, to time.Time, withDividends bool) ([]models.HistoricalPrice, error)
+	GetFinancialStatementRevisions(stockID uuid.UUID, period string) ([]models.FinancialStatement, error)
+	GetAnalystTargets(stockID uuid.UUID) ([]models.AnalystTarget, error)
+	GetSentimentScores(stockID uuid.UUID, from, to time.Time, source string) ([]models.SentimentScore, error)
+	GetAnalystEstimates(stockID uuid.UUID, period string) ([]models.AnalystEstimate, error)
+}
+
+// Series is the full history of one stock
+type Series struct {
+	Stock     models.Stock
+	Prices    []models.HistoricalPrice    // Adjusted for splits and dividends, oldest first, for returns
+	Traded    []models.HistoricalPrice    // As traded, oldest first, for valuation against statements
+	Annual    []models.FinancialStatement // Every revision, newest first
+	Quarterly []models.FinancialStatement // Every revision, newest first
+	Targets   []models.AnalystTarget      // Newest first
+	Sentiment []models.SentimentScore     // Oldest first
+	Estimates []models.AnalystEstimate    // Annual snapshots
+}
+
+// Dataset is the history a backtest replays
+type Dataset struct {
+	Stocks    []Series
+	Benchmark *Series // Prices only, nil without a benchmark
+}
+
+// Load reads the history of the given symbols (every tracked stock when empty) and of
+// the benchmark between from and to. Returns are computed on prices adjusted for splits and
+// dividends, valuations on prices as traded, which match the per-share figures reported at
+// the time. A year of earlier prices is loaded so price-based inputs are available from the
+// first day.
+func Load(store Store, symbols []string, benchmark string, from, to time.Time) (*Dataset, error) {
+	var stocks []models.Stock
+	if len(symbols) == 0 {
+		all, err := store.GetAllStocks()
+		if err != nil {
+			return nil, err
+		}
+		stocks = all
+	} else {
+		for _, symbol := range symbols {
+			stock, err := store.GetStockBySymbol(symbol)
+			if err != nil {
+				return nil, err
+			}
+			if stock == nil {
+				return nil, fmt.Errorf("%w %s", ErrUnknownSymbol, symbol)
+			}
+			stocks = append(stocks, *stock)
+		}
+	}
+
+	ds := &Dataset{}
+	priceFrom := from.AddDate(-1, 0, 0)
+	for _, stock := range stocks {
+		if stock.Symbol == benchmark {
+			continue
+		}
+		s := Series{Stock: stock}
+		var err error
+		if s.Prices, err = store.GetAdjustedHistoricalPrices(stock.StockID, priceFrom, to, true); err != nil {
+			return nil, fmt.Errorf("failed to load prices of %s: %w", stock.Symbol, err)
+		}
+		if len(s.Prices) == 0 {
+			continue
+		}
+		if s.Traded, err = store.GetHistoricalPrices(stock.StockID, priceFrom, to); err != nil {
+			return nil, fmt.Errorf("failed to load traded prices of %s: %w", stock.Symbol, err)
+		}
+		if s.Annual, err = store.GetFinancialStatementRevisions(stock.StockID, "annual"); err != nil {
+			return nil, fmt.Errorf("failed to load annual statements of %s: %w", stock.Symbol, err)
+		}
+		if s.Quarterly, err = store.GetFinancialStatementRevisions(stock.StockID, "quarterly"); err != nil {
+			return nil, fmt.Errorf("failed to load quarterly statements of %s: %w", stock.Symbol, err)
+		}
+		if s.Targets, err = store.GetAnalystTargets(stock.StockID); err != nil {
+			return nil, fmt.Errorf("failed to load analyst targets of %s: %w", stock.Symbol, err)
+		}
+		if s.Sentiment, err = store.GetSentimentScores(stock.StockID, from.Add(-sentimentLookback), to, ""); err != nil {
+			return nil, fmt.Errorf("failed to load sentiment of %s: %w", stock.Symbol, err)
+		}
+		if s.Estimates, err = store.GetAnalystEstimates(stock.StockID, "annual"); err != nil {
+			return nil, fmt.Errorf("failed to load analyst estimates of %s: %w", stock.Symbol, err)
+		}
+		ds.Stocks = append(ds.Stocks, s)
+	}
+
+	if benchmark != "" {
+		stock, err := store.GetStockBySymbol(benchmark)
+		if err != nil {
+			return nil, err
+		}
+		if stock == nil {
+			return nil, fmt.Errorf("%w %s (benchmark)", ErrUnknownSymbol, benchmark)
+		}
+		prices, err := store.GetAdjustedHistoricalPrices(stock.StockID, priceFrom, to, true)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load benchmark prices: %w", err)
+		}
+		ds.Benchmark = &Series{Stock: *stock, Prices: prices}
+	}
+	return ds, nil
+}
+
+// InputsAt builds the scoring inputs of a stock using only data available at the close
+// of the given day: statements are used once known and restated periods in the revision
+// current then. Adjusted prices reflect splits and dividends after that day, so ratios
+// use the close as traded and only the price history is adjusted. It returns false when
+// the stock has no price by then.
+func (s *Series) InputsAt(at time.Time, fundamentalsPeriod string) (undervaluation.Inputs, bool) {
+	n := sort.Search(len(s.Prices), func(i int) bool { return s.Prices[i].Time.After(at) })
+	traded := sort.Search(len(s.Traded), func(i int) bool { return s.Traded[i].Time.After(at) })
+	if n == 0 || traded == 0 {
+		return undervaluation.Inputs{}, false
+	}
+	latest := s.Traded[traded-1]
+	yearAgo := sort.Search(n, func(i int) bool { return !s.Prices[i].Time.Before(at.AddDate(-1, 0, 0)) })
+
+	annual := fundamentals.AsOf(s.Annual, at)
+	statements := annual
+	if fundamentalsPeriod == fundamentals.TTMPeriod {
+		if ttm, ok := fundamentals.TTM(fundamentals.AsOf(s.Quarterly, at)); ok {
+			statements = []models.FinancialStatement{ttm}
+		}
+	}
+
+	currentPE := 0.0
+	if len(statements) > 0 {
+		statements = append([]models.FinancialStatement(nil), statements...)
+		ratios.Compute(statements[0], latest.ClosePrice).ApplyTo(&statements[0])
+		currentPE = statements[0].PERatio
+	}
+	growthMetrics := growth.Compute(annual, currentPE)
+	qualityScores := quality.Compute(annual, latest.ClosePrice)
+
+	in := undervaluation.Inputs{
+		Stock:               &s.Stock,
+		LatestPrice:         latest.ClosePrice,
+		LatestPriceTime:     latest.Time,
+		Prices:              s.Prices[yearAgo:n],
+		AsOf:                at,
+		FinancialStatements: statements,
+		Growth:              &growthMetrics,
+		Quality:             &qualityScores,
+	}
+
+	for i, t := range s.Targets {
+		if !t.Date.After(at) {
+			in.AnalystTargets = s.Targets[i:]
+			break
+		}
+	}
+	for _, sc := range s.Sentiment {
+		if sc.Timestamp.After(at) {
+			break
+		}
+		if !sc.Timestamp.Before(at.Add(-sentimentLookback)) {
+			in.SentimentScores = append(in.SentimentScores, sc)
+		}
+	}
+	if m, ok := estimates.Compute(s.Estimates, annual, latest.ClosePrice, at); ok {
+		in.Estimates = &m
+	}
+	return in, true
+}
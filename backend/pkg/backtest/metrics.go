@@ -0,0 +1,71 @@
+package backtest
+
+import (
+	"math"
+	"time"
+
+	"stockpick-backend/pkg/risk"
+)
+
+// Metrics summarize the performance of an equity curve
+type Metrics struct {
+	TotalReturn float64 `json:"total_return"`
+	CAGR        float64 `json:"cagr"`
+	Volatility  float64 `json:"volatility"` // Annualized standard deviation of daily returns
+	Sharpe      float64 `json:"sharpe"`
+	MaxDrawdown float64 `json:"max_drawdown"` // Largest peak-to-trough decline, as a positive fraction
+	Turnover    float64 `json:"turnover"`     // Annualized one-way turnover, portfolio only
+}
+
+// computeMetrics derives performance metrics from daily values starting at 1
+func computeMetrics(days []time.Time, values []float64, riskFreeRate float64) Metrics {
+	var m Metrics
+	if len(values) < 2 {
+		return m
+	}
+	end := values[len(values)-1]
+	m.TotalReturn = end - 1
+	if years := years(days); years > 0 && end > 0 {
+		m.CAGR = math.Pow(end, 1/years) - 1
+	}
+
+	var returns []float64
+	peak := values[0]
+	for i := 1; i < len(values); i++ {
+		if values[i-1] > 0 {
+			returns = append(returns, values[i]/values[i-1]-1)
+		}
+		if values[i] > peak {
+			peak = values[i]
+		}
+		if peak > 0 {
+			m.MaxDrawdown = math.Max(m.MaxDrawdown, 1-values[i]/peak)
+		}
+	}
+
+	mean, std := risk.MeanStd(returns)
+	m.Volatility = std * math.Sqrt(risk.TradingDaysPerYear)
+	if std > 0 {
+		m.Sharpe = (mean - riskFreeRate/risk.TradingDaysPerYear) / std * math.Sqrt(risk.TradingDaysPerYear)
+	}
+	return m
+}
+
+// annualTurnover is the one-way turnover per year across all rebalances
+func annualTurnover(rebalances []Rebalance, days []time.Time) float64 {
+	total := 0.0
+	for _, r := range rebalances {
+		total += r.Turnover
+	}
+	if y := years(days); y > 0 {
+		return total / y
+	}
+	return total
+}
+
+func years(days []time.Time) float64 {
+	if len(days) < 2 {
+		return 0
+	}
+	return days[len(days)-1].Sub(days[0]).Hours() / 24 / 365.25
+}
@@ -0,0 +1,258 @@
+// Package calendar knows when exchanges hold trading sessions. US equity exchanges follow
+// the NYSE holiday rules, including early closes and unscheduled closures; other exchanges
+// use calendars observed from the stored price history. The US rules are complete from 2001;
+// earlier years apply the current holiday rules and miss unscheduled closures.
+package calendar
+
+import (
+	"log"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Calendar tells whether an exchange trades on a day. Days are calendar dates, identified
+// by their year, month and day whatever the time's location.
+type Calendar interface {
+	Name() string
+	// Session returns the session held on a day, false when the exchange is closed
+	Session(day time.Time) (Session, bool)
+}
+
+// Session is one trading session of an exchange
+type Session struct {
+	Date       time.Time `json:"date"` // Midnight UTC of the trading day, like daily bars
+	Open       time.Time `json:"open"`
+	Close      time.Time `json:"close"`
+	EarlyClose bool      `json:"early_close"`
+}
+
+// Holiday is a full-day closure
+type Holiday struct {
+	Date time.Time `json:"date"`
+	Name string    `json:"name"`
+}
+
+// Sessions lists the sessions of a calendar between two days, both included
+func Sessions(c Calendar, from, to time.Time) []Session {
+	var sessions []Session
+	for day := Date(from); !day.After(Date(to)); day = day.AddDate(0, 0, 1) {
+		if s, ok := c.Session(day); ok {
+			sessions = append(sessions, s)
+		}
+	}
+	return sessions
+}
+
+// Date returns the calendar day of a time as midnight UTC
+func Date(t time.Time) time.Time {
+	y, m, d := t.Date()
+	return time.Date(y, m, d, 0, 0, 0, 0, time.UTC)
+}
+
+// key identifies a calendar day
+func key(t time.Time) string {
+	return t.Format("2006-01-02")
+}
+
+// easternTime is the time zone of US exchange sessions
+var easternTime = func() *time.Location {
+	loc, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		log.Printf("Could not load US Eastern time zone, using UTC: %v", err)
+		return time.UTC
+	}
+	return loc
+}()
+
+// usCalendar applies the NYSE rules, which NASDAQ and the other US equity venues share
+type usCalendar struct {
+	name string
+}
+
+// NYSE and NASDAQ are the calendars of the major US exchanges
+var (
+	NYSE   Calendar = usCalendar{name: "NYSE"}
+	NASDAQ Calendar = usCalendar{name: "NASDAQ"}
+)
+
+// US session hours, Eastern time
+const (
+	openHour, openMinute = 9, 30
+	closeHour            = 16
+	earlyCloseHour       = 13
+)
+
+// closures are unscheduled full-day closures of US exchanges since 2001
+var closures = map[string]string{
+	"2001-09-11": "September 11 attacks",
+	"2001-09-12": "September 11 attacks",
+	"2001-09-13": "September 11 attacks",
+	"2001-09-14": "September 11 attacks",
+	"2004-06-11": "National Day of Mourning for Ronald Reagan",
+	"2007-01-02": "National Day of Mourning for Gerald Ford",
+	"2012-10-29": "Hurricane Sandy",
+	"2012-10-30": "Hurricane Sandy",
+	"2018-12-05": "National Day of Mourning for George H. W. Bush",
+	"2025-01-09": "National Day of Mourning for Jimmy Carter",
+}
+
+func (c usCalendar) Name() string { return c.name }
+
+func (c usCalendar) Session(day time.Time) (Session, bool) {
+	day = Date(day)
+	if day.Weekday() == time.Saturday || day.Weekday() == time.Sunday {
+		return Session{}, false
+	}
+	if _, closed := closures[key(day)]; closed {
+		return Session{}, false
+	}
+	for _, h := range USHolidays(day.Year()) {
+		if h.Date.Equal(day) {
+			return Session{}, false
+		}
+	}
+
+	y, m, d := day.Date()
+	s := Session{
+		Date:       day,
+		Open:       time.Date(y, m, d, openHour, openMinute, 0, 0, easternTime),
+		Close:      time.Date(y, m, d, closeHour, 0, 0, 0, easternTime),
+		EarlyClose: usEarlyClose(day),
+	}
+	if s.EarlyClose {
+		s.Close = time.Date(y, m, d, earlyCloseHour, 0, 0, 0, easternTime)
+	}
+	return s, true
+}
+
+// USHolidays lists the scheduled full-day holidays of US exchanges in a year, as observed:
+// holidays on a Saturday move to the Friday before and holidays on a Sunday to the Monday
+// after, except that New Year's Day on a Saturday is not observed.
+func USHolidays(year int) []Holiday {
+	var holidays []Holiday
+	add := func(name string, date time.Time) {
+		holidays = append(holidays, Holiday{Date: date, Name: name})
+	}
+
+	if newYear := date(year, time.January, 1); newYear.Weekday() != time.Saturday {
+		add("New Year's Day", observed(newYear))
+	}
+	if year >= 1998 {
+		add("Martin Luther King Jr. Day", nthWeekday(year, time.January, time.Monday, 3))
+	}
+	add("Washington's Birthday", nthWeekday(year, time.February, time.Monday, 3))
+	add("Good Friday", easter(year).AddDate(0, 0, -2))
+	add("Memorial Day", lastWeekday(year, time.May, time.Monday))
+	if year >= 2022 {
+		add("Juneteenth", observed(date(year, time.June, 19)))
+	}
+	add("Independence Day", observed(date(year, time.July, 4)))
+	add("Labor Day", nthWeekday(year, time.September, time.Monday, 1))
+	add("Thanksgiving Day", nthWeekday(year, time.November, time.Thursday, 4))
+	add("Christmas Day", observed(date(year, time.December, 25)))
+
+	sort.Slice(holidays, func(i, j int) bool { return holidays[i].Date.Before(holidays[j].Date) })
+	return holidays
+}
+
+// usEarlyClose reports the 1 p.m. closes: the day before Independence Day when July 4 falls
+// Tuesday to Friday, the day after Thanksgiving and Christmas Eve on a weekday
+func usEarlyClose(day time.Time) bool {
+	y, m, d := day.Date()
+	switch {
+	case m == time.July && d == 3:
+		wd := date(y, time.July, 4).Weekday()
+		return wd >= time.Tuesday && wd <= time.Friday
+	case m == time.November && day.Weekday() == time.Friday:
+		return day.AddDate(0, 0, -1).Equal(nthWeekday(y, time.November, time.Thursday, 4))
+	case m == time.December && d == 24:
+		return day.Weekday() != time.Saturday && day.Weekday() != time.Sunday
+	}
+	return false
+}
+
+// ForExchange returns the calendar of an exchange as stored on stocks, e.g. "NASDAQ Global
+// Select" or "New York Stock Exchange". It returns false for exchanges without rules.
+func ForExchange(exchange string) (Calendar, bool) {
+	e := strings.ToUpper(exchange)
+	switch {
+	case strings.Contains(e, "NASDAQ"):
+		return NASDAQ, true
+	case strings.Contains(e, "NYSE"), strings.Contains(e, "NEW YORK STOCK EXCHANGE"),
+		strings.Contains(e, "AMEX"), strings.Contains(e, "BATS"), strings.Contains(e, "CBOE"):
+		return NYSE, true
+	}
+	return nil, false
+}
+
+// observedCalendar holds the sessions seen in price data
+type observedCalendar struct {
+	name     string
+	sessions map[string]bool
+}
+
+// Observed is a data-driven calendar whose sessions are the given trading days, for
+// exchanges without rules. Session times are unknown and left at the day's bounds in UTC.
+func Observed(name string, days []time.Time) Calendar {
+	c := observedCalendar{name: name, sessions: make(map[string]bool, len(days))}
+	for _, d := range days {
+		c.sessions[key(Date(d))] = true
+	}
+	return c
+}
+
+func (c observedCalendar) Name() string { return c.name }
+
+func (c observedCalendar) Session(day time.Time) (Session, bool) {
+	day = Date(day)
+	if !c.sessions[key(day)] {
+		return Session{}, false
+	}
+	return Session{Date: day, Open: day, Close: day.AddDate(0, 0, 1)}, true
+}
+
+func date(year int, month time.Month, day int) time.Time {
+	return time.Date(year, month, day, 0, 0, 0, 0, time.UTC)
+}
+
+// observed moves a holiday on a weekend to the nearest weekday
+func observed(d time.Time) time.Time {
+	switch d.Weekday() {
+	case time.Saturday:
+		return d.AddDate(0, 0, -1)
+	case time.Sunday:
+		return d.AddDate(0, 0, 1)
+	}
+	return d
+}
+
+// nthWeekday is the nth given weekday of a month
+func nthWeekday(year int, month time.Month, weekday time.Weekday, n int) time.Time {
+	first := date(year, month, 1)
+	offset := (int(weekday) - int(first.Weekday()) + 7) % 7
+	return first.AddDate(0, 0, offset+7*(n-1))
+}
+
+// lastWeekday is the last given weekday of a month
+func lastWeekday(year int, month time.Month, weekday time.Weekday) time.Time {
+	last := date(year, month+1, 0)
+	offset := (int(last.Weekday()) - int(weekday) + 7) % 7
+	return last.AddDate(0, 0, -offset)
+}
+
+// easter is Easter Sunday of the Gregorian calendar (anonymous Gregorian algorithm)
+func easter(year int) time.Time {
+	a := year % 19
+	b, c := year/100, year%100
+	d, e := b/4, b%4
+	f := (b + 8) / 25
+	g := (b - f + 1) / 3
+	h := (19*a + b - d - g + 15) % 30
+	i, k := c/4, c%4
+	l := (32 + 2*e + 2*i - h - k) % 7
+	m := (a + 11*h + 22*l) / 451
+	month := (h + l - 7*m + 114) / 31
+	day := (h+l-7*m+114)%31 + 1
+	return date(year, time.Month(month), day)
+}
@@ -0,0 +1,105 @@
+package calendar
+
+import (
+	"testing"
+	"time"
+)
+
+func day(s string) time.Time {
+	t, err := time.Parse("2006-01-02", s)
+	if err != nil {
+		panic(err)
+	}
+	return t
+}
+
+func TestUSSessions(t *testing.T) {
+	tests := []struct {
+		name string
+		day  string
+		open bool
+	}{
+		{"new year's day on a saturday is not observed", "2021-12-31", true},
+		{"new year's day on a saturday", "2022-01-01", false},
+		{"new year's day on a sunday", "2023-01-02", false},
+		{"juneteenth before 2022", "2021-06-18", true},
+		{"juneteenth on a sunday", "2022-06-20", false},
+		{"juneteenth", "2023-06-19", false},
+		{"good friday", "2024-03-29", false},
+		{"easter monday", "2024-04-01", true},
+		{"good friday in april", "2025-04-18", false},
+		{"independence day on a sunday", "2021-07-05", false},
+		{"independence day on a saturday", "2026-07-03", false},
+		{"thanksgiving", "2024-11-28", false},
+		{"christmas on a sunday", "2022-12-26", false},
+		{"unscheduled closure", "2001-09-11", false},
+		{"day of mourning", "2025-01-09", false},
+		{"weekend", "2024-06-15", false},
+		{"regular day", "2024-06-14", true},
+	}
+	for _, tt := range tests {
+		if _, open := NYSE.Session(day(tt.day)); open != tt.open {
+			t.Errorf("%s: Session(%s) open = %v, want %v", tt.name, tt.day, open, tt.open)
+		}
+	}
+}
+
+func TestUSEarlyCloses(t *testing.T) {
+	tests := []struct {
+		name  string
+		day   string
+		early bool
+	}{
+		{"before independence day on a thursday", "2024-07-03", true},
+		{"before independence day on a tuesday", "2023-07-03", true},
+		{"before independence day on a monday", "2022-07-01", false},
+		{"day after thanksgiving", "2024-11-29", true},
+		{"christmas eve", "2024-12-24", true},
+		{"regular day", "2024-12-23", false},
+	}
+	for _, tt := range tests {
+		s, ok := NYSE.Session(day(tt.day))
+		if !ok {
+			t.Errorf("%s: no session on %s", tt.name, tt.day)
+			continue
+		}
+		if s.EarlyClose != tt.early {
+			t.Errorf("%s: EarlyClose = %v, want %v", tt.name, s.EarlyClose, tt.early)
+		}
+		wantHour := closeHour
+		if tt.early {
+			wantHour = earlyCloseHour
+		}
+		if h := s.Close.In(easternTime).Hour(); h != wantHour {
+			t.Errorf("%s: closes at %d, want %d", tt.name, h, wantHour)
+		}
+	}
+}
+
+func TestUSHolidaysObserved(t *testing.T) {
+	// 2022 starts without New Year's Day and is the first year with Juneteenth
+	holidays := USHolidays(2022)
+	if len(holidays) != 9 {
+		t.Fatalf("got %d holidays in 2022, want 9: %v", len(holidays), holidays)
+	}
+	if holidays[0].Name != "Martin Luther King Jr. Day" {
+		t.Errorf("first holiday of 2022 is %s, want Martin Luther King Jr. Day", holidays[0].Name)
+	}
+	if n := len(USHolidays(2021)); n != 9 {
+		t.Errorf("got %d holidays in 2021, want 9", n)
+	}
+}
+
+func TestSessions(t *testing.T) {
+	// Thanksgiving week of 2024: Monday to Friday without Thursday
+	sessions := Sessions(NYSE, day("2024-11-23"), day("2024-12-01"))
+	want := []string{"2024-11-25", "2024-11-26", "2024-11-27", "2024-11-29"}
+	if len(sessions) != len(want) {
+		t.Fatalf("got %d sessions, want %d", len(sessions), len(want))
+	}
+	for i, s := range sessions {
+		if key(s.Date) != want[i] {
+			t.Errorf("session %d on %s, want %s", i, key(s.Date), want[i])
+		}
+	}
+}
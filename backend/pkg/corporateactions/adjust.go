@@ -0,0 +1,112 @@
+// Package corporateactions adjusts stored prices for stock splits and cash dividends.
+// Prices are stored as traded; adjusted series are computed on read by scaling every bar
+// before an event, so that the latest bar keeps its traded price.
+package corporateactions
+
+import (
+	"sort"
+	"time"
+
+	"stockpick-backend/pkg/models"
+)
+
+// Adjustment scales the bars traded before its date
+type Adjustment struct {
+	Date   time.Time
+	Price  float64 // Multiplier of prices before Date
+	Volume float64 // Multiplier of volumes before Date
+}
+
+// ForSplit is the adjustment of a split: a 4-for-1 split divides earlier prices by 4 and
+// multiplies earlier volumes by 4. It returns false for a malformed ratio.
+func ForSplit(s models.StockSplit) (Adjustment, bool) {
+	if s.Numerator <= 0 || s.Denominator <= 0 {
+		return Adjustment{}, false
+	}
+	ratio := s.Numerator / s.Denominator
+	return Adjustment{Date: s.Date, Price: 1 / ratio, Volume: ratio}, true
+}
+
+// ForDividend is the adjustment of a cash dividend given the last close before its ex-date:
+// earlier prices are scaled by 1 - dividend/close, so that returns include the payout. It
+// returns false when the dividend is not a positive fraction of the close.
+func ForDividend(d models.Dividend, prevClose float64) (Adjustment, bool) {
+	if d.Amount <= 0 || prevClose <= 0 || d.Amount >= prevClose {
+		return Adjustment{}, false
+	}
+	return Adjustment{Date: d.ExDate, Price: 1 - d.Amount/prevClose, Volume: 1}, true
+}
+
+// Adjust returns split-adjusted prices (oldest first), also adjusted for dividends when
+// withDividends is set, so that the latest bar keeps its traded price. Events after the
+// latest bar are ignored. Dividend adjustments use the traded close of the last bar before
+// the ex-date, so dividends on or before the first bar are ignored too; they would not
+// change the series anyway. The input is not modified.
+func Adjust(prices []models.HistoricalPrice, splits []models.StockSplit, dividends []models.Dividend, withDividends bool) []models.HistoricalPrice {
+	if len(prices) == 0 {
+		return nil
+	}
+	last := prices[len(prices)-1].Time
+
+	var adjustments []Adjustment
+	for _, s := range splits {
+		if before(last, s.Date) {
+			continue // Announced but not yet effective
+		}
+		if a, ok := ForSplit(s); ok {
+			adjustments = append(adjustments, a)
+		}
+	}
+	if withDividends {
+		for _, d := range dividends {
+			i := sort.Search(len(prices), func(i int) bool { return !before(prices[i].Time, d.ExDate) })
+			if i == 0 || i == len(prices) {
+				continue
+			}
+			if a, ok := ForDividend(d, prices[i-1].ClosePrice); ok {
+				adjustments = append(adjustments, a)
+			}
+		}
+	}
+	return Apply(prices, adjustments)
+}
+
+// Apply scales every bar by the adjustments dated after its trading day. Price fields are
+// multiplied by the cumulative price factor, the volume by the cumulative volume factor;
+// percentage changes are unaffected. The input is not modified.
+func Apply(prices []models.HistoricalPrice, adjustments []Adjustment) []models.HistoricalPrice {
+	out := append([]models.HistoricalPrice(nil), prices...)
+	if len(adjustments) == 0 {
+		return out
+	}
+	adjustments = append([]Adjustment(nil), adjustments...)
+	sort.Slice(adjustments, func(i, j int) bool { return adjustments[i].Date.After(adjustments[j].Date) })
+
+	price, volume := 1.0, 1.0
+	next := 0
+	for i := len(out) - 1; i >= 0; i-- {
+		for next < len(adjustments) && before(out[i].Time, adjustments[next].Date) {
+			price *= adjustments[next].Price
+			volume *= adjustments[next].Volume
+			next++
+		}
+		if price == 1 && volume == 1 {
+			continue
+		}
+		p := &out[i]
+		p.OpenPrice *= price
+		p.HighPrice *= price
+		p.LowPrice *= price
+		p.ClosePrice *= price
+		p.VWAP *= price
+		p.PriceChange *= price
+		p.Volume = int64(float64(p.Volume)*volume + 0.5)
+	}
+	return out
+}
+
+// before reports whether a bar traded on an earlier day than an event date. Daily bars
+// and event dates are both stored at midnight UTC, so the comparison is on UTC dates.
+func before(bar, event time.Time) bool {
+	return bar.UTC().Format("2006-01-02") < event.UTC().Format("2006-01-02")
+}
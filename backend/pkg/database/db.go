@@ -4,9 +4,12 @@ import (
 	"database/sql"
 	"fmt"
 	"log"
+	"math"
 	"time"
 
 	"github.com/google/uuid"
+	"stockpick-backend/pkg/corporateactions"
+	"stockpick-backend/pkg/fundamentals"
 	"stockpick-backend/pkg/models"
 )
 
@@ -74,9 +77,21 @@ func (d *DB) GetStockBySymbol(symbol string) (*models.Stock, error) {
 
 // GetAllStocks retrieves all stocks from the database
 func (d *DB) GetAllStocks() ([]models.Stock, error) {
-	query := `SELECT stock_id, symbol, company_name, exchange, sector, industry, currency, is_active, created_at, updated_at FROM stocks ORDER BY symbol ASC`
+	return d.queryStocks("")
+}
 
-	rows, err := d.Query(query)
+// GetStocksWhere retrieves the stocks matching a SQL condition over the stocks columns,
+// with positional arguments. The condition must come from trusted code such as the
+// screener's translator, never from user input.
+func (d *DB) GetStocksWhere(condition string, args ...interface{}) ([]models.Stock, error) {
+	return d.queryStocks("WHERE "+condition, args...)
+}
+
+// queryStocks retrieves the stocks selected by an optional WHERE clause, ordered by symbol
+func (d *DB) queryStocks(where string, args ...interface{}) ([]models.Stock, error) {
+	query := `SELECT stock_id, symbol, company_name, exchange, sector, industry, currency, is_active, created_at, updated_at FROM stocks ` + where + ` ORDER BY symbol ASC`
+
+	rows, err := d.Query(query, args...)
 	if err != nil {
 		return nil, fmt.Errorf("failed to query all stocks: %w", err)
 	}
@@ -151,37 +166,476 @@ func (d *DB) GetHistoricalPrices(stockID uuid.UUID, from, to time.Time) ([]model
 	return prices, nil
 }
 
-// InsertFinancialStatement inserts a new financial statement record
+// InsertIntradayPrices inserts or updates intraday bars of one interval in a single transaction
+func (d *DB) InsertIntradayPrices(interval string, prices []models.HistoricalPrice) error {
+	tx, err := d.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin intraday price transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	stmt, err := tx.Prepare(`INSERT INTO intraday_prices (time, stock_id, bar_interval, open_price, high_price, low_price, close_price, volume)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8) ON CONFLICT (time, stock_id, bar_interval) DO UPDATE SET
+		open_price = EXCLUDED.open_price, high_price = EXCLUDED.high_price, low_price = EXCLUDED.low_price,
+		close_price = EXCLUDED.close_price, volume = EXCLUDED.volume`)
+	if err != nil {
+		return fmt.Errorf("failed to prepare intraday price insert: %w", err)
+	}
+	defer stmt.Close()
+
+	for _, p := range prices {
+		if _, err := stmt.Exec(p.Time, p.StockID, interval, p.OpenPrice, p.HighPrice, p.LowPrice, p.ClosePrice, p.Volume); err != nil {
+			return fmt.Errorf("failed to insert intraday price: %w", err)
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit intraday prices: %w", err)
+	}
+	return nil
+}
+
+// GetIntradayPrices retrieves the intraday bars of one interval for a stock within a time range,
+// oldest first. Fields daily bars carry beyond OHLCV are left zero.
+func (d *DB) GetIntradayPrices(stockID uuid.UUID, interval string, from, to time.Time) ([]models.HistoricalPrice, error) {
+	query := `SELECT time, stock_id, open_price, high_price, low_price, close_price, volume
+		FROM intraday_prices WHERE stock_id = $1 AND bar_interval = $2 AND time BETWEEN $3 AND $4 ORDER BY time ASC`
+
+	rows, err := d.Query(query, stockID, interval, from, to)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query intraday prices: %w", err)
+	}
+	defer rows.Close()
+
+	var prices []models.HistoricalPrice
+	for rows.Next() {
+		var price models.HistoricalPrice
+		err := rows.Scan(&price.Time, &price.StockID, &price.OpenPrice, &price.HighPrice, &price.LowPrice, &price.ClosePrice, &price.Volume)
+		if err != nil {
+			log.Printf("Error scanning intraday price row: %v", err)
+			continue
+		}
+		prices = append(prices, price)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating intraday prices rows: %w", err)
+	}
+
+	return prices, nil
+}
+
+// GetClosePriceOnOrBefore returns the last closing price recorded on or before the given time.
+// It returns 0 when no price is available.
+func (d *DB) GetClosePriceOnOrBefore(stockID uuid.UUID, at time.Time) (float64, error) {
+	query := `SELECT close_price FROM historical_prices WHERE stock_id = $1 AND time <= $2 ORDER BY time DESC LIMIT 1`
+
+	var price float64
+	err := d.QueryRow(query, stockID, at).Scan(&price)
+	if err == sql.ErrNoRows {
+		return 0, nil
+	} else if err != nil {
+		return 0, fmt.Errorf("failed to get close price: %w", err)
+	}
+	return price, nil
+}
+
+// GetAdjustedHistoricalPrices retrieves prices like GetHistoricalPrices, adjusted for stock
+// splits and, with withDividends, for cash dividends. Adjustments are relative to the latest
+// stored price, so later prices are loaded to find the splits and dividends after to.
+func (d *DB) GetAdjustedHistoricalPrices(stockID uuid.UUID, from, to time.Time, withDividends bool) ([]models.HistoricalPrice, error) {
+	prices, err := d.GetHistoricalPrices(stockID, from, time.Now())
+	if err != nil {
+		return nil, err
+	}
+	splits, err := d.GetStockSplits(stockID)
+	if err != nil {
+		return nil, err
+	}
+	var dividends []models.Dividend
+	if withDividends {
+		if dividends, err = d.GetDividends(stockID); err != nil {
+			return nil, err
+		}
+	}
+
+	adjusted := corporateactions.Adjust(prices, splits, dividends, withDividends)
+	end := len(adjusted)
+	for end > 0 && adjusted[end-1].Time.After(to) {
+		end--
+	}
+	return adjusted[:end], nil
+}
+
+// Bar intervals served from the continuous aggregates of historical_prices when unadjusted
+const (
+	WeeklyInterval  = "1w"
+	MonthlyInterval = "1mo"
+)
+
+// aggregates maps the aggregate intervals to their continuous aggregate and bucket width
+var aggregates = map[string]struct {
+	view  string
+	width string
+}{
+	WeeklyInterval:  {view: "weekly_prices", width: "1 week"},
+	MonthlyInterval: {view: "monthly_prices", width: "1 month"},
+}
+
+// GetAggregatedPrices retrieves weekly or monthly bars of a stock from the continuous
+// aggregates, as traded, including the bucket that contains from. Bars are timed at the
+// start of their bucket; fields beyond OHLCV are left zero.
+func (d *DB) GetAggregatedPrices(stockID uuid.UUID, interval string, from, to time.Time) ([]models.HistoricalPrice, error) {
+	agg, ok := aggregates[interval]
+	if !ok {
+		return nil, fmt.Errorf("unsupported aggregate interval %q", interval)
+	}
+	query := fmt.Sprintf(`SELECT bucket, stock_id, open_price, high_price, low_price, close_price, volume
+		FROM %s WHERE stock_id = $1 AND bucket BETWEEN time_bucket(INTERVAL '%s', $2::timestamptz) AND $3 ORDER BY bucket ASC`,
+		agg.view, agg.width)
+
+	rows, err := d.Query(query, stockID, from, to)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query %s: %w", agg.view, err)
+	}
+	defer rows.Close()
+
+	var prices []models.HistoricalPrice
+	for rows.Next() {
+		var price models.HistoricalPrice
+		var volume sql.NullInt64
+		err := rows.Scan(&price.Time, &price.StockID, &price.OpenPrice, &price.HighPrice, &price.LowPrice, &price.ClosePrice, &volume)
+		if err != nil {
+			log.Printf("Error scanning %s row: %v", agg.view, err)
+			continue
+		}
+		price.Volume = volume.Int64
+		prices = append(prices, price)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating %s rows: %w", agg.view, err)
+	}
+
+	return prices, nil
+}
+
+// GetAdjustedAggregatedPrices retrieves weekly or monthly bars like GetAggregatedPrices,
+// adjusted like GetAdjustedHistoricalPrices. The continuous aggregates mix closing bases
+// within a bucket that contains a split or dividend, so the bars are built from the
+// adjusted daily prices instead.
+func (d *DB) GetAdjustedAggregatedPrices(stockID uuid.UUID, interval string, from, to time.Time, withDividends bool) ([]models.HistoricalPrice, error) {
+	if _, ok := aggregates[interval]; !ok {
+		return nil, fmt.Errorf("unsupported aggregate interval %q", interval)
+	}
+	daily, err := d.GetAdjustedHistoricalPrices(stockID, bucketStart(interval, from), to, withDividends)
+	if err != nil {
+		return nil, err
+	}
+
+	var bars []models.HistoricalPrice
+	for _, p := range daily {
+		start := bucketStart(interval, p.Time)
+		if n := len(bars); n > 0 && bars[n-1].Time.Equal(start) {
+			bar := &bars[n-1]
+			bar.HighPrice = math.Max(bar.HighPrice, p.HighPrice)
+			bar.LowPrice = math.Min(bar.LowPrice, p.LowPrice)
+			bar.ClosePrice = p.ClosePrice
+			bar.Volume += p.Volume
+			continue
+		}
+		bars = append(bars, models.HistoricalPrice{
+			Time:       start,
+			StockID:    p.StockID,
+			OpenPrice:  p.OpenPrice,
+			HighPrice:  p.HighPrice,
+			LowPrice:   p.LowPrice,
+			ClosePrice: p.ClosePrice,
+			Volume:     p.Volume,
+		})
+	}
+	return bars, nil
+}
+
+// bucketStart returns the start of the weekly or monthly bucket containing t, matching
+// time_bucket: weeks start on Monday and months on the first, both in UTC
+func bucketStart(interval string, t time.Time) time.Time {
+	t = t.UTC()
+	day := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+	if interval == MonthlyInterval {
+		return day.AddDate(0, 0, 1-day.Day())
+	}
+	return day.AddDate(0, 0, -(int(day.Weekday())+6)%7)
+}
+
+// UpsertStockSplit inserts or updates a stock split
+func (d *DB) UpsertStockSplit(split *models.StockSplit) error {
+	query := `INSERT INTO stock_splits (stock_id, date, numerator, denominator, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		ON CONFLICT (stock_id, date) DO UPDATE SET
+		numerator = EXCLUDED.numerator, denominator = EXCLUDED.denominator, updated_at = NOW()`
+
+	split.CreatedAt = time.Now()
+	split.UpdatedAt = time.Now()
+
+	_, err := d.Exec(query, split.StockID, split.Date, split.Numerator, split.Denominator, split.CreatedAt, split.UpdatedAt)
+	if err != nil {
+		return fmt.Errorf("failed to upsert stock split: %w", err)
+	}
+	return nil
+}
+
+// GetStockSplits retrieves the splits of a stock, oldest first
+func (d *DB) GetStockSplits(stockID uuid.UUID) ([]models.StockSplit, error) {
+	query := `SELECT stock_id, date, numerator, denominator, created_at, updated_at
+		FROM stock_splits WHERE stock_id = $1 ORDER BY date ASC`
+
+	rows, err := d.Query(query, stockID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query stock splits: %w", err)
+	}
+	defer rows.Close()
+
+	var splits []models.StockSplit
+	for rows.Next() {
+		var split models.StockSplit
+		err := rows.Scan(&split.StockID, &split.Date, &split.Numerator, &split.Denominator, &split.CreatedAt, &split.UpdatedAt)
+		if err != nil {
+			log.Printf("Error scanning stock split row: %v", err)
+			continue
+		}
+		splits = append(splits, split)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating stock splits rows: %w", err)
+	}
+
+	return splits, nil
+}
+
+// UpsertDividend inserts or updates a dividend
+func (d *DB) UpsertDividend(dividend *models.Dividend) error {
+	query := `INSERT INTO dividends (stock_id, ex_date, amount, adjusted_amount, record_date, payment_date, declaration_date, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+		ON CONFLICT (stock_id, ex_date) DO UPDATE SET
+		amount = EXCLUDED.amount, adjusted_amount = EXCLUDED.adjusted_amount, record_date = EXCLUDED.record_date,
+		payment_date = EXCLUDED.payment_date, declaration_date = EXCLUDED.declaration_date, updated_at = NOW()`
+
+	dividend.CreatedAt = time.Now()
+	dividend.UpdatedAt = time.Now()
+
+	_, err := d.Exec(query, dividend.StockID, dividend.ExDate, dividend.Amount, dividend.AdjustedAmount,
+		dividend.RecordDate, dividend.PaymentDate, dividend.DeclarationDate, dividend.CreatedAt, dividend.UpdatedAt)
+	if err != nil {
+		return fmt.Errorf("failed to upsert dividend: %w", err)
+	}
+	return nil
+}
+
+// GetDividends retrieves the dividends of a stock, oldest first
+func (d *DB) GetDividends(stockID uuid.UUID) ([]models.Dividend, error) {
+	query := `SELECT stock_id, ex_date, amount, COALESCE(adjusted_amount, amount), record_date, payment_date, declaration_date, created_at, updated_at
+		FROM dividends WHERE stock_id = $1 ORDER BY ex_date ASC`
+
+	rows, err := d.Query(query, stockID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query dividends: %w", err)
+	}
+	defer rows.Close()
+
+	var dividends []models.Dividend
+	for rows.Next() {
+		var dividend models.Dividend
+		err := rows.Scan(
+			&dividend.StockID, &dividend.ExDate, &dividend.Amount, &dividend.AdjustedAmount,
+			&dividend.RecordDate, &dividend.PaymentDate, &dividend.DeclarationDate,
+			&dividend.CreatedAt, &dividend.UpdatedAt,
+		)
+		if err != nil {
+			log.Printf("Error scanning dividend row: %v", err)
+			continue
+		}
+		dividends = append(dividends, dividend)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating dividends rows: %w", err)
+	}
+
+	return dividends, nil
+}
+
+// financialStatementColumns lists the financial_statements columns in the order
+// scanFinancialStatement expects them.
+const financialStatementColumns = `statement_id, stock_id, date, period, filing_date, accepted_date, revision, known_since,
+	revenue, net_income, eps, total_assets, total_liabilities, total_equity, free_cash_flow,
+	gross_profit, operating_income, ebitda, income_before_tax, income_tax_expense, total_debt, cash_and_equivalents, shares_outstanding,
+	current_assets, current_liabilities, long_term_debt, retained_earnings, operating_cash_flow,
+	debt_to_equity_ratio, p_e_ratio, p_b_ratio, p_s_ratio, ev_to_ebitda, fcf_yield, roic, roe, gross_margin, operating_margin, net_margin,
+	created_at, updated_at`
+
+// scanFinancialStatement scans a row selected with financialStatementColumns
+func scanFinancialStatement(rows *sql.Rows) (models.FinancialStatement, error) {
+	var statement models.FinancialStatement
+	err := rows.Scan(
+		&statement.StatementID, &statement.StockID, &statement.Date, &statement.Period,
+		&statement.FilingDate, &statement.AcceptedDate, &statement.Revision, &statement.KnownSince,
+		&statement.Revenue, &statement.NetIncome, &statement.EPS, &statement.TotalAssets, &statement.TotalLiabilities,
+		&statement.TotalEquity, &statement.FreeCashFlow,
+		&statement.GrossProfit, &statement.OperatingIncome, &statement.EBITDA, &statement.IncomeBeforeTax,
+		&statement.IncomeTaxExpense, &statement.TotalDebt, &statement.CashAndEquivalents, &statement.SharesOutstanding,
+		&statement.CurrentAssets, &statement.CurrentLiabilities, &statement.LongTermDebt, &statement.RetainedEarnings,
+		&statement.OperatingCashFlow,
+		&statement.DebtToEquityRatio, &statement.PERatio, &statement.PBRatio, &statement.PSRatio, &statement.EVToEBITDA,
+		&statement.FCFYield, &statement.ROIC, &statement.ROE, &statement.GrossMargin, &statement.OperatingMargin,
+		&statement.NetMargin, &statement.CreatedAt, &statement.UpdatedAt,
+	)
+	return statement, err
+}
+
+// InsertFinancialStatement stores a reported financial statement. Restatements are kept:
+// when the reported figures differ from the latest stored revision of the same period a
+// new revision is inserted, otherwise the latest revision is updated in place.
 func (d *DB) InsertFinancialStatement(statement *models.FinancialStatement) error {
-	query := `INSERT INTO financial_statements (statement_id, stock_id, date, period, revenue, net_income, eps, total_assets, total_liabilities, total_equity, free_cash_flow, debt_to_equity_ratio, p_e_ratio, p_b_ratio, roic, created_at, updated_at)
-		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16, $17)
-		ON CONFLICT (stock_id, date, period) DO UPDATE SET
+	revisions, err := d.queryFinancialStatements(`SELECT `+financialStatementColumns+`
+		FROM financial_statements WHERE stock_id = $1 AND date = $2 AND period = $3
+		ORDER BY revision DESC LIMIT 1`, statement.StockID, statement.Date, statement.Period)
+	if err != nil {
+		return fmt.Errorf("failed to look up financial statement revisions: %w", err)
+	}
+
+	switch {
+	case len(revisions) == 0:
+		statement.Revision = 1
+		statement.KnownSince = fundamentals.PublicationTime(*statement)
+	case fundamentals.SameFigures(revisions[0], *statement):
+		latest := revisions[0]
+		if statement.FilingDate == nil {
+			statement.FilingDate = latest.FilingDate
+		}
+		if statement.AcceptedDate == nil {
+			statement.AcceptedDate = latest.AcceptedDate
+		}
+		statement.Revision = latest.Revision
+		statement.KnownSince = latest.KnownSince
+		if latest.Revision == 1 {
+			// The original report may only now carry its filing dates
+			statement.KnownSince = fundamentals.PublicationTime(*statement)
+		}
+	default:
+		// A restatement is known from its own filing when that is newer than the revision it
+		// replaces, otherwise from the moment it is first seen.
+		latest := revisions[0]
+		statement.Revision = latest.Revision + 1
+		statement.KnownSince = time.Now()
+		if published := fundamentals.PublicationTime(*statement); published.After(latest.KnownSince) && published.Before(statement.KnownSince) {
+			statement.KnownSince = published
+		}
+	}
+
+	query := `INSERT INTO financial_statements (` + financialStatementColumns + `)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16, $17, $18, $19, $20, $21, $22, $23, $24, $25, $26, $27, $28, $29, $30, $31, $32, $33, $34, $35, $36, $37, $38, $39, $40, $41)
+		ON CONFLICT (stock_id, date, period, revision) DO UPDATE SET
+		filing_date = EXCLUDED.filing_date, accepted_date = EXCLUDED.accepted_date, known_since = EXCLUDED.known_since,
 		revenue = EXCLUDED.revenue, net_income = EXCLUDED.net_income, eps = EXCLUDED.eps,
 		total_assets = EXCLUDED.total_assets, total_liabilities = EXCLUDED.total_liabilities,
 		total_equity = EXCLUDED.total_equity, free_cash_flow = EXCLUDED.free_cash_flow,
+		gross_profit = EXCLUDED.gross_profit, operating_income = EXCLUDED.operating_income, ebitda = EXCLUDED.ebitda,
+		income_before_tax = EXCLUDED.income_before_tax, income_tax_expense = EXCLUDED.income_tax_expense,
+		total_debt = EXCLUDED.total_debt, cash_and_equivalents = EXCLUDED.cash_and_equivalents,
+		shares_outstanding = EXCLUDED.shares_outstanding, current_assets = EXCLUDED.current_assets,
+		current_liabilities = EXCLUDED.current_liabilities, long_term_debt = EXCLUDED.long_term_debt,
+		retained_earnings = EXCLUDED.retained_earnings, operating_cash_flow = EXCLUDED.operating_cash_flow,
 		debt_to_equity_ratio = EXCLUDED.debt_to_equity_ratio, p_e_ratio = EXCLUDED.p_e_ratio,
-		p_b_ratio = EXCLUDED.p_b_ratio, roic = EXCLUDED.roic, updated_at = NOW()`
+		p_b_ratio = EXCLUDED.p_b_ratio, p_s_ratio = EXCLUDED.p_s_ratio, ev_to_ebitda = EXCLUDED.ev_to_ebitda,
+		fcf_yield = EXCLUDED.fcf_yield, roic = EXCLUDED.roic, roe = EXCLUDED.roe, gross_margin = EXCLUDED.gross_margin,
+		operating_margin = EXCLUDED.operating_margin, net_margin = EXCLUDED.net_margin, updated_at = NOW()
+		RETURNING statement_id`
 
 	statement.StatementID = uuid.New()
 	statement.CreatedAt = time.Now()
 	statement.UpdatedAt = time.Now()
 
-	_, err := d.Exec(query, statement.StatementID, statement.StockID, statement.Date, statement.Period,
+	err = d.QueryRow(query, statement.StatementID, statement.StockID, statement.Date, statement.Period,
+		statement.FilingDate, statement.AcceptedDate, statement.Revision, statement.KnownSince,
 		statement.Revenue, statement.NetIncome, statement.EPS, statement.TotalAssets, statement.TotalLiabilities,
-		statement.TotalEquity, statement.FreeCashFlow, statement.DebtToEquityRatio, statement.PERatio,
-		statement.PBRatio, statement.ROIC, statement.CreatedAt, statement.UpdatedAt)
+		statement.TotalEquity, statement.FreeCashFlow,
+		statement.GrossProfit, statement.OperatingIncome, statement.EBITDA, statement.IncomeBeforeTax,
+		statement.IncomeTaxExpense, statement.TotalDebt, statement.CashAndEquivalents, statement.SharesOutstanding,
+		statement.CurrentAssets, statement.CurrentLiabilities, statement.LongTermDebt, statement.RetainedEarnings,
+		statement.OperatingCashFlow,
+		statement.DebtToEquityRatio, statement.PERatio, statement.PBRatio, statement.PSRatio, statement.EVToEBITDA,
+		statement.FCFYield, statement.ROIC, statement.ROE, statement.GrossMargin, statement.OperatingMargin,
+		statement.NetMargin, statement.CreatedAt, statement.UpdatedAt).Scan(&statement.StatementID)
 	if err != nil {
 		return fmt.Errorf("failed to insert financial statement: %w", err)
 	}
 	return nil
 }
 
-// GetFinancialStatements retrieves financial statements for a stock by period
+// UpdateFinancialStatementRatios persists the derived valuation and profitability ratios of a stored statement
+func (d *DB) UpdateFinancialStatementRatios(statement *models.FinancialStatement) error {
+	query := `UPDATE financial_statements SET
+		debt_to_equity_ratio = $2, p_e_ratio = $3, p_b_ratio = $4, p_s_ratio = $5, ev_to_ebitda = $6,
+		fcf_yield = $7, roic = $8, roe = $9, gross_margin = $10, operating_margin = $11, net_margin = $12,
+		updated_at = NOW()
+		WHERE statement_id = $1`
+
+	_, err := d.Exec(query, statement.StatementID, statement.DebtToEquityRatio, statement.PERatio, statement.PBRatio,
+		statement.PSRatio, statement.EVToEBITDA, statement.FCFYield, statement.ROIC, statement.ROE,
+		statement.GrossMargin, statement.OperatingMargin, statement.NetMargin)
+	if err != nil {
+		return fmt.Errorf("failed to update financial statement ratios: %w", err)
+	}
+	return nil
+}
+
+// GetFinancialStatements retrieves the latest revision of the financial statements for a
+// stock by period. The virtual "ttm" period returns rolling trailing-twelve-month statements
+// aggregated from the stored quarterly statements.
 func (d *DB) GetFinancialStatements(stockID uuid.UUID, period string) ([]models.FinancialStatement, error) {
-	query := `SELECT statement_id, stock_id, date, period, revenue, net_income, eps, total_assets, total_liabilities, total_equity, free_cash_flow, debt_to_equity_ratio, p_e_ratio, p_b_ratio, roic, created_at, updated_at
-		FROM financial_statements WHERE stock_id = $1 AND period = $2 ORDER BY date DESC`
+	if period == fundamentals.TTMPeriod {
+		quarters, err := d.GetFinancialStatements(stockID, "quarterly")
+		if err != nil {
+			return nil, err
+		}
+		return fundamentals.TTMSeries(quarters), nil
+	}
 
-	rows, err := d.Query(query, stockID, period)
+	query := `SELECT DISTINCT ON (date) ` + financialStatementColumns + `
+		FROM financial_statements WHERE stock_id = $1 AND period = $2 ORDER BY date DESC, revision DESC`
+	return d.queryFinancialStatements(query, stockID, period)
+}
+
+// GetFinancialStatementsAsOf retrieves the financial statements of a stock as they were
+// known at the given time: statements published later are left out and restated periods
+// are returned in the revision that was current then.
+func (d *DB) GetFinancialStatementsAsOf(stockID uuid.UUID, period string, asOf time.Time) ([]models.FinancialStatement, error) {
+	if period == fundamentals.TTMPeriod {
+		quarters, err := d.GetFinancialStatementsAsOf(stockID, "quarterly", asOf)
+		if err != nil {
+			return nil, err
+		}
+		return fundamentals.TTMSeries(quarters), nil
+	}
+
+	query := `SELECT DISTINCT ON (date) ` + financialStatementColumns + `
+		FROM financial_statements WHERE stock_id = $1 AND period = $2 AND known_since <= $3
+		ORDER BY date DESC, revision DESC`
+	return d.queryFinancialStatements(query, stockID, period, asOf)
+}
+
+// GetFinancialStatementRevisions retrieves every stored revision of the financial statements
+// for a stock by period, ordered by date and revision, newest first
+func (d *DB) GetFinancialStatementRevisions(stockID uuid.UUID, period string) ([]models.FinancialStatement, error) {
+	query := `SELECT ` + financialStatementColumns + `
+		FROM financial_statements WHERE stock_id = $1 AND period = $2 ORDER BY date DESC, revision DESC`
+	return d.queryFinancialStatements(query, stockID, period)
+}
+
+// queryFinancialStatements runs a query selecting financialStatementColumns
+func (d *DB) queryFinancialStatements(query string, args ...interface{}) ([]models.FinancialStatement, error) {
+	rows, err := d.Query(query, args...)
 	if err != nil {
 		return nil, fmt.Errorf("failed to query financial statements: %w", err)
 	}
@@ -189,13 +643,7 @@ func (d *DB) GetFinancialStatements(stockID uuid.UUID, period string) ([]models.
 
 	var statements []models.FinancialStatement
 	for rows.Next() {
-		var statement models.FinancialStatement
-		err := rows.Scan(
-			&statement.StatementID, &statement.StockID, &statement.Date, &statement.Period,
-			&statement.Revenue, &statement.NetIncome, &statement.EPS, &statement.TotalAssets, &statement.TotalLiabilities,
-			&statement.TotalEquity, &statement.FreeCashFlow, &statement.DebtToEquityRatio, &statement.PERatio,
-			&statement.PBRatio, &statement.ROIC, &statement.CreatedAt, &statement.UpdatedAt,
-		)
+		statement, err := scanFinancialStatement(rows)
 		if err != nil {
 			log.Printf("Error scanning financial statement row: %v", err)
 			continue
@@ -269,6 +717,64 @@ func (d *DB) GetAnalystTargets(stockID uuid.UUID) ([]models.AnalystTarget, error
 	return targets, nil
 }
 
+// InsertAnalystEstimate inserts or updates an analyst estimate snapshot
+func (d *DB) InsertAnalystEstimate(estimate *models.AnalystEstimate) error {
+	query := `INSERT INTO analyst_estimates (estimate_id, stock_id, fiscal_date, period, as_of, revenue_low, revenue_avg, revenue_high, ebitda_avg, net_income_avg, eps_low, eps_avg, eps_high, revenue_analysts, eps_analysts, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16, $17)
+		ON CONFLICT (stock_id, fiscal_date, period, as_of) DO UPDATE SET
+		revenue_low = EXCLUDED.revenue_low, revenue_avg = EXCLUDED.revenue_avg, revenue_high = EXCLUDED.revenue_high,
+		ebitda_avg = EXCLUDED.ebitda_avg, net_income_avg = EXCLUDED.net_income_avg,
+		eps_low = EXCLUDED.eps_low, eps_avg = EXCLUDED.eps_avg, eps_high = EXCLUDED.eps_high,
+		revenue_analysts = EXCLUDED.revenue_analysts, eps_analysts = EXCLUDED.eps_analysts, updated_at = NOW()`
+
+	estimate.EstimateID = uuid.New()
+	estimate.CreatedAt = time.Now()
+	estimate.UpdatedAt = time.Now()
+
+	_, err := d.Exec(query, estimate.EstimateID, estimate.StockID, estimate.FiscalDate, estimate.Period, estimate.AsOf,
+		estimate.RevenueLow, estimate.RevenueAvg, estimate.RevenueHigh, estimate.EBITDAAvg, estimate.NetIncomeAvg,
+		estimate.EPSLow, estimate.EPSAvg, estimate.EPSHigh, estimate.RevenueAnalysts, estimate.EPSAnalysts,
+		estimate.CreatedAt, estimate.UpdatedAt)
+	if err != nil {
+		return fmt.Errorf("failed to insert analyst estimate: %w", err)
+	}
+	return nil
+}
+
+// GetAnalystEstimates retrieves every estimate snapshot of a stock for a period, newest snapshot first
+func (d *DB) GetAnalystEstimates(stockID uuid.UUID, period string) ([]models.AnalystEstimate, error) {
+	query := `SELECT estimate_id, stock_id, fiscal_date, period, as_of, revenue_low, revenue_avg, revenue_high, ebitda_avg, net_income_avg, eps_low, eps_avg, eps_high, revenue_analysts, eps_analysts, created_at, updated_at
+		FROM analyst_estimates WHERE stock_id = $1 AND period = $2 ORDER BY as_of DESC, fiscal_date ASC`
+
+	rows, err := d.Query(query, stockID, period)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query analyst estimates: %w", err)
+	}
+	defer rows.Close()
+
+	var estimates []models.AnalystEstimate
+	for rows.Next() {
+		var estimate models.AnalystEstimate
+		err := rows.Scan(
+			&estimate.EstimateID, &estimate.StockID, &estimate.FiscalDate, &estimate.Period, &estimate.AsOf,
+			&estimate.RevenueLow, &estimate.RevenueAvg, &estimate.RevenueHigh, &estimate.EBITDAAvg, &estimate.NetIncomeAvg,
+			&estimate.EPSLow, &estimate.EPSAvg, &estimate.EPSHigh, &estimate.RevenueAnalysts, &estimate.EPSAnalysts,
+			&estimate.CreatedAt, &estimate.UpdatedAt,
+		)
+		if err != nil {
+			log.Printf("Error scanning analyst estimate row: %v", err)
+			continue
+		}
+		estimates = append(estimates, estimate)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating analyst estimates rows: %w", err)
+	}
+
+	return estimates, nil
+}
+
 // InsertSentimentScore inserts a new sentiment score record
 func (d *DB) InsertSentimentScore(sentiment *models.SentimentScore) error {
 	query := `INSERT INTO sentiment_scores (sentiment_id, stock_id, timestamp, absolute_index, relative_index, sentiment_score, general_perception, source, created_at, updated_at)
@@ -291,10 +797,11 @@ func (d *DB) InsertSentimentScore(sentiment *models.SentimentScore) error {
 	return nil
 }
 
-// GetSentimentScores retrieves sentiment scores for a stock within a time range and source
+// GetSentimentScores retrieves sentiment scores for a stock within a time range and source.
+// An empty source returns the scores of every source.
 func (d *DB) GetSentimentScores(stockID uuid.UUID, from, to time.Time, source string) ([]models.SentimentScore, error) {
 	query := `SELECT sentiment_id, stock_id, timestamp, absolute_index, relative_index, sentiment_score, general_perception, source, created_at, updated_at
-		FROM sentiment_scores WHERE stock_id = $1 AND timestamp BETWEEN $2 AND $3 AND source = $4 ORDER BY timestamp ASC`
+		FROM sentiment_scores WHERE stock_id = $1 AND timestamp BETWEEN $2 AND $3 AND ($4::text = '' OR source = $4) ORDER BY timestamp ASC`
 
 	rows, err := d.Query(query, stockID, from, to, source)
 	if err != nil {
@@ -322,4 +829,305 @@ func (d *DB) GetSentimentScores(stockID uuid.UUID, from, to time.Time, source st
 	}
 
 	return sentiments, nil
-}
\ No newline at end of file
+}
+
+// UpsertSavedScreen stores a user's screen, replacing any screen of the same name
+func (d *DB) UpsertSavedScreen(screen *models.SavedScreen) error {
+	query := `INSERT INTO saved_screens (screen_id, user_id, name, query, sort_by, sort_order, result_limit, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+		ON CONFLICT (user_id, name) DO UPDATE SET
+		query = EXCLUDED.query, sort_by = EXCLUDED.sort_by, sort_order = EXCLUDED.sort_order,
+		result_limit = EXCLUDED.result_limit, updated_at = NOW()
+		RETURNING screen_id, created_at`
+
+	screen.ScreenID = uuid.New()
+	screen.CreatedAt = time.Now()
+	screen.UpdatedAt = time.Now()
+
+	err := d.QueryRow(query, screen.ScreenID, screen.UserID, screen.Name, screen.Query, screen.SortBy,
+		screen.SortOrder, screen.ResultLimit, screen.CreatedAt, screen.UpdatedAt,
+	).Scan(&screen.ScreenID, &screen.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("failed to upsert saved screen: %w", err)
+	}
+	return nil
+}
+
+// GetSavedScreens retrieves a user's saved screens by name
+func (d *DB) GetSavedScreens(userID string) ([]models.SavedScreen, error) {
+	query := `SELECT screen_id, user_id, name, query, sort_by, sort_order, result_limit, created_at, updated_at
+		FROM saved_screens WHERE user_id = $1 ORDER BY name ASC`
+
+	rows, err := d.Query(query, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query saved screens: %w", err)
+	}
+	defer rows.Close()
+
+	var screens []models.SavedScreen
+	for rows.Next() {
+		var screen models.SavedScreen
+		err := rows.Scan(
+			&screen.ScreenID, &screen.UserID, &screen.Name, &screen.Query, &screen.SortBy,
+			&screen.SortOrder, &screen.ResultLimit, &screen.CreatedAt, &screen.UpdatedAt,
+		)
+		if err != nil {
+			log.Printf("Error scanning saved screen row: %v", err)
+			continue
+		}
+		screens = append(screens, screen)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating saved screens rows: %w", err)
+	}
+
+	return screens, nil
+}
+
+// GetSavedScreen retrieves one of a user's saved screens, or nil if it does not exist
+func (d *DB) GetSavedScreen(userID string, screenID uuid.UUID) (*models.SavedScreen, error) {
+	query := `SELECT screen_id, user_id, name, query, sort_by, sort_order, result_limit, created_at, updated_at
+		FROM saved_screens WHERE user_id = $1 AND screen_id = $2`
+
+	screen := &models.SavedScreen{}
+	err := d.QueryRow(query, userID, screenID).Scan(
+		&screen.ScreenID, &screen.UserID, &screen.Name, &screen.Query, &screen.SortBy,
+		&screen.SortOrder, &screen.ResultLimit, &screen.CreatedAt, &screen.UpdatedAt,
+	)
+	if err == sql.ErrNoRows {
+		return nil, nil // Screen not found
+	} else if err != nil {
+		return nil, fmt.Errorf("failed to get saved screen: %w", err)
+	}
+	return screen, nil
+}
+
+// DeleteSavedScreen deletes one of a user's saved screens, reporting whether it existed
+func (d *DB) DeleteSavedScreen(userID string, screenID uuid.UUID) (bool, error) {
+	result, err := d.Exec(`DELETE FROM saved_screens WHERE user_id = $1 AND screen_id = $2`, userID, screenID)
+	if err != nil {
+		return false, fmt.Errorf("failed to delete saved screen: %w", err)
+	}
+	n, err := result.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("failed to delete saved screen: %w", err)
+	}
+	return n > 0, nil
+}
+
+// UpsertFactorAnalysis stores the result of a factor analysis under its cache key,
+// replacing an earlier result for the same request
+func (d *DB) UpsertFactorAnalysis(analysis *models.FactorAnalysis) error {
+	query := `INSERT INTO factor_analyses (analysis_id, cache_key, request, result, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		ON CONFLICT (cache_key) DO UPDATE SET
+		request = EXCLUDED.request, result = EXCLUDED.result, updated_at = NOW()
+		RETURNING analysis_id, created_at, updated_at`
+
+	analysis.AnalysisID = uuid.New()
+	analysis.CreatedAt = time.Now()
+	analysis.UpdatedAt = time.Now()
+
+	// JSONB parameters are sent as text; []byte would be sent as bytea
+	err := d.QueryRow(query, analysis.AnalysisID, analysis.CacheKey, string(analysis.Request), string(analysis.Result),
+		analysis.CreatedAt, analysis.UpdatedAt,
+	).Scan(&analysis.AnalysisID, &analysis.CreatedAt, &analysis.UpdatedAt)
+	if err != nil {
+		return fmt.Errorf("failed to upsert factor analysis: %w", err)
+	}
+	return nil
+}
+
+// GetFactorAnalyses lists the cached factor analyses without their results, most recent first
+func (d *DB) GetFactorAnalyses() ([]models.FactorAnalysis, error) {
+	query := `SELECT analysis_id, cache_key, request, created_at, updated_at
+		FROM factor_analyses ORDER BY updated_at DESC`
+
+	rows, err := d.Query(query)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query factor analyses: %w", err)
+	}
+	defer rows.Close()
+
+	var analyses []models.FactorAnalysis
+	for rows.Next() {
+		var analysis models.FactorAnalysis
+		err := rows.Scan(&analysis.AnalysisID, &analysis.CacheKey, &analysis.Request, &analysis.CreatedAt, &analysis.UpdatedAt)
+		if err != nil {
+			log.Printf("Error scanning factor analysis row: %v", err)
+			continue
+		}
+		analyses = append(analyses, analysis)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating factor analyses rows: %w", err)
+	}
+
+	return analyses, nil
+}
+
+// GetFactorAnalysis retrieves a cached factor analysis by id, or nil if it does not exist
+func (d *DB) GetFactorAnalysis(analysisID uuid.UUID) (*models.FactorAnalysis, error) {
+	return d.getFactorAnalysis(`analysis_id = $1`, analysisID)
+}
+
+// GetFactorAnalysisByKey retrieves the cached factor analysis of a request, or nil if there is none
+func (d *DB) GetFactorAnalysisByKey(cacheKey string) (*models.FactorAnalysis, error) {
+	return d.getFactorAnalysis(`cache_key = $1`, cacheKey)
+}
+
+func (d *DB) getFactorAnalysis(condition string, arg interface{}) (*models.FactorAnalysis, error) {
+	query := `SELECT analysis_id, cache_key, request, result, created_at, updated_at
+		FROM factor_analyses WHERE ` + condition
+
+	analysis := &models.FactorAnalysis{}
+	err := d.QueryRow(query, arg).Scan(
+		&analysis.AnalysisID, &analysis.CacheKey, &analysis.Request, &analysis.Result,
+		&analysis.CreatedAt, &analysis.UpdatedAt,
+	)
+	if err == sql.ErrNoRows {
+		return nil, nil // Analysis not found
+	} else if err != nil {
+		return nil, fmt.Errorf("failed to get factor analysis: %w", err)
+	}
+	return analysis, nil
+}
+
+// GetPriceRange returns the times of the first and last daily bars of a stock, false when
+// it has none
+func (d *DB) GetPriceRange(stockID uuid.UUID) (time.Time, time.Time, bool, error) {
+	var first, last sql.NullTime
+	err := d.QueryRow(`SELECT MIN(time), MAX(time) FROM historical_prices WHERE stock_id = $1`, stockID).Scan(&first, &last)
+	if err != nil {
+		return time.Time{}, time.Time{}, false, fmt.Errorf("failed to get price range: %w", err)
+	}
+	return first.Time, last.Time, first.Valid, nil
+}
+
+// GetTradingDayCounts returns, for each day between from and to, the number of stocks of an
+// exchange with a daily bar
+func (d *DB) GetTradingDayCounts(exchange string, from, to time.Time) (map[time.Time]int, error) {
+	query := `SELECT (hp.time AT TIME ZONE 'UTC')::date AS day, COUNT(DISTINCT hp.stock_id)
+		FROM historical_prices hp JOIN stocks s ON s.stock_id = hp.stock_id
+		WHERE s.exchange = $1 AND hp.time BETWEEN $2 AND $3 GROUP BY day`
+
+	rows, err := d.Query(query, exchange, from, to)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query trading days: %w", err)
+	}
+	defer rows.Close()
+
+	counts := make(map[time.Time]int)
+	for rows.Next() {
+		var day time.Time
+		var n int
+		if err := rows.Scan(&day, &n); err != nil {
+			log.Printf("Error scanning trading day row: %v", err)
+			continue
+		}
+		counts[time.Date(day.Year(), day.Month(), day.Day(), 0, 0, 0, 0, time.UTC)] = n
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating trading day rows: %w", err)
+	}
+
+	return counts, nil
+}
+
+// MaxBackfillAttempts is how often a failed backfill is queued again before it is given up
+const MaxBackfillAttempts = 3
+
+// QueueBackfillJob queues a backfill unless the same range is already queued, done or has
+// failed too often. It returns whether the job was queued.
+func (d *DB) QueueBackfillJob(job *models.BackfillJob) (bool, error) {
+	query := `INSERT INTO backfill_jobs (job_id, stock_id, data_type, range_start, range_end, status, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+		ON CONFLICT (stock_id, data_type, range_start, range_end) DO UPDATE SET status = EXCLUDED.status, updated_at = NOW()
+		WHERE backfill_jobs.status = $9 AND backfill_jobs.attempts < $10
+		RETURNING job_id, status, attempts, created_at`
+
+	job.JobID = uuid.New()
+	job.Status = models.BackfillPending
+	job.CreatedAt = time.Now()
+	job.UpdatedAt = time.Now()
+
+	err := d.QueryRow(query, job.JobID, job.StockID, job.DataType, job.RangeStart, job.RangeEnd, job.Status,
+		job.CreatedAt, job.UpdatedAt, models.BackfillFailed, MaxBackfillAttempts).Scan(&job.JobID, &job.Status, &job.Attempts, &job.CreatedAt)
+	if err == sql.ErrNoRows {
+		return false, nil
+	} else if err != nil {
+		return false, fmt.Errorf("failed to queue backfill job: %w", err)
+	}
+	return true, nil
+}
+
+// backfillJobColumns lists the backfill_jobs columns, joined with the stock symbol, in the
+// order scanBackfillJob expects them
+const backfillJobColumns = `j.job_id, j.stock_id, s.symbol, j.data_type, j.range_start, j.range_end, j.status, j.attempts,
+	j.rows_ingested, COALESCE(j.last_error, ''), j.created_at, j.updated_at`
+
+// GetBackfillJobs retrieves backfill jobs with a status (any when empty), newest first
+func (d *DB) GetBackfillJobs(status string, limit int) ([]models.BackfillJob, error) {
+	query := `SELECT ` + backfillJobColumns + `
+		FROM backfill_jobs j JOIN stocks s ON s.stock_id = j.stock_id
+		WHERE $1 = '' OR j.status = $1 ORDER BY j.created_at DESC LIMIT $2`
+	return d.queryBackfillJobs(query, status, limit)
+}
+
+// ClaimBackfillJobs marks up to limit pending jobs as running and returns them, oldest
+// first. Jobs left running for an hour, e.g. by a crashed worker, are claimed again.
+func (d *DB) ClaimBackfillJobs(limit int) ([]models.BackfillJob, error) {
+	query := `WITH claimed AS (
+			UPDATE backfill_jobs SET status = $1, attempts = attempts + 1, updated_at = NOW()
+			WHERE job_id IN (
+				SELECT job_id FROM backfill_jobs
+				WHERE status = $2 OR (status = $1 AND updated_at < NOW() - INTERVAL '1 hour')
+				ORDER BY created_at LIMIT $3 FOR UPDATE SKIP LOCKED)
+			RETURNING *)
+		SELECT ` + backfillJobColumns + `
+		FROM claimed j JOIN stocks s ON s.stock_id = j.stock_id ORDER BY j.created_at`
+	return d.queryBackfillJobs(query, models.BackfillRunning, models.BackfillPending, limit)
+}
+
+// FinishBackfillJob records the outcome of a backfill job
+func (d *DB) FinishBackfillJob(jobID uuid.UUID, rows int, jobErr error) error {
+	status, lastError := models.BackfillDone, sql.NullString{}
+	if jobErr != nil {
+		status, lastError = models.BackfillFailed, sql.NullString{String: jobErr.Error(), Valid: true}
+	}
+	_, err := d.Exec(`UPDATE backfill_jobs SET status = $2, rows_ingested = $3, last_error = $4, updated_at = NOW() WHERE job_id = $1`,
+		jobID, status, rows, lastError)
+	if err != nil {
+		return fmt.Errorf("failed to finish backfill job: %w", err)
+	}
+	return nil
+}
+
+func (d *DB) queryBackfillJobs(query string, args ...interface{}) ([]models.BackfillJob, error) {
+	rows, err := d.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query backfill jobs: %w", err)
+	}
+	defer rows.Close()
+
+	jobs := []models.BackfillJob{}
+	for rows.Next() {
+		var job models.BackfillJob
+		err := rows.Scan(&job.JobID, &job.StockID, &job.Symbol, &job.DataType, &job.RangeStart, &job.RangeEnd, &job.Status,
+			&job.Attempts, &job.RowsIngested, &job.LastError, &job.CreatedAt, &job.UpdatedAt)
+		if err != nil {
+			log.Printf("Error scanning backfill job row: %v", err)
+			continue
+		}
+		jobs = append(jobs, job)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating backfill jobs rows: %w", err)
+	}
+
+	return jobs, nil
+}
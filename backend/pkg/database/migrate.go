@@ -0,0 +1,119 @@
+package database
+
+import (
+	"embed"
+	"fmt"
+	"log"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// migrationFiles holds the schema migrations applied on top of database/schema.sql, named
+// NNNN_description.sql. They upgrade databases created from older versions of schema.sql,
+// so each must be a no-op where schema.sql already created its objects (IF NOT EXISTS,
+// if_not_exists => true).
+//
+//go:embed migrations/*.sql
+var migrationFiles embed.FS
+
+// migrationLock is the advisory lock key serializing migrations across backend instances
+const migrationLock = 7244011
+
+// migration is a versioned SQL script
+type migration struct {
+	Version int
+	Name    string
+	SQL     string
+}
+
+// Migrate applies the pending migrations in version order, each in its own transaction,
+// and records them in schema_migrations
+func (d *DB) Migrate() error {
+	_, err := d.Exec(`CREATE TABLE IF NOT EXISTS schema_migrations (
+		version INTEGER PRIMARY KEY,
+		name TEXT NOT NULL,
+		applied_at TIMESTAMPTZ DEFAULT NOW()
+	)`)
+	if err != nil {
+		return fmt.Errorf("failed to create schema_migrations: %w", err)
+	}
+
+	migrations, err := loadMigrations()
+	if err != nil {
+		return err
+	}
+	for _, m := range migrations {
+		applied, err := d.applyMigration(m)
+		if err != nil {
+			return err
+		}
+		if applied {
+			log.Printf("Applied migration %04d_%s", m.Version, m.Name)
+		}
+	}
+	return nil
+}
+
+// applyMigration runs a migration unless another instance already applied it
+func (d *DB) applyMigration(m migration) (bool, error) {
+	tx, err := d.Begin()
+	if err != nil {
+		return false, fmt.Errorf("failed to begin migration %d: %w", m.Version, err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(`SELECT pg_advisory_xact_lock($1)`, migrationLock); err != nil {
+		return false, fmt.Errorf("failed to lock migrations: %w", err)
+	}
+	var applied bool
+	if err := tx.QueryRow(`SELECT EXISTS (SELECT 1 FROM schema_migrations WHERE version = $1)`, m.Version).Scan(&applied); err != nil {
+		return false, fmt.Errorf("failed to check migration %d: %w", m.Version, err)
+	}
+	if applied {
+		return false, nil
+	}
+
+	if _, err := tx.Exec(m.SQL); err != nil {
+		return false, fmt.Errorf("failed to apply migration %04d_%s: %w", m.Version, m.Name, err)
+	}
+	if _, err := tx.Exec(`INSERT INTO schema_migrations (version, name) VALUES ($1, $2)`, m.Version, m.Name); err != nil {
+		return false, fmt.Errorf("failed to record migration %d: %w", m.Version, err)
+	}
+	if err := tx.Commit(); err != nil {
+		return false, fmt.Errorf("failed to commit migration %d: %w", m.Version, err)
+	}
+	return true, nil
+}
+
+// loadMigrations reads the embedded migrations ordered by version
+func loadMigrations() ([]migration, error) {
+	entries, err := migrationFiles.ReadDir("migrations")
+	if err != nil {
+		return nil, fmt.Errorf("failed to read migrations: %w", err)
+	}
+
+	var migrations []migration
+	seen := map[int]string{}
+	for _, e := range entries {
+		base := strings.TrimSuffix(e.Name(), ".sql")
+		prefix, name, ok := strings.Cut(base, "_")
+		version, err := strconv.Atoi(prefix)
+		if !ok || err != nil || version <= 0 {
+			return nil, fmt.Errorf("invalid migration file name %q, expected NNNN_description.sql", e.Name())
+		}
+		if other, dup := seen[version]; dup {
+			return nil, fmt.Errorf("migrations %q and %q share version %d", other, e.Name(), version)
+		}
+		seen[version] = e.Name()
+
+		sql, err := migrationFiles.ReadFile(path.Join("migrations", e.Name()))
+		if err != nil {
+			return nil, fmt.Errorf("failed to read migration %s: %w", e.Name(), err)
+		}
+		migrations = append(migrations, migration{Version: version, Name: name, SQL: string(sql)})
+	}
+	sort.Slice(migrations, func(i, j int) bool { return migrations[i].Version < migrations[j].Version })
+	return migrations, nil
+}
@@ -0,0 +1,24 @@
+-- Statement items and ratios added after the initial schema
+ALTER TABLE financial_statements
+    ADD COLUMN IF NOT EXISTS filing_date DATE,
+    ADD COLUMN IF NOT EXISTS accepted_date TIMESTAMPTZ,
+    ADD COLUMN IF NOT EXISTS gross_profit NUMERIC(20, 2),
+    ADD COLUMN IF NOT EXISTS operating_income NUMERIC(20, 2),
+    ADD COLUMN IF NOT EXISTS ebitda NUMERIC(20, 2),
+    ADD COLUMN IF NOT EXISTS income_before_tax NUMERIC(20, 2),
+    ADD COLUMN IF NOT EXISTS income_tax_expense NUMERIC(20, 2),
+    ADD COLUMN IF NOT EXISTS total_debt NUMERIC(20, 2),
+    ADD COLUMN IF NOT EXISTS cash_and_equivalents NUMERIC(20, 2),
+    ADD COLUMN IF NOT EXISTS shares_outstanding NUMERIC(20, 2),
+    ADD COLUMN IF NOT EXISTS current_assets NUMERIC(20, 2),
+    ADD COLUMN IF NOT EXISTS current_liabilities NUMERIC(20, 2),
+    ADD COLUMN IF NOT EXISTS long_term_debt NUMERIC(20, 2),
+    ADD COLUMN IF NOT EXISTS retained_earnings NUMERIC(20, 2),
+    ADD COLUMN IF NOT EXISTS operating_cash_flow NUMERIC(20, 2),
+    ADD COLUMN IF NOT EXISTS p_s_ratio NUMERIC(10, 4),
+    ADD COLUMN IF NOT EXISTS ev_to_ebitda NUMERIC(10, 4),
+    ADD COLUMN IF NOT EXISTS fcf_yield NUMERIC(10, 4),
+    ADD COLUMN IF NOT EXISTS roe NUMERIC(10, 4),
+    ADD COLUMN IF NOT EXISTS gross_margin NUMERIC(10, 4),
+    ADD COLUMN IF NOT EXISTS operating_margin NUMERIC(10, 4),
+    ADD COLUMN IF NOT EXISTS net_margin NUMERIC(10, 4);
@@ -0,0 +1,21 @@
+-- Restatements are kept as revisions of a statement, each known from its publication.
+-- Existing statements become revision 1, known from their estimated publication time
+-- (fundamentals.PublicationTime).
+ALTER TABLE financial_statements
+    ADD COLUMN IF NOT EXISTS revision INTEGER NOT NULL DEFAULT 1,
+    ADD COLUMN IF NOT EXISTS known_since TIMESTAMPTZ;
+
+UPDATE financial_statements SET known_since = COALESCE(
+    accepted_date,
+    filing_date::timestamp AT TIME ZONE 'UTC',
+    (date + CASE WHEN period = 'annual' THEN 90 ELSE 45 END)::timestamp AT TIME ZONE 'UTC')
+WHERE known_since IS NULL;
+
+ALTER TABLE financial_statements ALTER COLUMN known_since SET NOT NULL;
+
+-- Named like the constraint schema.sql creates, so that fresh databases skip it
+CREATE UNIQUE INDEX IF NOT EXISTS financial_statements_stock_id_date_period_revision_key
+    ON financial_statements (stock_id, date, period, revision);
+ALTER TABLE financial_statements DROP CONSTRAINT IF EXISTS financial_statements_stock_id_date_period_key;
+
+CREATE INDEX IF NOT EXISTS idx_financial_statements_known_since ON financial_statements (stock_id, period, known_since);
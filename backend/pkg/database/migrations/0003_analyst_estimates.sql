@@ -0,0 +1,21 @@
+-- Consensus estimate snapshots, one per estimated period per day
+CREATE TABLE IF NOT EXISTS analyst_estimates (
+    estimate_id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    stock_id UUID NOT NULL REFERENCES stocks(stock_id),
+    fiscal_date DATE NOT NULL,
+    period TEXT NOT NULL,
+    as_of DATE NOT NULL,
+    revenue_low NUMERIC(20, 2),
+    revenue_avg NUMERIC(20, 2),
+    revenue_high NUMERIC(20, 2),
+    ebitda_avg NUMERIC(20, 2),
+    net_income_avg NUMERIC(20, 2),
+    eps_low NUMERIC(10, 4),
+    eps_avg NUMERIC(10, 4),
+    eps_high NUMERIC(10, 4),
+    revenue_analysts INTEGER,
+    eps_analysts INTEGER,
+    created_at TIMESTAMPTZ DEFAULT NOW(),
+    updated_at TIMESTAMPTZ DEFAULT NOW(),
+    UNIQUE (stock_id, fiscal_date, period, as_of)
+);
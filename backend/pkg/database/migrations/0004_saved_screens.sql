@@ -0,0 +1,13 @@
+-- Screener expressions saved per user
+CREATE TABLE IF NOT EXISTS saved_screens (
+    screen_id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    user_id TEXT NOT NULL,
+    name TEXT NOT NULL,
+    query TEXT NOT NULL,
+    sort_by TEXT NOT NULL,
+    sort_order TEXT NOT NULL,
+    result_limit INTEGER NOT NULL,
+    created_at TIMESTAMPTZ DEFAULT NOW(),
+    updated_at TIMESTAMPTZ DEFAULT NOW(),
+    UNIQUE (user_id, name)
+);
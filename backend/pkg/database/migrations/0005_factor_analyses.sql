@@ -0,0 +1,9 @@
+-- Cache of factor IC analyses, keyed by a hash of the normalized request
+CREATE TABLE IF NOT EXISTS factor_analyses (
+    analysis_id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    cache_key TEXT NOT NULL UNIQUE,
+    request JSONB NOT NULL,
+    result JSONB NOT NULL,
+    created_at TIMESTAMPTZ DEFAULT NOW(),
+    updated_at TIMESTAMPTZ DEFAULT NOW()
+);
@@ -0,0 +1,23 @@
+-- Splits and dividends; prices are stored unadjusted and adjusted on read
+CREATE TABLE IF NOT EXISTS stock_splits (
+    stock_id UUID NOT NULL REFERENCES stocks(stock_id),
+    date DATE NOT NULL,
+    numerator DOUBLE PRECISION NOT NULL,
+    denominator DOUBLE PRECISION NOT NULL,
+    created_at TIMESTAMPTZ DEFAULT NOW(),
+    updated_at TIMESTAMPTZ DEFAULT NOW(),
+    PRIMARY KEY (stock_id, date)
+);
+
+CREATE TABLE IF NOT EXISTS dividends (
+    stock_id UUID NOT NULL REFERENCES stocks(stock_id),
+    ex_date DATE NOT NULL,
+    amount DOUBLE PRECISION NOT NULL,
+    adjusted_amount DOUBLE PRECISION,
+    record_date DATE,
+    payment_date DATE,
+    declaration_date DATE,
+    created_at TIMESTAMPTZ DEFAULT NOW(),
+    updated_at TIMESTAMPTZ DEFAULT NOW(),
+    PRIMARY KEY (stock_id, ex_date)
+);
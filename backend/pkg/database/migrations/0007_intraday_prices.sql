@@ -0,0 +1,16 @@
+-- Bars shorter than a day, in one-day chunks
+CREATE TABLE IF NOT EXISTS intraday_prices (
+    time TIMESTAMPTZ NOT NULL,
+    stock_id UUID NOT NULL REFERENCES stocks(stock_id),
+    bar_interval TEXT NOT NULL,
+    open_price DOUBLE PRECISION,
+    high_price DOUBLE PRECISION,
+    low_price DOUBLE PRECISION,
+    close_price DOUBLE PRECISION,
+    volume BIGINT,
+    PRIMARY KEY (time, stock_id, bar_interval)
+);
+
+SELECT create_hypertable('intraday_prices', 'time', 'stock_id', number_partitions => 4,
+    chunk_time_interval => INTERVAL '1 day', if_not_exists => true);
+CREATE INDEX IF NOT EXISTS idx_intraday_prices_stock_interval ON intraday_prices (stock_id, bar_interval, time DESC);
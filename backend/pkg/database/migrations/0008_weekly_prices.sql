@@ -0,0 +1,22 @@
+-- Weekly OHLCV bars (weeks start on Monday), maintained from historical_prices.
+-- Real-time aggregation serves the buckets the refresh policy has not materialized yet.
+CREATE MATERIALIZED VIEW IF NOT EXISTS weekly_prices
+WITH (timescaledb.continuous, timescaledb.materialized_only = false) AS
+SELECT
+    time_bucket(INTERVAL '1 week', time) AS bucket,
+    stock_id,
+    first(open_price, time) AS open_price,
+    max(high_price) AS high_price,
+    min(low_price) AS low_price,
+    last(close_price, time) AS close_price,
+    sum(volume) AS volume
+FROM historical_prices
+GROUP BY bucket, stock_id
+WITH NO DATA;
+
+-- No start offset, so backfilled history is rematerialized too
+SELECT add_continuous_aggregate_policy('weekly_prices',
+    start_offset => NULL,
+    end_offset => INTERVAL '1 day',
+    schedule_interval => INTERVAL '1 hour',
+    if_not_exists => true);
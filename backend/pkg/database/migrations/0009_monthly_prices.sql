@@ -0,0 +1,20 @@
+-- Monthly OHLCV bars, maintained from historical_prices like weekly_prices
+CREATE MATERIALIZED VIEW IF NOT EXISTS monthly_prices
+WITH (timescaledb.continuous, timescaledb.materialized_only = false) AS
+SELECT
+    time_bucket(INTERVAL '1 month', time) AS bucket,
+    stock_id,
+    first(open_price, time) AS open_price,
+    max(high_price) AS high_price,
+    min(low_price) AS low_price,
+    last(close_price, time) AS close_price,
+    sum(volume) AS volume
+FROM historical_prices
+GROUP BY bucket, stock_id
+WITH NO DATA;
+
+SELECT add_continuous_aggregate_policy('monthly_prices',
+    start_offset => NULL,
+    end_offset => INTERVAL '1 day',
+    schedule_interval => INTERVAL '1 hour',
+    if_not_exists => true);
@@ -0,0 +1,26 @@
+-- Compress price chunks once they fall outside the ranges ingestion rewrites: daily prices
+-- are re-ingested for the last year, intraday bars for the last few days. Segmenting by
+-- stock keeps per-stock range scans cheap on compressed chunks. Compression settings
+-- cannot change once chunks are compressed, so they are only set the first time.
+DO $$
+BEGIN
+    IF NOT (SELECT compression_enabled FROM timescaledb_information.hypertables
+            WHERE hypertable_name = 'historical_prices') THEN
+        ALTER TABLE historical_prices SET (
+            timescaledb.compress,
+            timescaledb.compress_segmentby = 'stock_id',
+            timescaledb.compress_orderby = 'time DESC'
+        );
+    END IF;
+    IF NOT (SELECT compression_enabled FROM timescaledb_information.hypertables
+            WHERE hypertable_name = 'intraday_prices') THEN
+        ALTER TABLE intraday_prices SET (
+            timescaledb.compress,
+            timescaledb.compress_segmentby = 'stock_id, bar_interval',
+            timescaledb.compress_orderby = 'time DESC'
+        );
+    END IF;
+END $$;
+
+SELECT add_compression_policy('historical_prices', INTERVAL '12 months', if_not_exists => true);
+SELECT add_compression_policy('intraday_prices', INTERVAL '1 month', if_not_exists => true);
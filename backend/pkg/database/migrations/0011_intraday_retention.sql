@@ -0,0 +1,2 @@
+-- Drop intraday bars after six months; daily history is kept indefinitely
+SELECT add_retention_policy('intraday_prices', INTERVAL '180 days', if_not_exists => true);
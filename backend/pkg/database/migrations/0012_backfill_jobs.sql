@@ -0,0 +1,16 @@
+-- Queue of targeted re-ingestions for missing price data
+CREATE TABLE IF NOT EXISTS backfill_jobs (
+    job_id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    stock_id UUID NOT NULL REFERENCES stocks(stock_id),
+    data_type TEXT NOT NULL,
+    range_start DATE NOT NULL,
+    range_end DATE NOT NULL,
+    status TEXT NOT NULL DEFAULT 'pending',
+    attempts INTEGER NOT NULL DEFAULT 0,
+    rows_ingested INTEGER NOT NULL DEFAULT 0,
+    last_error TEXT,
+    created_at TIMESTAMPTZ DEFAULT NOW(),
+    updated_at TIMESTAMPTZ DEFAULT NOW(),
+    UNIQUE (stock_id, data_type, range_start, range_end)
+);
+CREATE INDEX IF NOT EXISTS idx_backfill_jobs_status ON backfill_jobs (status, created_at);
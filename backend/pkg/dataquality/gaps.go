@@ -0,0 +1,83 @@
+// Package dataquality checks stored price history against exchange calendars to find the
+// sessions a stock has no bar for.
+package dataquality
+
+import (
+	"sort"
+	"time"
+
+	"stockpick-backend/pkg/calendar"
+)
+
+// Gap is a run of consecutive sessions without a bar
+type Gap struct {
+	From     time.Time `json:"from"` // First missing session
+	To       time.Time `json:"to"`   // Last missing session
+	Sessions int       `json:"sessions"`
+}
+
+// Report is the result of checking a stock's daily bars against its calendar
+type Report struct {
+	Calendar string    `json:"calendar"`
+	From     time.Time `json:"from"`
+	To       time.Time `json:"to"`
+	Sessions int       `json:"sessions"` // Sessions expected between From and To
+	Missing  int       `json:"missing"`
+	Extra    int       `json:"extra"` // Bars on days the calendar has no session
+	Gaps     []Gap     `json:"gaps"`
+}
+
+// Check compares the times of daily bars with the sessions of a calendar between two days,
+// both included. Missing sessions are grouped into gaps of consecutive sessions, so that
+// each gap can be backfilled with one request.
+func Check(cal calendar.Calendar, bars []time.Time, from, to time.Time) Report {
+	r := Report{Calendar: cal.Name(), From: calendar.Date(from), To: calendar.Date(to), Gaps: []Gap{}}
+	have := make(map[time.Time]bool, len(bars))
+	for _, b := range bars {
+		day := calendar.Date(b.UTC())
+		if day.Before(r.From) || day.After(r.To) {
+			continue
+		}
+		have[day] = true
+		if _, ok := cal.Session(day); !ok {
+			r.Extra++
+		}
+	}
+
+	var current *Gap
+	for _, s := range calendar.Sessions(cal, r.From, r.To) {
+		r.Sessions++
+		if have[s.Date] {
+			current = nil
+			continue
+		}
+		r.Missing++
+		if current == nil {
+			r.Gaps = append(r.Gaps, Gap{From: s.Date})
+			current = &r.Gaps[len(r.Gaps)-1]
+		}
+		current.To = s.Date
+		current.Sessions++
+	}
+	return r
+}
+
+// ObservedSessions derives the trading days of an exchange without calendar rules from
+// the number of its stocks with a bar on each day: days traded by at least minShare of the
+// busiest day's count, so that a few stray bars do not make a session
+func ObservedSessions(counts map[time.Time]int, minShare float64) []time.Time {
+	busiest := 0
+	for _, n := range counts {
+		if n > busiest {
+			busiest = n
+		}
+	}
+	var days []time.Time
+	for day, n := range counts {
+		if float64(n) >= minShare*float64(busiest) {
+			days = append(days, day)
+		}
+	}
+	sort.Slice(days, func(i, j int) bool { return days[i].Before(days[j]) })
+	return days
+}
@@ -0,0 +1,101 @@
+package dataquality
+
+import (
+	"testing"
+	"time"
+
+	"stockpick-backend/pkg/calendar"
+)
+
+func day(s string) time.Time {
+	t, err := time.Parse("2006-01-02", s)
+	if err != nil {
+		panic(err)
+	}
+	return t
+}
+
+func days(ss ...string) []time.Time {
+	var out []time.Time
+	for _, s := range ss {
+		out = append(out, day(s))
+	}
+	return out
+}
+
+func TestCheck(t *testing.T) {
+	// Thanksgiving week of 2024: the 28th is a holiday, the 29th closes early
+	from, to := day("2024-11-25"), day("2024-12-03")
+	tests := []struct {
+		name    string
+		bars    []time.Time
+		missing int
+		extra   int
+		gaps    [][2]string
+	}{
+		{
+			name: "complete",
+			bars: days("2024-11-25", "2024-11-26", "2024-11-27", "2024-11-29", "2024-12-02", "2024-12-03"),
+		},
+		{
+			name:    "gap across the holiday",
+			bars:    days("2024-11-25", "2024-11-26", "2024-12-02", "2024-12-03"),
+			missing: 2,
+			gaps:    [][2]string{{"2024-11-27", "2024-11-29"}},
+		},
+		{
+			name:    "gap across the weekend",
+			bars:    days("2024-11-25", "2024-11-26", "2024-11-27", "2024-12-03"),
+			missing: 2,
+			gaps:    [][2]string{{"2024-11-29", "2024-12-02"}},
+		},
+		{
+			name:    "separate gaps",
+			bars:    days("2024-11-25", "2024-11-27", "2024-11-29", "2024-12-03"),
+			missing: 2,
+			gaps:    [][2]string{{"2024-11-26", "2024-11-26"}, {"2024-12-02", "2024-12-02"}},
+		},
+		{
+			name:  "bars on the holiday and outside the range",
+			bars:  days("2024-11-22", "2024-11-25", "2024-11-26", "2024-11-27", "2024-11-28", "2024-11-29", "2024-12-02", "2024-12-03"),
+			extra: 1,
+		},
+	}
+	for _, tt := range tests {
+		r := Check(calendar.NYSE, tt.bars, from, to)
+		if r.Sessions != 6 {
+			t.Errorf("%s: %d sessions, want 6", tt.name, r.Sessions)
+		}
+		if r.Missing != tt.missing || r.Extra != tt.extra {
+			t.Errorf("%s: missing %d and extra %d, want %d and %d", tt.name, r.Missing, r.Extra, tt.missing, tt.extra)
+		}
+		if len(r.Gaps) != len(tt.gaps) {
+			t.Errorf("%s: %d gaps, want %d", tt.name, len(r.Gaps), len(tt.gaps))
+			continue
+		}
+		for i, g := range r.Gaps {
+			if !g.From.Equal(day(tt.gaps[i][0])) || !g.To.Equal(day(tt.gaps[i][1])) {
+				t.Errorf("%s: gap %d is %s to %s, want %s to %s", tt.name, i,
+					g.From.Format("2006-01-02"), g.To.Format("2006-01-02"), tt.gaps[i][0], tt.gaps[i][1])
+			}
+		}
+	}
+}
+
+func TestObservedSessions(t *testing.T) {
+	counts := map[time.Time]int{
+		day("2024-06-12"): 100,
+		day("2024-06-13"): 95,
+		day("2024-06-15"): 3, // A few stray weekend bars
+	}
+	got := ObservedSessions(counts, 0.5)
+	want := days("2024-06-12", "2024-06-13")
+	if len(got) != len(want) {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+	for i := range want {
+		if !got[i].Equal(want[i]) {
+			t.Errorf("session %d is %v, want %v", i, got[i], want[i])
+		}
+	}
+}
@@ -0,0 +1,232 @@
+package dcf
+
+import (
+	"fmt"
+	"math"
+
+	"stockpick-backend/pkg/growth"
+	"stockpick-backend/pkg/models"
+	"stockpick-backend/pkg/ratios"
+)
+
+// Default market assumptions, overridable per request
+const (
+	DefaultRiskFreeRate      = 0.04
+	DefaultEquityRiskPremium = 0.055
+	DefaultBeta              = 1.0
+	DefaultCostOfDebt        = 0.06
+	DefaultTerminalGrowth    = 0.025
+)
+
+// Bounds applied to the first-stage growth rate derived from history, so that one
+// exceptional year does not dominate the projection.
+const (
+	minDerivedGrowth = -0.05
+	maxDerivedGrowth = 0.15
+)
+
+// normalizationYears is how many annual free cash flows are averaged into the base FCF
+const normalizationYears = 3
+
+// Stage is a projection stage with a constant annual free cash flow growth rate
+type Stage struct {
+	Years  int     `json:"years"`
+	Growth float64 `json:"growth"`
+}
+
+// Assumptions drive a DCF valuation
+type Assumptions struct {
+	Stages            []Stage `json:"stages"`
+	TerminalGrowth    float64 `json:"terminal_growth"`
+	RiskFreeRate      float64 `json:"risk_free_rate"`
+	EquityRiskPremium float64 `json:"equity_risk_premium"`
+	Beta              float64 `json:"beta"`
+	CostOfDebt        float64 `json:"cost_of_debt"` // Pre-tax
+	TaxRate           float64 `json:"tax_rate"`
+	DiscountRate      float64 `json:"discount_rate"` // Overrides WACC when positive
+	BaseFCF           float64 `json:"base_fcf"`      // Overrides the normalized historical FCF when positive
+	FCFMargin         float64 `json:"fcf_margin"`    // When positive, base FCF is latest revenue times this margin
+}
+
+// Company holds the company figures a valuation runs on
+type Company struct {
+	BaseFCF           float64 `json:"base_fcf"`
+	Revenue           float64 `json:"revenue"`
+	TotalDebt         float64 `json:"total_debt"`
+	Cash              float64 `json:"cash"`
+	SharesOutstanding float64 `json:"shares_outstanding"`
+}
+
+// Projection is one projected year of free cash flow
+type Projection struct {
+	Year         int     `json:"year"`
+	Growth       float64 `json:"growth"`
+	FCF          float64 `json:"fcf"`
+	PresentValue float64 `json:"present_value"`
+}
+
+// Result is the outcome of a DCF valuation
+type Result struct {
+	Assumptions            Assumptions  `json:"assumptions"`
+	Company                Company      `json:"company"`
+	DiscountRate           float64      `json:"discount_rate"`
+	Projections            []Projection `json:"projections"`
+	TerminalValue          float64      `json:"terminal_value"`
+	PresentTerminalValue   float64      `json:"present_terminal_value"`
+	EnterpriseValue        float64      `json:"enterprise_value"`
+	EquityValue            float64      `json:"equity_value"`
+	IntrinsicValuePerShare float64      `json:"intrinsic_value_per_share"`
+	Price                  float64      `json:"price"`
+	MarginOfSafety         float64      `json:"margin_of_safety"` // (value - price) / value
+}
+
+// DefaultAssumptions builds assumptions from annual statements ordered newest first:
+// a five-year stage at the historical FCF (or revenue) growth rate, a five-year stage
+// fading halfway to terminal growth, and default market rates.
+func DefaultAssumptions(annual []models.FinancialStatement) Assumptions {
+	metrics := growth.Compute(annual, 0)
+	g, ok := metrics.FCF.Preferred()
+	if !ok {
+		g, ok = metrics.Revenue.Preferred()
+	}
+	if !ok {
+		g = DefaultTerminalGrowth
+	}
+	g = math.Max(minDerivedGrowth, math.Min(maxDerivedGrowth, g))
+
+	taxRate := ratios.DefaultTaxRate
+	if len(annual) > 0 {
+		taxRate = ratios.TaxRate(annual[0])
+	}
+
+	return Assumptions{
+		Stages: []Stage{
+			{Years: 5, Growth: g},
+			{Years: 5, Growth: (g + DefaultTerminalGrowth) / 2},
+		},
+		TerminalGrowth:    DefaultTerminalGrowth,
+		RiskFreeRate:      DefaultRiskFreeRate,
+		EquityRiskPremium: DefaultEquityRiskPremium,
+		Beta:              DefaultBeta,
+		CostOfDebt:        DefaultCostOfDebt,
+		TaxRate:           taxRate,
+	}
+}
+
+// CompanyFromStatements takes the balance sheet and share count from the latest annual
+// statement and normalizes base FCF as the average of up to three recent years.
+func CompanyFromStatements(annual []models.FinancialStatement) (Company, error) {
+	if len(annual) == 0 {
+		return Company{}, fmt.Errorf("no annual statements available")
+	}
+	latest := annual[0]
+
+	n := int(math.Min(float64(len(annual)), normalizationYears))
+	sum := 0.0
+	for _, fs := range annual[:n] {
+		sum += fs.FreeCashFlow
+	}
+
+	return Company{
+		BaseFCF:           sum / float64(n),
+		Revenue:           latest.Revenue,
+		TotalDebt:         latest.TotalDebt,
+		Cash:              latest.CashAndEquivalents,
+		SharesOutstanding: ratios.SharesOutstanding(latest),
+	}, nil
+}
+
+// CostOfEquity returns the CAPM cost of equity
+func (a Assumptions) CostOfEquity() float64 {
+	return a.RiskFreeRate + a.Beta*a.EquityRiskPremium
+}
+
+// WACC returns the weighted average cost of capital for the given market value of
+// equity and debt. Without a market value of equity it falls back to the cost of equity.
+func (a Assumptions) WACC(marketCap, debt float64) float64 {
+	ke := a.CostOfEquity()
+	if marketCap <= 0 || debt <= 0 {
+		return ke
+	}
+	total := marketCap + debt
+	return marketCap/total*ke + debt/total*a.CostOfDebt*(1-a.TaxRate)
+}
+
+// ResolveDiscountRate returns the explicit discount rate, or the WACC at the given share price
+func (a Assumptions) ResolveDiscountRate(c Company, price float64) float64 {
+	if a.DiscountRate > 0 {
+		return a.DiscountRate
+	}
+	return a.WACC(price*c.SharesOutstanding, c.TotalDebt)
+}
+
+// Evaluate runs the DCF for a company at the given share price.
+func Evaluate(c Company, price float64, a Assumptions) (*Result, error) {
+	if c.SharesOutstanding <= 0 {
+		return nil, fmt.Errorf("shares outstanding unknown")
+	}
+	if len(a.Stages) == 0 {
+		return nil, fmt.Errorf("at least one projection stage is required")
+	}
+
+	c.BaseFCF = baseFCF(c, a)
+	if c.BaseFCF <= 0 {
+		return nil, fmt.Errorf("base free cash flow is not positive")
+	}
+	return project(c, price, a)
+}
+
+// baseFCF is the free cash flow the projection starts from: the assumed base FCF, the
+// assumed margin on the latest revenue, or else the normalized historical FCF
+func baseFCF(c Company, a Assumptions) float64 {
+	if a.BaseFCF > 0 {
+		return a.BaseFCF
+	} else if a.FCFMargin > 0 {
+		return c.Revenue * a.FCFMargin
+	}
+	return c.BaseFCF
+}
+
+// project discounts the cash flows grown from c.BaseFCF through the stages and the
+// terminal value
+func project(c Company, price float64, a Assumptions) (*Result, error) {
+	r := a.ResolveDiscountRate(c, price)
+	if r <= a.TerminalGrowth {
+		return nil, fmt.Errorf("discount rate %.4f must exceed terminal growth %.4f", r, a.TerminalGrowth)
+	}
+
+	res := &Result{
+		Assumptions:  a,
+		Company:      c,
+		DiscountRate: r,
+		Price:        price,
+	}
+
+	fcf := c.BaseFCF
+	year := 0
+	presentValue := 0.0
+	for _, stage := range a.Stages {
+		for i := 0; i < stage.Years; i++ {
+			year++
+			fcf *= 1 + stage.Growth
+			pv := fcf / math.Pow(1+r, float64(year))
+			presentValue += pv
+			res.Projections = append(res.Projections, Projection{
+				Year:         year,
+				Growth:       stage.Growth,
+				FCF:          fcf,
+				PresentValue: pv,
+			})
+		}
+	}
+
+	res.TerminalValue = fcf * (1 + a.TerminalGrowth) / (r - a.TerminalGrowth)
+	res.PresentTerminalValue = res.TerminalValue / math.Pow(1+r, float64(year))
+	res.EnterpriseValue = presentValue + res.PresentTerminalValue
+	res.EquityValue = res.EnterpriseValue - c.TotalDebt + c.Cash
+	res.IntrinsicValuePerShare = res.EquityValue / c.SharesOutstanding
+	if res.IntrinsicValuePerShare > 0 {
+		res.MarginOfSafety = (res.IntrinsicValuePerShare - price) / res.IntrinsicValuePerShare
+	}
+	return res, nil
+}
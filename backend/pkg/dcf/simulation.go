@@ -0,0 +1,262 @@
+package dcf
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+	"runtime"
+	"sort"
+	"sync"
+)
+
+// MaxIterations caps the number of evaluations a single simulation may run
+const MaxIterations = 100000
+
+// chunkSize is the number of iterations drawn from one random source. Each chunk is
+// seeded from the simulation seed and its index, so results do not depend on how
+// chunks are scheduled across workers.
+const chunkSize = 500
+
+// Distribution is a normal distribution truncated to [Min, Max]
+type Distribution struct {
+	Mean   float64 `json:"mean"`
+	StdDev float64 `json:"std_dev"`
+	Min    float64 `json:"min"`
+	Max    float64 `json:"max"`
+}
+
+// Sample draws a value, clamping it to the distribution bounds
+func (d Distribution) Sample(rng *rand.Rand) float64 {
+	v := d.Mean + rng.NormFloat64()*d.StdDev
+	return math.Max(d.Min, math.Min(d.Max, v))
+}
+
+// SimulationConfig configures a Monte Carlo DCF simulation. The sampled growth replaces
+// the first stage growth and shifts later stages by the same amount; the sampled margin
+// sets the base FCF as a share of revenue.
+type SimulationConfig struct {
+	Iterations     int           `json:"iterations"`
+	Seed           int64         `json:"seed"`
+	Workers        int           `json:"-"`
+	Growth         Distribution  `json:"growth"`
+	FCFMargin      *Distribution `json:"fcf_margin"` // Nil when revenue is unknown
+	DiscountRate   Distribution  `json:"discount_rate"`
+	TerminalGrowth Distribution  `json:"terminal_growth"`
+}
+
+// Percentiles of the simulated intrinsic value per share
+type Percentiles struct {
+	P5  float64 `json:"p5"`
+	P10 float64 `json:"p10"`
+	P25 float64 `json:"p25"`
+	P50 float64 `json:"p50"`
+	P75 float64 `json:"p75"`
+	P90 float64 `json:"p90"`
+	P95 float64 `json:"p95"`
+}
+
+// SimulationResult summarizes the distribution of simulated intrinsic values per share
+type SimulationResult struct {
+	Config                 SimulationConfig `json:"config"`
+	Price                  float64          `json:"price"`
+	Valid                  int              `json:"valid"` // Iterations that produced a valuation
+	Mean                   float64          `json:"mean"`
+	StdDev                 float64          `json:"std_dev"`
+	Percentiles            Percentiles      `json:"percentiles"`
+	ProbabilityUndervalued float64          `json:"probability_undervalued"` // P(price < value)
+	Sensitivity            SensitivityGrid  `json:"sensitivity"`
+}
+
+// SensitivityGrid holds intrinsic value per share across discount rates (rows) and
+// terminal growth rates (columns). Cells where the model is undefined are nil.
+type SensitivityGrid struct {
+	DiscountRates   []float64    `json:"discount_rates"`
+	TerminalGrowths []float64    `json:"terminal_growths"`
+	Values          [][]*float64 `json:"values"`
+}
+
+// DefaultSimulationConfig centres the distributions on the base assumptions. Like Evaluate,
+// it fails when the base FCF is not positive, as a margin centred on it would project
+// hardly any cash flow.
+func DefaultSimulationConfig(c Company, price float64, base Assumptions) (SimulationConfig, error) {
+	fcf := baseFCF(c, base)
+	if fcf <= 0 {
+		return SimulationConfig{}, fmt.Errorf("base free cash flow is not positive")
+	}
+
+	firstGrowth := 0.0
+	if len(base.Stages) > 0 {
+		firstGrowth = base.Stages[0].Growth
+	}
+
+	cfg := SimulationConfig{
+		Iterations:     5000,
+		Seed:           1,
+		Growth:         Distribution{Mean: firstGrowth, StdDev: 0.03, Min: -0.2, Max: 0.4},
+		DiscountRate:   Distribution{Mean: base.ResolveDiscountRate(c, price), StdDev: 0.01, Min: 0.03, Max: 0.25},
+		TerminalGrowth: Distribution{Mean: base.TerminalGrowth, StdDev: 0.005, Min: 0, Max: 0.04},
+	}
+
+	if c.Revenue > 0 {
+		margin := fcf / c.Revenue
+		cfg.FCFMargin = &Distribution{Mean: margin, StdDev: margin * 0.25, Min: 0, Max: 1}
+	}
+	return cfg, nil
+}
+
+// Simulate runs cfg.Iterations DCF evaluations with sampled assumptions across
+// cfg.Workers goroutines. Results are reproducible for a given seed.
+func Simulate(c Company, price float64, base Assumptions, cfg SimulationConfig) (*SimulationResult, error) {
+	if cfg.Iterations <= 0 || cfg.Iterations > MaxIterations {
+		return nil, fmt.Errorf("iterations must be between 1 and %d", MaxIterations)
+	}
+	if len(base.Stages) == 0 {
+		return nil, fmt.Errorf("at least one projection stage is required")
+	}
+	if c.SharesOutstanding <= 0 {
+		return nil, fmt.Errorf("shares outstanding unknown")
+	}
+	if cfg.FCFMargin != nil && cfg.FCFMargin.Mean <= 0 {
+		return nil, fmt.Errorf("FCF margin mean must be positive")
+	} else if cfg.FCFMargin == nil && baseFCF(c, base) <= 0 {
+		return nil, fmt.Errorf("base free cash flow is not positive")
+	}
+	workers := cfg.Workers
+	if workers <= 0 {
+		workers = runtime.NumCPU()
+	}
+
+	values := make([]float64, cfg.Iterations)
+	chunks := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for chunk := range chunks {
+				rng := rand.New(rand.NewSource(cfg.Seed + int64(chunk)))
+				end := int(math.Min(float64((chunk+1)*chunkSize), float64(cfg.Iterations)))
+				for i := chunk * chunkSize; i < end; i++ {
+					values[i] = simulateOnce(c, price, base, cfg, rng)
+				}
+			}
+		}()
+	}
+	for chunk := 0; chunk*chunkSize < cfg.Iterations; chunk++ {
+		chunks <- chunk
+	}
+	close(chunks)
+	wg.Wait()
+
+	valid := values[:0]
+	for _, v := range values {
+		if !math.IsNaN(v) {
+			valid = append(valid, v)
+		}
+	}
+	if len(valid) == 0 {
+		return nil, fmt.Errorf("no simulated valuation was defined")
+	}
+	sort.Float64s(valid)
+
+	res := &SimulationResult{
+		Config: cfg,
+		Price:  price,
+		Valid:  len(valid),
+	}
+	undervalued := 0
+	for _, v := range valid {
+		res.Mean += v
+		if price < v {
+			undervalued++
+		}
+	}
+	res.Mean /= float64(len(valid))
+	for _, v := range valid {
+		res.StdDev += (v - res.Mean) * (v - res.Mean)
+	}
+	res.StdDev = math.Sqrt(res.StdDev / float64(len(valid)))
+	res.ProbabilityUndervalued = float64(undervalued) / float64(len(valid))
+	res.Percentiles = Percentiles{
+		P5:  percentile(valid, 0.05),
+		P10: percentile(valid, 0.10),
+		P25: percentile(valid, 0.25),
+		P50: percentile(valid, 0.50),
+		P75: percentile(valid, 0.75),
+		P90: percentile(valid, 0.90),
+		P95: percentile(valid, 0.95),
+	}
+
+	discount := base.ResolveDiscountRate(c, price)
+	res.Sensitivity = Sensitivity(c, price, base,
+		offsets(discount, 0.01, 2),
+		offsets(base.TerminalGrowth, 0.005, 2))
+	return res, nil
+}
+
+// Sensitivity evaluates the DCF over every pair of discount rate and terminal growth
+func Sensitivity(c Company, price float64, base Assumptions, discountRates, terminalGrowths []float64) SensitivityGrid {
+	grid := SensitivityGrid{
+		DiscountRates:   discountRates,
+		TerminalGrowths: terminalGrowths,
+		Values:          make([][]*float64, len(discountRates)),
+	}
+	for i, r := range discountRates {
+		grid.Values[i] = make([]*float64, len(terminalGrowths))
+		for j, g := range terminalGrowths {
+			a := base
+			a.DiscountRate = r
+			a.TerminalGrowth = g
+			if res, err := Evaluate(c, price, a); err == nil {
+				v := res.IntrinsicValuePerShare
+				grid.Values[i][j] = &v
+			}
+		}
+	}
+	return grid
+}
+
+// simulateOnce evaluates one sampled scenario, returning NaN when the model is undefined
+func simulateOnce(c Company, price float64, base Assumptions, cfg SimulationConfig, rng *rand.Rand) float64 {
+	a := base
+	a.Stages = make([]Stage, len(base.Stages))
+	shift := cfg.Growth.Sample(rng) - base.Stages[0].Growth
+	for i, stage := range base.Stages {
+		a.Stages[i] = Stage{Years: stage.Years, Growth: stage.Growth + shift}
+	}
+	a.DiscountRate = cfg.DiscountRate.Sample(rng)
+	a.TerminalGrowth = cfg.TerminalGrowth.Sample(rng)
+	// A sampled margin sets the base FCF even when it is clamped to 0, a scenario of its
+	// own rather than a fallback to the historical FCF
+	if cfg.FCFMargin != nil {
+		c.BaseFCF = c.Revenue * cfg.FCFMargin.Sample(rng)
+	} else if c.BaseFCF = baseFCF(c, a); c.BaseFCF <= 0 {
+		return math.NaN()
+	}
+
+	res, err := project(c, price, a)
+	if err != nil {
+		return math.NaN()
+	}
+	return res.IntrinsicValuePerShare
+}
+
+// percentile interpolates linearly within sorted values
+func percentile(sorted []float64, p float64) float64 {
+	if len(sorted) == 1 {
+		return sorted[0]
+	}
+	pos := p * float64(len(sorted)-1)
+	lo := int(math.Floor(pos))
+	hi := int(math.Ceil(pos))
+	return sorted[lo] + (sorted[hi]-sorted[lo])*(pos-float64(lo))
+}
+
+// offsets returns center plus/minus n steps
+func offsets(center, step float64, n int) []float64 {
+	values := make([]float64, 0, 2*n+1)
+	for i := -n; i <= n; i++ {
+		values = append(values, center+float64(i)*step)
+	}
+	return values
+}
@@ -0,0 +1,145 @@
+// Package dividends derives income metrics from the stored dividend history: trailing and
+// forward yield, payout ratios against earnings and free cash flow, dividend growth rates
+// and streaks. Per-share amounts are restated for splits so that they compare with the
+// latest price across the whole history.
+package dividends
+
+import (
+	"math"
+	"time"
+
+	"stockpick-backend/pkg/models"
+	"stockpick-backend/pkg/ratios"
+)
+
+// Metrics are the dividend metrics of a stock. Pointers are nil when the metric is
+// undefined, e.g. payout ratios of a loss-making company.
+type Metrics struct {
+	LastExDate       time.Time `json:"last_ex_date"`
+	Frequency        int       `json:"frequency"`         // Payments per year, from the last two years
+	TrailingDividend float64   `json:"trailing_dividend"` // Paid per share over the last twelve months
+	TrailingYield    *float64  `json:"trailing_yield"`
+	ForwardDividend  float64   `json:"forward_dividend"` // Latest payment annualized, 0 once payments stopped
+	ForwardYield     *float64  `json:"forward_yield"`
+	PayoutRatio      *float64  `json:"payout_ratio"`     // Dividends over the statement's year to its EPS
+	FCFPayoutRatio   *float64  `json:"fcf_payout_ratio"` // Dividends paid over the statement's year to its free cash flow
+	CAGR3            *float64  `json:"cagr_3y"`          // Growth of calendar-year dividends to the last full year
+	CAGR5            *float64  `json:"cagr_5y"`
+	CAGR10           *float64  `json:"cagr_10y"`
+	GrowthYears      int       `json:"growth_years"`  // Consecutive calendar years of higher dividends, to the last full year
+	YearsPaid        int       `json:"years_paid"`    // Consecutive calendar years with a dividend, to the last full year
+	HistoryYears     int       `json:"history_years"` // Calendar years from the first stored dividend to the last full year
+}
+
+// Compute derives the dividend metrics as of a time from the dividend history (any order),
+// the latest close and the statements of the payout basis, newest first (TTM or annual).
+// It returns false when no dividend was paid by asOf.
+func Compute(history []models.Dividend, statements []models.FinancialStatement, price float64, asOf time.Time) (Metrics, bool) {
+	var m Metrics
+	yearAgo, twoYearsAgo := asOf.AddDate(-1, 0, 0), asOf.AddDate(-2, 0, 0)
+	lastYear := asOf.Year() - 1
+	annual := map[int]float64{}
+	firstYear := 0
+	var firstExDate time.Time
+	latestAmount, recent := 0.0, 0
+	for _, d := range history {
+		amount := amount(d)
+		if amount <= 0 || d.ExDate.After(asOf) {
+			continue
+		}
+		if d.ExDate.After(m.LastExDate) {
+			m.LastExDate, latestAmount = d.ExDate, amount
+		}
+		if d.ExDate.After(yearAgo) {
+			m.TrailingDividend += amount
+		}
+		if d.ExDate.After(twoYearsAgo) {
+			recent++
+		}
+		if firstExDate.IsZero() || d.ExDate.Before(firstExDate) {
+			firstExDate = d.ExDate
+		}
+		year := d.ExDate.Year()
+		annual[year] += amount
+		if firstYear == 0 || year < firstYear {
+			firstYear = year
+		}
+	}
+	if m.LastExDate.IsZero() {
+		return m, false
+	}
+
+	if m.TrailingDividend > 0 {
+		// A history shorter than two years covers the span of its payments plus one
+		// interval, so that a new quarterly payer is not taken for a semi-annual one
+		window := 2.0
+		if recent > 1 && firstExDate.After(twoYearsAgo) {
+			span := m.LastExDate.Sub(firstExDate).Hours() / 24 / 365.25
+			window = math.Min(span*float64(recent)/float64(recent-1), window)
+		}
+		m.Frequency = int(math.Max(math.Round(float64(recent)/window), 1))
+		m.ForwardDividend = latestAmount * float64(m.Frequency)
+	}
+	if price > 0 {
+		m.TrailingYield = float(m.TrailingDividend / price)
+		m.ForwardYield = float(m.ForwardDividend / price)
+	}
+
+	if len(statements) > 0 {
+		// Per-share figures of the statement are as reported, so the dividends of its year
+		// are taken as paid rather than restated for later splits
+		fs := statements[0]
+		paid := 0.0
+		for _, d := range history {
+			if d.ExDate.After(fs.Date.AddDate(-1, 0, 0)) && !d.ExDate.After(fs.Date) {
+				paid += d.Amount
+			}
+		}
+		if fs.EPS > 0 {
+			m.PayoutRatio = float(paid / fs.EPS)
+		}
+		if shares := ratios.SharesOutstanding(fs); shares > 0 && fs.FreeCashFlow > 0 {
+			m.FCFPayoutRatio = float(paid * shares / fs.FreeCashFlow)
+		}
+	}
+
+	m.CAGR3 = cagr(annual, firstYear, lastYear, 3)
+	m.CAGR5 = cagr(annual, firstYear, lastYear, 5)
+	m.CAGR10 = cagr(annual, firstYear, lastYear, 10)
+	// The first year of the history may be partial, so it does not count as a year of growth
+	for y := lastYear; y-1 > firstYear && annual[y] > annual[y-1]*(1+1e-9); y-- {
+		m.GrowthYears++
+	}
+	for y := lastYear; annual[y] > 0; y-- {
+		m.YearsPaid++
+	}
+	if lastYear >= firstYear {
+		m.HistoryYears = lastYear - firstYear + 1
+	}
+	return m, true
+}
+
+// cagr is the compound growth of calendar-year dividends over the given number of years to
+// the last full year, nil unless the history covers the whole starting year
+func cagr(annual map[int]float64, firstYear, lastYear, years int) *float64 {
+	start, end := annual[lastYear-years], annual[lastYear]
+	if lastYear-years <= firstYear || start <= 0 || end <= 0 {
+		return nil
+	}
+	return float(math.Pow(end/start, 1/float64(years)) - 1)
+}
+
+// amount prefers the split-adjusted amount of a dividend
+func amount(d models.Dividend) float64 {
+	if d.AdjustedAmount > 0 {
+		return d.AdjustedAmount
+	}
+	return d.Amount
+}
+
+func float(v float64) *float64 {
+	if math.IsNaN(v) || math.IsInf(v, 0) {
+		return nil
+	}
+	return &v
+}
@@ -0,0 +1,142 @@
+package estimates
+
+import (
+	"time"
+
+	"stockpick-backend/pkg/models"
+)
+
+// Revision trends of the forward EPS consensus
+const (
+	TrendUp   = "up"
+	TrendDown = "down"
+	TrendFlat = "flat"
+)
+
+// flatRevision is the largest relative EPS revision still considered flat
+const flatRevision = 0.01
+
+// snapshotTolerance is how much older than the lookback date a snapshot may be
+const snapshotTolerance = 15 * 24 * time.Hour
+
+// Metrics are forward-looking measures derived from the consensus estimate of the next
+// fiscal year. A nil metric is unavailable.
+type Metrics struct {
+	FiscalDate            time.Time `json:"fiscal_date"` // End of the estimated fiscal year
+	AsOf                  time.Time `json:"as_of"`       // Date of the consensus snapshot used
+	ForwardEPS            *float64  `json:"forward_eps"`
+	ForwardRevenue        *float64  `json:"forward_revenue"`
+	ForwardPE             *float64  `json:"forward_pe"`
+	ExpectedEPSGrowth     *float64  `json:"expected_eps_growth"` // Forward EPS over the last reported annual EPS
+	ExpectedRevenueGrowth *float64  `json:"expected_revenue_growth"`
+	EPSRevision30D        *float64  `json:"eps_revision_30d"` // Relative change of the forward EPS consensus
+	EPSRevision90D        *float64  `json:"eps_revision_90d"`
+	RevisionTrend         string    `json:"revision_trend,omitempty"` // "up", "down" or "flat"
+	Analysts              int       `json:"analysts"`
+}
+
+// Compute derives forward metrics from annual estimate snapshots (any order), the reported
+// annual statements (newest first) and the current price. Only snapshots captured on or
+// before asOf are used. It returns false when no upcoming fiscal year is estimated.
+func Compute(snapshots []models.AnalystEstimate, annual []models.FinancialStatement, price float64, asOf time.Time) (Metrics, bool) {
+	// The forward year is the first estimated fiscal year after the last reported one
+	reportedThrough := asOf
+	if len(annual) > 0 {
+		reportedThrough = annual[0].Date
+	}
+
+	var current *models.AnalystEstimate
+	for i := range snapshots {
+		e := &snapshots[i]
+		if e.AsOf.After(asOf) || !e.FiscalDate.After(reportedThrough) {
+			continue
+		}
+		if current == nil || e.FiscalDate.Before(current.FiscalDate) ||
+			(e.FiscalDate.Equal(current.FiscalDate) && e.AsOf.After(current.AsOf)) {
+			current = e
+		}
+	}
+	if current == nil {
+		return Metrics{}, false
+	}
+
+	m := Metrics{
+		FiscalDate:     current.FiscalDate,
+		AsOf:           current.AsOf,
+		ForwardEPS:     nonZero(current.EPSAvg),
+		ForwardRevenue: nonZero(current.RevenueAvg),
+		Analysts:       current.EPSAnalysts,
+	}
+	if current.EPSAvg > 0 && price > 0 {
+		pe := price / current.EPSAvg
+		m.ForwardPE = &pe
+	}
+	if len(annual) > 0 {
+		m.ExpectedEPSGrowth = growth(current.EPSAvg, annual[0].EPS)
+		m.ExpectedRevenueGrowth = growth(current.RevenueAvg, annual[0].Revenue)
+	}
+
+	if prior, ok := priorSnapshot(snapshots, current, current.AsOf.AddDate(0, 0, -30)); ok {
+		m.EPSRevision30D = growth(current.EPSAvg, prior.EPSAvg)
+	}
+	if prior, ok := priorSnapshot(snapshots, current, current.AsOf.AddDate(0, 0, -90)); ok {
+		m.EPSRevision90D = growth(current.EPSAvg, prior.EPSAvg)
+	}
+	if revision, ok := m.Revision(); ok {
+		switch {
+		case revision > flatRevision:
+			m.RevisionTrend = TrendUp
+		case revision < -flatRevision:
+			m.RevisionTrend = TrendDown
+		default:
+			m.RevisionTrend = TrendFlat
+		}
+	}
+	return m, true
+}
+
+// Revision returns the 90-day forward EPS revision, falling back to 30 days
+func (m Metrics) Revision() (float64, bool) {
+	if m.EPSRevision90D != nil {
+		return *m.EPSRevision90D, true
+	}
+	if m.EPSRevision30D != nil {
+		return *m.EPSRevision30D, true
+	}
+	return 0, false
+}
+
+// priorSnapshot returns the latest snapshot of the same fiscal year captured on or before
+// the date, provided it is not much older than the date
+func priorSnapshot(snapshots []models.AnalystEstimate, current *models.AnalystEstimate, date time.Time) (models.AnalystEstimate, bool) {
+	var prior *models.AnalystEstimate
+	for i := range snapshots {
+		e := &snapshots[i]
+		if !e.FiscalDate.Equal(current.FiscalDate) || e.AsOf.After(date) || date.Sub(e.AsOf) > snapshotTolerance {
+			continue
+		}
+		if prior == nil || e.AsOf.After(prior.AsOf) {
+			prior = e
+		}
+	}
+	if prior == nil {
+		return models.AnalystEstimate{}, false
+	}
+	return *prior, true
+}
+
+// growth is the relative change from base to value, unavailable for a non-positive base
+func growth(value, base float64) *float64 {
+	if value == 0 || base <= 0 {
+		return nil
+	}
+	g := value/base - 1
+	return &g
+}
+
+func nonZero(v float64) *float64 {
+	if v == 0 {
+		return nil
+	}
+	return &v
+}
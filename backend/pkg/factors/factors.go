@@ -0,0 +1,401 @@
+// Package factors measures which metrics carry signal about future returns. Every metric of
+// the screener catalog, including the components of the undervaluation score, is evaluated
+// on point-in-time cross-sections against forward returns with information coefficients,
+// quantile spreads and decay curves.
+package factors
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"time"
+
+	"stockpick-backend/pkg/backtest"
+	"stockpick-backend/pkg/fundamentals"
+	"stockpick-backend/pkg/models"
+	"stockpick-backend/pkg/screener"
+	"stockpick-backend/pkg/undervaluation"
+)
+
+// maxStaleness is how old a close may be and still price a stock on a date
+const maxStaleness = 7 * 24 * time.Hour
+
+// Config describes a factor analysis
+type Config struct {
+	Start        time.Time `json:"start"`
+	End          time.Time `json:"end"`
+	Frequency    string    `json:"frequency"`    // Sampling schedule, one of the backtest rebalance schedules
+	Horizons     []int     `json:"horizons"`     // Forward return horizons, in months
+	DecayMonths  int       `json:"decay_months"` // Length of the decay curve, in months; 0 disables it
+	Quantiles    int       `json:"quantiles"`
+	MinStocks    int       `json:"min_stocks"` // Smallest cross-section a date is evaluated on
+	Mode         string    `json:"mode"`       // "absolute" or "relative" scoring
+	GroupBy      string    `json:"group_by"`   // Peer grouping of relative scoring
+	Fundamentals string    `json:"fundamentals"`
+	Factors      []string  `json:"factors"` // Catalog metrics to evaluate, every numeric metric when empty
+}
+
+// DefaultConfig returns a monthly analysis of the last six years with 1, 3 and 12 month horizons
+func DefaultConfig() Config {
+	end := time.Now().UTC().Truncate(24 * time.Hour)
+	return Config{
+		Start:        end.AddDate(-6, 0, 0),
+		End:          end,
+		Frequency:    backtest.RebalanceMonthly,
+		Horizons:     []int{1, 3, 12},
+		DecayMonths:  12,
+		Quantiles:    5,
+		MinStocks:    10,
+		Mode:         backtest.ModeAbsolute,
+		GroupBy:      undervaluation.GroupSector,
+		Fundamentals: fundamentals.TTMPeriod,
+	}
+}
+
+// Validate checks the configuration
+func (c Config) Validate() error {
+	if !c.End.After(c.Start) {
+		return fmt.Errorf("end must be after start")
+	}
+	switch c.Frequency {
+	case backtest.RebalanceWeekly, backtest.RebalanceMonthly, backtest.RebalanceQuarterly, backtest.RebalanceAnnually:
+	default:
+		return fmt.Errorf("frequency must be weekly, monthly, quarterly or annually")
+	}
+	if len(c.Horizons) == 0 {
+		return fmt.Errorf("at least one horizon is required")
+	}
+	for _, h := range c.Horizons {
+		if h < 1 || h > 60 {
+			return fmt.Errorf("horizons must be between 1 and 60 months")
+		}
+	}
+	if c.DecayMonths < 0 || c.DecayMonths > 36 {
+		return fmt.Errorf("decay_months must be between 0 and 36")
+	}
+	if c.Quantiles < 2 || c.Quantiles > 10 {
+		return fmt.Errorf("quantiles must be between 2 and 10")
+	}
+	if c.MinStocks < c.Quantiles {
+		return fmt.Errorf("min_stocks must be at least the number of quantiles")
+	}
+	if c.Mode != backtest.ModeAbsolute && c.Mode != backtest.ModeRelative {
+		return fmt.Errorf("mode must be absolute or relative")
+	}
+	if c.Mode == backtest.ModeRelative && c.GroupBy != undervaluation.GroupSector && c.GroupBy != undervaluation.GroupIndustry {
+		return fmt.Errorf("group_by must be sector or industry")
+	}
+	if c.Fundamentals != "annual" && c.Fundamentals != fundamentals.TTMPeriod {
+		return fmt.Errorf("fundamentals must be annual or ttm")
+	}
+	for _, name := range c.Factors {
+		if f, ok := screener.Lookup(name); !ok || f.Type != screener.TypeNumber {
+			return fmt.Errorf("unknown numeric metric %q", name)
+		}
+	}
+	return nil
+}
+
+// MaxHorizon is the furthest a forward return looks ahead, in months. Prices must be
+// available up to this long after End for the last dates to be evaluated.
+func (c Config) MaxHorizon() int {
+	max := c.DecayMonths
+	for _, h := range c.Horizons {
+		if h > max {
+			max = h
+		}
+	}
+	return max
+}
+
+// Result is the outcome of a factor analysis
+type Result struct {
+	Config  Config   `json:"config"`
+	Dates   int      `json:"dates"`  // Sample dates with a scored cross-section
+	Stocks  int      `json:"stocks"` // Distinct stocks observed
+	Factors []Factor `json:"factors"`
+}
+
+// Factor is the evaluation of one metric
+type Factor struct {
+	Name        string       `json:"name"`
+	Category    string       `json:"category"`
+	Description string       `json:"description"`
+	Horizons    []Horizon    `json:"horizons"`
+	Decay       []DecayPoint `json:"decay,omitempty"`
+}
+
+// Horizon is the evaluation of a metric against forward returns over one horizon
+type Horizon struct {
+	Months    int       `json:"months"`
+	IC        *ICStats  `json:"ic"`        // Nil when no date had enough observations
+	Quantiles []float64 `json:"quantiles"` // Mean forward return per quantile, lowest metric values first
+	Spread    *float64  `json:"spread"`    // Top minus bottom quantile return
+	Series    []ICPoint `json:"series,omitempty"`
+}
+
+// ICStats summarize the information coefficients of a metric across dates. Horizons longer
+// than the sampling interval overlap, which overstates the t-statistic.
+type ICStats struct {
+	Mean    float64 `json:"mean"`
+	Std     float64 `json:"std"`
+	IR      float64 `json:"ir"` // Mean over standard deviation
+	TStat   float64 `json:"t_stat"`
+	HitRate float64 `json:"hit_rate"` // Share of dates with a positive coefficient
+	Periods int     `json:"periods"`
+}
+
+// ICPoint is the rank correlation between a metric and forward returns on one date
+type ICPoint struct {
+	Date   time.Time `json:"date"`
+	IC     float64   `json:"ic"`
+	Stocks int       `json:"stocks"`
+}
+
+// DecayPoint is the mean information coefficient against the return earned in a single
+// month after the date: month 1 is the first month, month 2 the second and so on
+type DecayPoint struct {
+	Month   int      `json:"month"`
+	IC      *float64 `json:"ic"`
+	Periods int      `json:"periods"`
+}
+
+// crossSection holds the metric values and forward returns of the stocks scored on a date.
+// Unavailable values are NaN.
+type crossSection struct {
+	day     time.Time
+	values  map[string][]float64
+	forward map[int][]float64 // By horizon in months
+	monthly [][]float64       // Return of month k+1 after the date, for the decay curve
+}
+
+// Run samples the dataset on the configured schedule, scores every stock on point-in-time
+// data and evaluates each metric against the forward returns that followed
+func Run(ds *backtest.Dataset, cfg Config) (*Result, error) {
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+	fields := selectFields(cfg.Factors)
+
+	days := ds.TradingDays(cfg.Start, cfg.End)
+	var sections []crossSection
+	seen := map[int]bool{}
+	for d, day := range days {
+		if d > 0 && !backtest.NewPeriod(days[d-1], day, cfg.Frequency) {
+			continue
+		}
+		cs, stocks, err := sample(ds, cfg, fields, day)
+		if err != nil {
+			return nil, err
+		}
+		if len(stocks) == 0 {
+			continue
+		}
+		for _, i := range stocks {
+			seen[i] = true
+		}
+		sections = append(sections, cs)
+	}
+	if len(sections) == 0 {
+		return nil, fmt.Errorf("no stock could be scored between %s and %s", cfg.Start.Format("2006-01-02"), cfg.End.Format("2006-01-02"))
+	}
+
+	result := &Result{Config: cfg, Dates: len(sections), Stocks: len(seen)}
+	for _, f := range fields {
+		factor := Factor{Name: f.Name, Category: f.Category, Description: f.Description}
+		for _, h := range cfg.Horizons {
+			factor.Horizons = append(factor.Horizons, evaluate(sections, f.Name, h, cfg))
+		}
+		for m := 0; m < cfg.DecayMonths; m++ {
+			factor.Decay = append(factor.Decay, decay(sections, f.Name, m, cfg.MinStocks))
+		}
+		result.Factors = append(result.Factors, factor)
+	}
+	return result, nil
+}
+
+// selectFields returns the catalog metrics to evaluate, in catalog order
+func selectFields(names []string) []screener.Field {
+	wanted := map[string]bool{}
+	for _, n := range names {
+		wanted[n] = true
+	}
+	var fields []screener.Field
+	for _, f := range screener.Catalog() {
+		if f.Type == screener.TypeNumber && (len(wanted) == 0 || wanted[f.Name]) {
+			fields = append(fields, f)
+		}
+	}
+	return fields
+}
+
+// sample scores the universe on a day and records metric values and forward returns. It
+// also returns the dataset indexes of the stocks in the cross-section.
+func sample(ds *backtest.Dataset, cfg Config, fields []screener.Field, day time.Time) (crossSection, []int, error) {
+	var universe []undervaluation.Inputs
+	var stocks []int
+	for i := range ds.Stocks {
+		in, ok := ds.Stocks[i].InputsAt(day, cfg.Fundamentals)
+		if !ok || day.Sub(in.LatestPriceTime) > maxStaleness {
+			continue
+		}
+		universe = append(universe, in)
+		stocks = append(stocks, i)
+	}
+
+	scores := make([]*undervaluation.UndervaluationScore, len(universe))
+	if cfg.Mode == backtest.ModeRelative && len(universe) > 0 {
+		relative, err := undervaluation.CalculateRelative(universe, cfg.GroupBy)
+		if err != nil {
+			return crossSection{}, nil, err
+		}
+		copy(scores, relative)
+	} else {
+		for j, in := range universe {
+			if s, err := undervaluation.Calculate(in); err == nil {
+				scores[j] = s
+			}
+		}
+	}
+
+	cs := crossSection{day: day, values: map[string][]float64{}, forward: map[int][]float64{}}
+	for _, f := range fields {
+		cs.values[f.Name] = make([]float64, len(universe))
+	}
+	for j, in := range universe {
+		record := screener.NewRecord(in, scores[j])
+		for _, f := range fields {
+			v := record[f.Name]
+			cs.values[f.Name][j] = math.NaN()
+			if v.Valid && !math.IsNaN(v.Num) && !math.IsInf(v.Num, 0) {
+				cs.values[f.Name][j] = v.Num
+			}
+		}
+	}
+
+	for _, h := range cfg.Horizons {
+		returns := make([]float64, len(stocks))
+		for j, i := range stocks {
+			returns[j] = forwardReturn(ds.Stocks[i].Prices, day, day.AddDate(0, h, 0))
+		}
+		cs.forward[h] = returns
+	}
+	for m := 0; m < cfg.DecayMonths; m++ {
+		returns := make([]float64, len(stocks))
+		for j, i := range stocks {
+			returns[j] = forwardReturn(ds.Stocks[i].Prices, day.AddDate(0, m, 0), day.AddDate(0, m+1, 0))
+		}
+		cs.monthly = append(cs.monthly, returns)
+	}
+	return cs, stocks, nil
+}
+
+// forwardReturn is the close-to-close return between two times, NaN unless both are
+// priced by a recent enough close. Prices are ordered oldest first.
+func forwardReturn(prices []models.HistoricalPrice, from, to time.Time) float64 {
+	start, ok := closeAt(prices, from)
+	if !ok {
+		return math.NaN()
+	}
+	end, ok := closeAt(prices, to)
+	if !ok {
+		return math.NaN()
+	}
+	return end/start - 1
+}
+
+// closeAt is the last close on or before t, if it is no older than maxStaleness
+func closeAt(prices []models.HistoricalPrice, t time.Time) (float64, bool) {
+	n := sort.Search(len(prices), func(i int) bool { return prices[i].Time.After(t) })
+	if n == 0 {
+		return 0, false
+	}
+	p := prices[n-1]
+	if t.Sub(p.Time) > maxStaleness || p.ClosePrice <= 0 {
+		return 0, false
+	}
+	return p.ClosePrice, true
+}
+
+// pairs collects the stocks of a cross-section with both a metric value and a return
+func pairs(values, returns []float64) []pair {
+	var ps []pair
+	for j := range values {
+		if !math.IsNaN(values[j]) && !math.IsNaN(returns[j]) {
+			ps = append(ps, pair{factor: values[j], ret: returns[j]})
+		}
+	}
+	return ps
+}
+
+// evaluate computes the information coefficients and quantile returns of a metric
+func evaluate(sections []crossSection, name string, months int, cfg Config) Horizon {
+	h := Horizon{Months: months, Series: []ICPoint{}}
+	var ics, spreads []float64
+	quantileSums := make([]float64, cfg.Quantiles)
+	for _, cs := range sections {
+		ps := pairs(cs.values[name], cs.forward[months])
+		if len(ps) < cfg.MinStocks {
+			continue
+		}
+		ic, ok := spearman(ps)
+		if !ok {
+			continue
+		}
+		ics = append(ics, ic)
+		h.Series = append(h.Series, ICPoint{Date: cs.day, IC: ic, Stocks: len(ps)})
+
+		q := quantileReturns(ps, cfg.Quantiles)
+		for b := range q {
+			quantileSums[b] += q[b]
+		}
+		spreads = append(spreads, q[len(q)-1]-q[0])
+	}
+	if len(ics) == 0 {
+		return h
+	}
+
+	stats := &ICStats{Mean: mean(ics), Std: stdDev(ics), Periods: len(ics)}
+	if stats.Std > 0 {
+		stats.IR = stats.Mean / stats.Std
+		stats.TStat = stats.Mean / (stats.Std / math.Sqrt(float64(len(ics))))
+	}
+	positive := 0
+	for _, ic := range ics {
+		if ic > 0 {
+			positive++
+		}
+	}
+	stats.HitRate = float64(positive) / float64(len(ics))
+	h.IC = stats
+
+	for b := range quantileSums {
+		quantileSums[b] /= float64(len(spreads))
+	}
+	h.Quantiles = quantileSums
+	spread := mean(spreads)
+	h.Spread = &spread
+	return h
+}
+
+// decay is the mean information coefficient of a metric against the return of the given
+// month (0-based) after each date
+func decay(sections []crossSection, name string, month, minStocks int) DecayPoint {
+	p := DecayPoint{Month: month + 1}
+	var ics []float64
+	for _, cs := range sections {
+		ps := pairs(cs.values[name], cs.monthly[month])
+		if len(ps) < minStocks {
+			continue
+		}
+		if ic, ok := spearman(ps); ok {
+			ics = append(ics, ic)
+		}
+	}
+	if len(ics) > 0 {
+		m := mean(ics)
+		p.IC = &m
+		p.Periods = len(ics)
+	}
+	return p
+}
@@ -0,0 +1,110 @@
+package factors
+
+import (
+	"math"
+	"sort"
+)
+
+// pair is a factor value and the forward return of one stock on one date
+type pair struct {
+	factor float64
+	ret    float64
+}
+
+// spearman is the rank correlation of factor values and returns, ties sharing their
+// average rank. It returns false when either side has no dispersion.
+func spearman(pairs []pair) (float64, bool) {
+	if len(pairs) < 2 {
+		return 0, false
+	}
+	xs := make([]float64, len(pairs))
+	ys := make([]float64, len(pairs))
+	for i, p := range pairs {
+		xs[i] = p.factor
+		ys[i] = p.ret
+	}
+	return pearson(ranks(xs), ranks(ys))
+}
+
+// ranks returns the 1-based rank of every value, ties sharing their average rank
+func ranks(values []float64) []float64 {
+	order := make([]int, len(values))
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(i, j int) bool { return values[order[i]] < values[order[j]] })
+
+	r := make([]float64, len(values))
+	for i := 0; i < len(order); {
+		j := i
+		for j+1 < len(order) && values[order[j+1]] == values[order[i]] {
+			j++
+		}
+		avg := float64(i+j)/2 + 1
+		for k := i; k <= j; k++ {
+			r[order[k]] = avg
+		}
+		i = j + 1
+	}
+	return r
+}
+
+func pearson(xs, ys []float64) (float64, bool) {
+	mx, my := mean(xs), mean(ys)
+	var sxy, sxx, syy float64
+	for i := range xs {
+		dx, dy := xs[i]-mx, ys[i]-my
+		sxy += dx * dy
+		sxx += dx * dx
+		syy += dy * dy
+	}
+	if sxx == 0 || syy == 0 {
+		return 0, false
+	}
+	return sxy / math.Sqrt(sxx*syy), true
+}
+
+// quantileReturns sorts the pairs by factor value and returns the mean forward return of
+// each of n equally sized buckets, lowest factor values first
+func quantileReturns(pairs []pair, n int) []float64 {
+	sorted := append([]pair(nil), pairs...)
+	sort.SliceStable(sorted, func(i, j int) bool { return sorted[i].factor < sorted[j].factor })
+
+	sums := make([]float64, n)
+	counts := make([]int, n)
+	for i, p := range sorted {
+		b := i * n / len(sorted)
+		sums[b] += p.ret
+		counts[b]++
+	}
+	for b := range sums {
+		if counts[b] > 0 {
+			sums[b] /= float64(counts[b])
+		}
+	}
+	return sums
+}
+
+func mean(values []float64) float64 {
+	if len(values) == 0 {
+		return 0
+	}
+	sum := 0.0
+	for _, v := range values {
+		sum += v
+	}
+	return sum / float64(len(values))
+}
+
+// stdDev is the sample standard deviation
+func stdDev(values []float64) float64 {
+	if len(values) < 2 {
+		return 0
+	}
+	m := mean(values)
+	ss := 0.0
+	for _, v := range values {
+		ss += (v - m) * (v - m)
+	}
+	return math.Sqrt(ss / float64(len(values)-1))
+}
@@ -5,11 +5,13 @@ import (
 	"fmt"
 	"io"
 	"net/http"
+	"sort"
 	"time"
 )
 
 const ( 
-	BaseURL = "https://financialmodelingprep.com/api/v3"
+	BaseURL   = "https://financialmodelingprep.com/api/v3"
+	BaseURLV4 = "https://financialmodelingprep.com/api/v4"
 )
 
 type Client struct {
@@ -27,7 +29,12 @@ func NewClient(apiKey string) *Client {
 }
 
 func (c *Client) get(path string, queryParams map[string]string) ([]byte, error) {
-	req, err := http.NewRequest("GET", fmt.Sprintf("%s%s", BaseURL, path), nil)
+	return c.getFrom(BaseURL, path, queryParams)
+}
+
+// getFrom performs a GET request against the given API version base URL
+func (c *Client) getFrom(baseURL, path string, queryParams map[string]string) ([]byte, error) {
+	req, err := http.NewRequest("GET", fmt.Sprintf("%s%s", baseURL, path), nil)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create request: %w", err)
 	}
@@ -83,6 +90,63 @@ func (c *Client) GetHistoricalPrices(symbol string, from, to time.Time) ([]Histo
 	return response.Historical, nil
 }
 
+// GetIntradayPrices fetches intraday bars for a given symbol. interval is one of FMP's
+// chart intervals: 1min, 5min, 15min, 30min, 1hour or 4hour.
+func (c *Client) GetIntradayPrices(symbol, interval string, from, to time.Time) ([]IntradayPriceFMP, error) {
+	path := fmt.Sprintf("/historical-chart/%s/%s", interval, symbol)
+	queryParams := map[string]string{
+		"from": from.Format("2006-01-02"),
+		"to":   to.Format("2006-01-02"),
+	}
+
+	body, err := c.get(path, queryParams)
+	if err != nil {
+		return nil, err
+	}
+
+	var bars []IntradayPriceFMP
+	if err := json.Unmarshal(body, &bars); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal intraday prices: %w", err)
+	}
+	return bars, nil
+}
+
+// GetStockSplits fetches the split history of a given symbol.
+func (c *Client) GetStockSplits(symbol string) ([]StockSplitFMP, error) {
+	path := fmt.Sprintf("/historical-price-full/stock_split/%s", symbol)
+	body, err := c.get(path, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	var response struct {
+		Symbol     string          `json:"symbol"`
+		Historical []StockSplitFMP `json:"historical"`
+	}
+	if err := json.Unmarshal(body, &response); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal stock splits: %w", err)
+	}
+	return response.Historical, nil
+}
+
+// GetDividends fetches the cash dividend history of a given symbol.
+func (c *Client) GetDividends(symbol string) ([]DividendFMP, error) {
+	path := fmt.Sprintf("/historical-price-full/stock_dividend/%s", symbol)
+	body, err := c.get(path, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	var response struct {
+		Symbol     string        `json:"symbol"`
+		Historical []DividendFMP `json:"historical"`
+	}
+	if err := json.Unmarshal(body, &response); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal dividends: %w", err)
+	}
+	return response.Historical, nil
+}
+
 // GetCompanyProfile fetches company profile data for a given symbol.
 func (c *Client) GetCompanyProfile(symbol string) ([]CompanyProfileFMP, error) {
 	path := fmt.Sprintf("/profile/%s", symbol)
@@ -116,10 +180,43 @@ func (c *Client) GetFinancialStatements(symbol, statementType, period string) ([
 	return statements, nil
 }
 
-// GetAnalystEstimates fetches analyst estimates for a given symbol.
-func (c *Client) GetAnalystEstimates(symbol string) ([]AnalystEstimateFMP, error) {
+// GetFullFinancialStatements fetches the income, balance sheet and cash flow statements
+// for a symbol and period ("annual" or "quarter") and merges them into one entry per date,
+// newest first.
+func (c *Client) GetFullFinancialStatements(symbol, period string) ([]FinancialStatementFMP, error) {
+	byDate := make(map[string]*FinancialStatementFMP)
+	var dates []string
+	for _, statementType := range []string{"income", "balance-sheet", "cash-flow"} {
+		statements, err := c.GetFinancialStatements(symbol, statementType, period)
+		if err != nil {
+			return nil, fmt.Errorf("failed to fetch %s statements: %w", statementType, err)
+		}
+		for _, s := range statements {
+			merged, ok := byDate[s.Date]
+			if !ok {
+				merged = &FinancialStatementFMP{}
+				byDate[s.Date] = merged
+				dates = append(dates, s.Date)
+			}
+			merged.merge(s)
+		}
+	}
+
+	sort.Sort(sort.Reverse(sort.StringSlice(dates)))
+	merged := make([]FinancialStatementFMP, 0, len(dates))
+	for _, date := range dates {
+		merged = append(merged, *byDate[date])
+	}
+	return merged, nil
+}
+
+// GetAnalystEstimates fetches analyst estimates for a given symbol and period ("annual" or "quarter").
+func (c *Client) GetAnalystEstimates(symbol, period string) ([]AnalystEstimateFMP, error) {
 	path := fmt.Sprintf("/analyst-estimates/%s", symbol)
-	body, err := c.get(path, nil)
+	queryParams := map[string]string{
+		"period": period,
+	}
+	body, err := c.get(path, queryParams)
 	if err != nil {
 		return nil, err
 	}
@@ -164,3 +261,21 @@ func (c *Client) GetSocialSentiment(symbol string, from, to time.Time) ([]Social
 	}
 	return sentiment, nil
 }
+
+// GetStockPeers fetches the symbols FMP considers peers of a given symbol
+// (same exchange, sector and similar market cap).
+func (c *Client) GetStockPeers(symbol string) ([]string, error) {
+	body, err := c.getFrom(BaseURLV4, "/stock_peers", map[string]string{"symbol": symbol})
+	if err != nil {
+		return nil, err
+	}
+
+	var peers []StockPeersFMP
+	if err := json.Unmarshal(body, &peers); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal stock peers: %w", err)
+	}
+	if len(peers) == 0 {
+		return nil, nil
+	}
+	return peers[0].PeersList, nil
+}
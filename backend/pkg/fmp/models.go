@@ -15,6 +15,37 @@ type HistoricalPriceFMP struct {
 	PctChange float64 `json:"changePercent"`
 }
 
+// IntradayPriceFMP represents a single intraday bar from FMP API. Dates are
+// "2006-01-02 15:04:05" in US Eastern time.
+type IntradayPriceFMP struct {
+	Date   string  `json:"date"`
+	Open   float64 `json:"open"`
+	High   float64 `json:"high"`
+	Low    float64 `json:"low"`
+	Close  float64 `json:"close"`
+	Volume int64   `json:"volume"`
+}
+
+// StockSplitFMP represents a stock split entry from FMP API
+type StockSplitFMP struct {
+	Date        string  `json:"date"`
+	Label       string  `json:"label"`
+	Numerator   float64 `json:"numerator"`
+	Denominator float64 `json:"denominator"`
+}
+
+// DividendFMP represents a dividend entry from FMP API; dates other than the
+// ex-dividend date may be empty
+type DividendFMP struct {
+	Date            string  `json:"date"` // Ex-dividend date
+	Label           string  `json:"label"`
+	AdjDividend     float64 `json:"adjDividend"`
+	Dividend        float64 `json:"dividend"`
+	RecordDate      string  `json:"recordDate"`
+	PaymentDate     string  `json:"paymentDate"`
+	DeclarationDate string  `json:"declarationDate"`
+}
+
 // CompanyProfileFMP represents company profile data from FMP API
 type CompanyProfileFMP struct {
 	Symbol        string `json:"symbol"`
@@ -55,12 +86,23 @@ type FinancialStatementFMP struct {
 	CostOfRevenue        float64 `json:"costOfRevenue"`
 	GrossProfit          float64 `json:"grossProfit"`
 	OperatingExpenses    float64 `json:"operatingExpenses"`
+	OperatingIncome      float64 `json:"operatingIncome"`
 	EBITDA               float64 `json:"ebitda"`
+	IncomeBeforeTax      float64 `json:"incomeBeforeTax"`
+	IncomeTaxExpense     float64 `json:"incomeTaxExpense"`
 	NetIncome            float64 `json:"netIncome"`
 	EPS                  float64 `json:"eps"`
+	WeightedAverageShsOut float64 `json:"weightedAverageShsOut"`
+	CashAndCashEquivalents float64 `json:"cashAndCashEquivalents"`
+	TotalCurrentAssets   float64 `json:"totalCurrentAssets"`
 	TotalAssets          float64 `json:"totalAssets"`
+	TotalCurrentLiabilities float64 `json:"totalCurrentLiabilities"`
 	TotalLiabilities     float64 `json:"totalLiabilities"`
+	LongTermDebt         float64 `json:"longTermDebt"`
+	RetainedEarnings     float64 `json:"retainedEarnings"`
 	TotalEquity          float64 `json:"totalEquity"`
+	TotalDebt            float64 `json:"totalDebt"`
+	OperatingCashFlow    float64 `json:"operatingCashFlow"`
 	FreeCashFlow         float64 `json:"freeCashFlow"`
 	Debt                 float64 `json:"debt"`
 	DebtToEquityRatio    float64 `json:"debtToEquityRatio"`
@@ -69,10 +111,18 @@ type FinancialStatementFMP struct {
 
 // AnalystEstimateFMP represents a single analyst estimate entry from FMP API
 type AnalystEstimateFMP struct {
-	Symbol           string  `json:"symbol"`
-	Date             string  `json:"date"`
-	EstimatedRevenue float64 `json:"estimatedRevenue"`
-	EstimatedEPS     float64 `json:"estimatedEps"`
+	Symbol                        string  `json:"symbol"`
+	Date                          string  `json:"date"` // Fiscal period end being estimated
+	EstimatedRevenueLow           float64 `json:"estimatedRevenueLow"`
+	EstimatedRevenueHigh          float64 `json:"estimatedRevenueHigh"`
+	EstimatedRevenueAvg           float64 `json:"estimatedRevenueAvg"`
+	EstimatedEbitdaAvg            float64 `json:"estimatedEbitdaAvg"`
+	EstimatedNetIncomeAvg         float64 `json:"estimatedNetIncomeAvg"`
+	EstimatedEpsLow               float64 `json:"estimatedEpsLow"`
+	EstimatedEpsHigh              float64 `json:"estimatedEpsHigh"`
+	EstimatedEpsAvg               float64 `json:"estimatedEpsAvg"`
+	NumberAnalystEstimatedRevenue int     `json:"numberAnalystEstimatedRevenue"`
+	NumberAnalystsEstimatedEps    int     `json:"numberAnalystsEstimatedEps"`
 	// Add other relevant fields as needed
 }
 
@@ -90,6 +140,12 @@ type PriceTargetFMP struct {
 	RecommendationStrongSell int `json:"recommendationStrongSell"`
 }
 
+// StockPeersFMP represents the peers of a symbol from FMP API
+type StockPeersFMP struct {
+	Symbol    string   `json:"symbol"`
+	PeersList []string `json:"peersList"`
+}
+
 // SocialSentimentFMP represents a single social sentiment entry from FMP API
 type SocialSentimentFMP struct {
 	Symbol        string    `json:"symbol"`
@@ -100,3 +156,52 @@ type SocialSentimentFMP struct {
 	GeneralPerception string `json:"generalPerception"`
 	Source        string    `json:"source"`
 }
+
+// merge fills the fields of s that are unset with the values reported in o.
+// FMP splits a period's figures across the income, balance sheet and cash flow endpoints.
+func (s *FinancialStatementFMP) merge(o FinancialStatementFMP) {
+	mergeString(&s.Date, o.Date)
+	mergeString(&s.Symbol, o.Symbol)
+	mergeString(&s.ReportedCurrency, o.ReportedCurrency)
+	mergeString(&s.Cik, o.Cik)
+	mergeString(&s.FillingDate, o.FillingDate)
+	mergeString(&s.AcceptedDate, o.AcceptedDate)
+	mergeString(&s.CalendarYear, o.CalendarYear)
+	mergeString(&s.Period, o.Period)
+	mergeFloat(&s.Revenue, o.Revenue)
+	mergeFloat(&s.CostOfRevenue, o.CostOfRevenue)
+	mergeFloat(&s.GrossProfit, o.GrossProfit)
+	mergeFloat(&s.OperatingExpenses, o.OperatingExpenses)
+	mergeFloat(&s.OperatingIncome, o.OperatingIncome)
+	mergeFloat(&s.EBITDA, o.EBITDA)
+	mergeFloat(&s.IncomeBeforeTax, o.IncomeBeforeTax)
+	mergeFloat(&s.IncomeTaxExpense, o.IncomeTaxExpense)
+	mergeFloat(&s.NetIncome, o.NetIncome)
+	mergeFloat(&s.EPS, o.EPS)
+	mergeFloat(&s.WeightedAverageShsOut, o.WeightedAverageShsOut)
+	mergeFloat(&s.CashAndCashEquivalents, o.CashAndCashEquivalents)
+	mergeFloat(&s.TotalCurrentAssets, o.TotalCurrentAssets)
+	mergeFloat(&s.TotalAssets, o.TotalAssets)
+	mergeFloat(&s.TotalCurrentLiabilities, o.TotalCurrentLiabilities)
+	mergeFloat(&s.TotalLiabilities, o.TotalLiabilities)
+	mergeFloat(&s.LongTermDebt, o.LongTermDebt)
+	mergeFloat(&s.RetainedEarnings, o.RetainedEarnings)
+	mergeFloat(&s.TotalEquity, o.TotalEquity)
+	mergeFloat(&s.TotalDebt, o.TotalDebt)
+	mergeFloat(&s.OperatingCashFlow, o.OperatingCashFlow)
+	mergeFloat(&s.FreeCashFlow, o.FreeCashFlow)
+	mergeFloat(&s.Debt, o.Debt)
+	mergeFloat(&s.DebtToEquityRatio, o.DebtToEquityRatio)
+}
+
+func mergeString(dst *string, src string) {
+	if *dst == "" {
+		*dst = src
+	}
+}
+
+func mergeFloat(dst *float64, src float64) {
+	if *dst == 0 {
+		*dst = src
+	}
+}
@@ -0,0 +1,79 @@
+package fundamentals
+
+import (
+	"math"
+	"time"
+
+	"stockpick-backend/pkg/models"
+)
+
+// Without filing dates a statement is assumed to be published the given lag after its
+// period end, roughly the SEC deadlines for 10-K and 10-Q filings.
+const (
+	AnnualReportingLag    = 90 * 24 * time.Hour
+	QuarterlyReportingLag = 45 * 24 * time.Hour
+)
+
+// PublicationTime estimates when a statement first became public: its acceptance time,
+// else its filing date, else its period end plus the reporting lag of its period.
+func PublicationTime(fs models.FinancialStatement) time.Time {
+	switch {
+	case fs.AcceptedDate != nil:
+		return *fs.AcceptedDate
+	case fs.FilingDate != nil:
+		return *fs.FilingDate
+	case fs.Period == "annual":
+		return fs.Date.Add(AnnualReportingLag)
+	}
+	return fs.Date.Add(QuarterlyReportingLag)
+}
+
+// knownSince is when a stored revision became known, falling back to PublicationTime
+// for statements that do not carry it.
+func knownSince(fs models.FinancialStatement) time.Time {
+	if !fs.KnownSince.IsZero() {
+		return fs.KnownSince
+	}
+	return PublicationTime(fs)
+}
+
+// AsOf returns the statements that were known at the given time, newest period first.
+// Revisions must be ordered by date descending and revision descending; for each period
+// only the latest revision known by then is kept.
+func AsOf(revisions []models.FinancialStatement, at time.Time) []models.FinancialStatement {
+	var known []models.FinancialStatement
+	for _, fs := range revisions {
+		if knownSince(fs).After(at) {
+			continue
+		}
+		if n := len(known); n > 0 && known[n-1].Date.Equal(fs.Date) {
+			continue
+		}
+		known = append(known, fs)
+	}
+	return known
+}
+
+// SameFigures reports whether two statements report the same figures, within the
+// precision they are stored at. Derived ratios and metadata are ignored, so a
+// difference means the statement was restated.
+func SameFigures(a, b models.FinancialStatement) bool {
+	if math.Abs(a.EPS-b.EPS) > 0.0001 {
+		return false
+	}
+	pairs := [][2]float64{
+		{a.Revenue, b.Revenue}, {a.NetIncome, b.NetIncome},
+		{a.TotalAssets, b.TotalAssets}, {a.TotalLiabilities, b.TotalLiabilities}, {a.TotalEquity, b.TotalEquity},
+		{a.FreeCashFlow, b.FreeCashFlow}, {a.GrossProfit, b.GrossProfit}, {a.OperatingIncome, b.OperatingIncome},
+		{a.EBITDA, b.EBITDA}, {a.IncomeBeforeTax, b.IncomeBeforeTax}, {a.IncomeTaxExpense, b.IncomeTaxExpense},
+		{a.TotalDebt, b.TotalDebt}, {a.CashAndEquivalents, b.CashAndEquivalents}, {a.SharesOutstanding, b.SharesOutstanding},
+		{a.CurrentAssets, b.CurrentAssets}, {a.CurrentLiabilities, b.CurrentLiabilities}, {a.LongTermDebt, b.LongTermDebt},
+		{a.RetainedEarnings, b.RetainedEarnings}, {a.OperatingCashFlow, b.OperatingCashFlow},
+	}
+	for _, p := range pairs {
+		if math.Abs(p[0]-p[1]) > 0.01 {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,89 @@
+package fundamentals
+
+import (
+	"stockpick-backend/pkg/models"
+	"stockpick-backend/pkg/ratios"
+)
+
+// TTMPeriod is the virtual period of trailing-twelve-month statements aggregated from quarterly data
+const TTMPeriod = "ttm"
+
+// Consecutive quarterly period ends are expected roughly 91 days apart; wider gaps mean a missing quarter.
+const (
+	minQuarterGapDays = 60
+	maxQuarterGapDays = 120
+)
+
+// TTM aggregates four consecutive quarterly statements, ordered newest first, into a
+// trailing-twelve-month statement. Flow items (revenue, income, EPS, cash flow) are summed
+// and balance-sheet items are taken from the latest quarter. It returns false when fewer
+// than four consecutive quarters are available.
+func TTM(quarters []models.FinancialStatement) (models.FinancialStatement, bool) {
+	if len(quarters) < 4 || !consecutive(quarters[:4]) {
+		return models.FinancialStatement{}, false
+	}
+
+	latest := quarters[0]
+	ttm := models.FinancialStatement{
+		StockID:            latest.StockID,
+		Date:               latest.Date,
+		Period:             TTMPeriod,
+		FilingDate:         latest.FilingDate,
+		AcceptedDate:       latest.AcceptedDate,
+		TotalAssets:        latest.TotalAssets,
+		TotalLiabilities:   latest.TotalLiabilities,
+		TotalEquity:        latest.TotalEquity,
+		TotalDebt:          latest.TotalDebt,
+		CashAndEquivalents: latest.CashAndEquivalents,
+		SharesOutstanding:  latest.SharesOutstanding,
+		CurrentAssets:      latest.CurrentAssets,
+		CurrentLiabilities: latest.CurrentLiabilities,
+		LongTermDebt:       latest.LongTermDebt,
+		RetainedEarnings:   latest.RetainedEarnings,
+		CreatedAt:          latest.CreatedAt,
+		UpdatedAt:          latest.UpdatedAt,
+	}
+	for _, q := range quarters[:4] {
+		ttm.Revenue += q.Revenue
+		ttm.NetIncome += q.NetIncome
+		ttm.EPS += q.EPS
+		ttm.FreeCashFlow += q.FreeCashFlow
+		ttm.OperatingCashFlow += q.OperatingCashFlow
+		ttm.GrossProfit += q.GrossProfit
+		ttm.OperatingIncome += q.OperatingIncome
+		ttm.EBITDA += q.EBITDA
+		ttm.IncomeBeforeTax += q.IncomeBeforeTax
+		ttm.IncomeTaxExpense += q.IncomeTaxExpense
+		if q.KnownSince.After(ttm.KnownSince) {
+			ttm.KnownSince = q.KnownSince
+		}
+	}
+
+	// Price-based ratios depend on the price the caller scores at; only the
+	// statement-only ratios are filled in here.
+	ratios.Compute(ttm, 0).ApplyTo(&ttm)
+	return ttm, true
+}
+
+// TTMSeries builds a rolling TTM statement for every window of four consecutive quarters,
+// newest first, from quarterly statements ordered newest first.
+func TTMSeries(quarters []models.FinancialStatement) []models.FinancialStatement {
+	var series []models.FinancialStatement
+	for i := 0; i+4 <= len(quarters); i++ {
+		if ttm, ok := TTM(quarters[i:]); ok {
+			series = append(series, ttm)
+		}
+	}
+	return series
+}
+
+// consecutive reports whether statements ordered newest first are one quarter apart each
+func consecutive(quarters []models.FinancialStatement) bool {
+	for i := 1; i < len(quarters); i++ {
+		gap := quarters[i-1].Date.Sub(quarters[i].Date).Hours() / 24
+		if gap < minQuarterGapDays || gap > maxQuarterGapDays {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,214 @@
+package graham
+
+import (
+	"fmt"
+	"math"
+
+	"stockpick-backend/pkg/dividends"
+	"stockpick-backend/pkg/models"
+	"stockpick-backend/pkg/ratios"
+)
+
+// Multiplier is Graham's ceiling on P/E times P/B (15 x 1.5)
+const Multiplier = 22.5
+
+// NetNetFraction is the share of NCAV a net-net must trade below
+const NetNetFraction = 2.0 / 3.0
+
+// MinRevenue is the "adequate size" threshold. Graham's $100 million of annual sales
+// (1973) is scaled up for inflation.
+const MinRevenue = 2e9
+
+// MinDividendYears is the uninterrupted dividend record the defensive investor requires
+const MinDividendYears = 20
+
+// EarningsYears is the span of the earnings stability and growth criteria
+const EarningsYears = 10
+
+// Checklist statuses. A check is unknown when the stored history is too short to evaluate it.
+const (
+	StatusPass    = "pass"
+	StatusFail    = "fail"
+	StatusUnknown = "unknown"
+)
+
+// Check is one criterion of the defensive-investor checklist
+type Check struct {
+	Name   string `json:"name"`
+	Status string `json:"status"`
+	Detail string `json:"detail"`
+}
+
+// Metrics holds the Graham valuation metrics of a stock
+type Metrics struct {
+	Price        float64  `json:"price"`
+	GrahamNumber *float64 `json:"graham_number"`
+	NCAVPerShare *float64 `json:"ncav_per_share"`
+	BelowGraham  bool     `json:"below_graham_number"`
+	BelowNCAV    bool     `json:"below_ncav"`
+	NetNet       bool     `json:"net_net"` // Price below two thirds of NCAV
+	Checklist    []Check  `json:"defensive_checklist"`
+	ChecksPassed int      `json:"checks_passed"`
+}
+
+// Compute derives the Graham metrics from annual statements ordered newest first, the
+// latest close and the dividend metrics, nil when no dividend history is stored.
+func Compute(annual []models.FinancialStatement, price float64, div *dividends.Metrics) Metrics {
+	m := Metrics{Price: price, Checklist: []Check{}}
+	if len(annual) == 0 {
+		return m
+	}
+	latest := annual[0]
+	shares := ratios.SharesOutstanding(latest)
+
+	if shares > 0 {
+		bvps := latest.TotalEquity / shares
+		if latest.EPS > 0 && bvps > 0 {
+			gn := math.Sqrt(Multiplier * latest.EPS * bvps)
+			m.GrahamNumber = &gn
+			m.BelowGraham = price > 0 && price < gn
+		}
+
+		ncav := (latest.CurrentAssets - latest.TotalLiabilities) / shares
+		m.NCAVPerShare = &ncav
+		m.BelowNCAV = price > 0 && ncav > 0 && price < ncav
+		m.NetNet = price > 0 && ncav > 0 && price < ncav*NetNetFraction
+	}
+
+	m.Checklist = defensiveChecklist(annual, price, div)
+	for _, c := range m.Checklist {
+		if c.Status == StatusPass {
+			m.ChecksPassed++
+		}
+	}
+	return m
+}
+
+// defensiveChecklist evaluates the defensive-investor criteria of The Intelligent Investor
+func defensiveChecklist(annual []models.FinancialStatement, price float64, div *dividends.Metrics) []Check {
+	latest := annual[0]
+	var checks []Check
+
+	// Adequate size
+	size := Check{Name: "adequate_size", Detail: fmt.Sprintf("revenue %.0f, minimum %.0f", latest.Revenue, MinRevenue)}
+	size.Status = status(latest.Revenue >= MinRevenue)
+	checks = append(checks, size)
+
+	// Strong financial condition
+	condition := Check{Name: "strong_financial_condition"}
+	if latest.CurrentLiabilities > 0 {
+		currentRatio := latest.CurrentAssets / latest.CurrentLiabilities
+		netCurrentAssets := latest.CurrentAssets - latest.CurrentLiabilities
+		condition.Status = status(currentRatio >= 2 && latest.LongTermDebt <= netCurrentAssets)
+		condition.Detail = fmt.Sprintf("current ratio %.2f (min 2), long-term debt %.0f vs net current assets %.0f",
+			currentRatio, latest.LongTermDebt, netCurrentAssets)
+	} else {
+		condition.Status = StatusUnknown
+		condition.Detail = "current liabilities not reported"
+	}
+	checks = append(checks, condition)
+
+	// Earnings stability: positive earnings in each of the past ten years. A loss within the
+	// stored years fails it; otherwise it takes ten years of history to pass.
+	years := int(math.Min(float64(len(annual)), EarningsYears))
+	stability := Check{Name: "earnings_stability"}
+	negative := 0
+	for _, fs := range annual[:years] {
+		if fs.NetIncome <= 0 {
+			negative++
+		}
+	}
+	switch {
+	case negative > 0:
+		stability.Status = StatusFail
+	case years >= EarningsYears:
+		stability.Status = StatusPass
+	default:
+		stability.Status = StatusUnknown
+	}
+	stability.Detail = fmt.Sprintf("%d of %d years with losses (%d years required)", negative, years, EarningsYears)
+	checks = append(checks, stability)
+
+	// Dividend record: uninterrupted payments for twenty years. A record as long as the
+	// stored history cannot tell whether payments went on before it.
+	dividendRecord := Check{Name: "dividend_record"}
+	switch {
+	case div == nil:
+		dividendRecord.Status = StatusUnknown
+		dividendRecord.Detail = "dividend history not available"
+	case div.YearsPaid >= MinDividendYears:
+		dividendRecord.Status = StatusPass
+	case div.YearsPaid >= div.HistoryYears:
+		dividendRecord.Status = StatusUnknown
+	default:
+		dividendRecord.Status = StatusFail
+	}
+	if div != nil {
+		dividendRecord.Detail = fmt.Sprintf("%d consecutive years of dividends in %d years of history (min %d)",
+			div.YearsPaid, div.HistoryYears, MinDividendYears)
+	}
+	checks = append(checks, dividendRecord)
+
+	// Earnings growth: three-year average EPS up at least a third over ten years
+	earningsGrowth := Check{Name: "earnings_growth"}
+	if years >= EarningsYears {
+		recent := averageEPS(annual[:3])
+		early := averageEPS(annual[years-3 : years])
+		if early > 0 {
+			g := recent/early - 1
+			earningsGrowth.Status = status(g >= 1.0/3.0)
+			earningsGrowth.Detail = fmt.Sprintf("3-year average EPS growth %.1f%% over %d years (min 33%%)", g*100, years)
+		} else {
+			earningsGrowth.Status = StatusFail
+			earningsGrowth.Detail = "early average EPS not positive"
+		}
+	} else {
+		earningsGrowth.Status = StatusUnknown
+		earningsGrowth.Detail = fmt.Sprintf("%d years of history, at least %d required", years, EarningsYears)
+	}
+	checks = append(checks, earningsGrowth)
+
+	// Moderate P/E on three-year average earnings and moderate P/B
+	avgEPS := averageEPS(annual[:int(math.Min(float64(len(annual)), 3))])
+	pe := Check{Name: "moderate_pe"}
+	if price > 0 && avgEPS > 0 {
+		pe.Status = status(price/avgEPS <= 15)
+		pe.Detail = fmt.Sprintf("P/E on 3-year average EPS %.2f (max 15)", price/avgEPS)
+	} else {
+		pe.Status = StatusFail
+		pe.Detail = "average EPS not positive"
+	}
+	checks = append(checks, pe)
+
+	pb := Check{Name: "moderate_pb"}
+	shares := ratios.SharesOutstanding(latest)
+	if price > 0 && avgEPS > 0 && shares > 0 && latest.TotalEquity > 0 {
+		priceToBook := price / (latest.TotalEquity / shares)
+		pb.Status = status(priceToBook <= 1.5 || price/avgEPS*priceToBook <= Multiplier)
+		pb.Detail = fmt.Sprintf("P/B %.2f, P/E x P/B %.2f (max 22.5)", priceToBook, price/avgEPS*priceToBook)
+	} else {
+		pb.Status = StatusUnknown
+		pb.Detail = "book value or earnings not positive"
+	}
+	checks = append(checks, pb)
+
+	return checks
+}
+
+func averageEPS(statements []models.FinancialStatement) float64 {
+	if len(statements) == 0 {
+		return 0
+	}
+	sum := 0.0
+	for _, fs := range statements {
+		sum += fs.EPS
+	}
+	return sum / float64(len(statements))
+}
+
+func status(passed bool) string {
+	if passed {
+		return StatusPass
+	}
+	return StatusFail
+}
@@ -0,0 +1,130 @@
+package growth
+
+import (
+	"math"
+	"time"
+
+	"stockpick-backend/pkg/models"
+	"stockpick-backend/pkg/ratios"
+)
+
+// yearTolerance is how far a statement date may drift from an exact N-year lookback
+// and still be used as the base of an N-year growth rate.
+const yearTolerance = 45 * 24 * time.Hour
+
+// Rates holds the growth rates of a single line item. A nil rate means it could not
+// be computed, e.g. too little history or a non-positive base for a CAGR.
+type Rates struct {
+	YoY    *float64 `json:"yoy"`
+	CAGR3Y *float64 `json:"cagr_3y"`
+	CAGR5Y *float64 `json:"cagr_5y"`
+}
+
+// Metrics holds growth rates for the main per-company line items and the PEG ratio
+type Metrics struct {
+	Revenue   Rates    `json:"revenue"`
+	EPS       Rates    `json:"eps"`
+	FCF       Rates    `json:"free_cash_flow"`
+	BookValue Rates    `json:"book_value_per_share"`
+	PEG       *float64 `json:"peg_ratio"`
+}
+
+// Compute derives growth metrics from annual statements ordered newest first. pe is the
+// current P/E used for the PEG ratio; pass 0 to skip it.
+func Compute(annual []models.FinancialStatement, pe float64) Metrics {
+	m := Metrics{
+		Revenue:   rates(annual, func(fs models.FinancialStatement) float64 { return fs.Revenue }),
+		EPS:       rates(annual, func(fs models.FinancialStatement) float64 { return fs.EPS }),
+		FCF:       rates(annual, func(fs models.FinancialStatement) float64 { return fs.FreeCashFlow }),
+		BookValue: rates(annual, bookValuePerShare),
+	}
+
+	if epsGrowth, ok := m.EPSGrowth(); ok && pe > 0 && epsGrowth > 0 {
+		peg := pe / (epsGrowth * 100)
+		m.PEG = &peg
+	}
+	return m
+}
+
+// EPSGrowth returns the preferred EPS growth rate: the 3-year CAGR when available,
+// otherwise year-over-year growth.
+func (m Metrics) EPSGrowth() (float64, bool) {
+	return m.EPS.Preferred()
+}
+
+// Preferred returns the 3-year CAGR when available, otherwise year-over-year growth.
+func (r Rates) Preferred() (float64, bool) {
+	if r.CAGR3Y != nil {
+		return *r.CAGR3Y, true
+	}
+	if r.YoY != nil {
+		return *r.YoY, true
+	}
+	return 0, false
+}
+
+// CAGR returns the compound annual growth rate from start to end over the given years.
+// It is undefined unless both values are positive.
+func CAGR(start, end, years float64) (float64, bool) {
+	if start <= 0 || end <= 0 || years <= 0 {
+		return 0, false
+	}
+	return math.Pow(end/start, 1/years) - 1, true
+}
+
+// YoY returns the relative change from prev to curr, measured against |prev| so that
+// an improving loss shows as positive growth.
+func YoY(prev, curr float64) (float64, bool) {
+	if prev == 0 {
+		return 0, false
+	}
+	return (curr - prev) / math.Abs(prev), true
+}
+
+func rates(annual []models.FinancialStatement, value func(models.FinancialStatement) float64) Rates {
+	var r Rates
+	if len(annual) == 0 {
+		return r
+	}
+	latest := annual[0]
+
+	if prev, ok := yearsBack(annual, 1); ok {
+		if g, ok := YoY(value(prev), value(latest)); ok {
+			r.YoY = &g
+		}
+	}
+	if base, ok := yearsBack(annual, 3); ok {
+		if g, ok := CAGR(value(base), value(latest), 3); ok {
+			r.CAGR3Y = &g
+		}
+	}
+	if base, ok := yearsBack(annual, 5); ok {
+		if g, ok := CAGR(value(base), value(latest), 5); ok {
+			r.CAGR5Y = &g
+		}
+	}
+	return r
+}
+
+// yearsBack finds the statement dated n years before the latest one
+func yearsBack(annual []models.FinancialStatement, n int) (models.FinancialStatement, bool) {
+	target := annual[0].Date.AddDate(-n, 0, 0)
+	for _, fs := range annual[1:] {
+		diff := fs.Date.Sub(target)
+		if diff < 0 {
+			diff = -diff
+		}
+		if diff <= yearTolerance {
+			return fs, true
+		}
+	}
+	return models.FinancialStatement{}, false
+}
+
+// bookValuePerShare falls back to total equity when the share count is unknown
+func bookValuePerShare(fs models.FinancialStatement) float64 {
+	if shares := ratios.SharesOutstanding(fs); shares > 0 {
+		return fs.TotalEquity / shares
+	}
+	return fs.TotalEquity
+}
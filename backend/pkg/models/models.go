@@ -1,6 +1,7 @@
 package models
 
 import (
+	"encoding/json"
 	"time"
 
 	"github.com/google/uuid"
@@ -34,12 +35,40 @@ type HistoricalPrice struct {
 	PctChange  float64   `json:"pct_change" db:"pct_change"`
 }
 
+// StockSplit is a stock split: numerator new shares for every denominator old shares,
+// effective from the split date
+type StockSplit struct {
+	StockID     uuid.UUID `json:"stock_id" db:"stock_id"`
+	Date        time.Time `json:"date" db:"date"`
+	Numerator   float64   `json:"numerator" db:"numerator"`
+	Denominator float64   `json:"denominator" db:"denominator"`
+	CreatedAt   time.Time `json:"created_at" db:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at" db:"updated_at"`
+}
+
+// Dividend is a cash dividend per share, keyed by its ex-dividend date
+type Dividend struct {
+	StockID         uuid.UUID  `json:"stock_id" db:"stock_id"`
+	ExDate          time.Time  `json:"ex_date" db:"ex_date"`
+	Amount          float64    `json:"amount" db:"amount"`                   // As paid, per share at the time
+	AdjustedAmount  float64    `json:"adjusted_amount" db:"adjusted_amount"` // Restated for later splits
+	RecordDate      *time.Time `json:"record_date" db:"record_date"`
+	PaymentDate     *time.Time `json:"payment_date" db:"payment_date"`
+	DeclarationDate *time.Time `json:"declaration_date" db:"declaration_date"`
+	CreatedAt       time.Time  `json:"created_at" db:"created_at"`
+	UpdatedAt       time.Time  `json:"updated_at" db:"updated_at"`
+}
+
 // FinancialStatement represents annual or quarterly financial data
 type FinancialStatement struct {
 	StatementID      uuid.UUID `json:"statement_id" db:"statement_id"`
 	StockID          uuid.UUID `json:"stock_id" db:"stock_id"`
 	Date             time.Time `json:"date" db:"date"`
 	Period           string    `json:"period" db:"period"`
+	FilingDate       *time.Time `json:"filing_date" db:"filing_date"`     // Date the report was filed, nil if unknown
+	AcceptedDate     *time.Time `json:"accepted_date" db:"accepted_date"` // Time the filing was accepted by the SEC, nil if unknown
+	Revision         int       `json:"revision" db:"revision"`          // 1 for the original report, incremented on each restatement
+	KnownSince       time.Time `json:"known_since" db:"known_since"`    // When this revision became public
 	Revenue          float64   `json:"revenue" db:"revenue"`
 	NetIncome        float64   `json:"net_income" db:"net_income"`
 	EPS              float64   `json:"eps" db:"eps"`
@@ -47,10 +76,30 @@ type FinancialStatement struct {
 	TotalLiabilities float64   `json:"total_liabilities" db:"total_liabilities"`
 	TotalEquity      float64   `json:"total_equity" db:"total_equity"`
 	FreeCashFlow     float64   `json:"free_cash_flow" db:"free_cash_flow"`
+	GrossProfit      float64   `json:"gross_profit" db:"gross_profit"`
+	OperatingIncome  float64   `json:"operating_income" db:"operating_income"`
+	EBITDA           float64   `json:"ebitda" db:"ebitda"`
+	IncomeBeforeTax  float64   `json:"income_before_tax" db:"income_before_tax"`
+	IncomeTaxExpense float64   `json:"income_tax_expense" db:"income_tax_expense"`
+	TotalDebt        float64   `json:"total_debt" db:"total_debt"`
+	CashAndEquivalents float64 `json:"cash_and_equivalents" db:"cash_and_equivalents"`
+	SharesOutstanding float64  `json:"shares_outstanding" db:"shares_outstanding"`
+	CurrentAssets    float64   `json:"current_assets" db:"current_assets"`
+	CurrentLiabilities float64 `json:"current_liabilities" db:"current_liabilities"`
+	LongTermDebt     float64   `json:"long_term_debt" db:"long_term_debt"`
+	RetainedEarnings float64   `json:"retained_earnings" db:"retained_earnings"`
+	OperatingCashFlow float64  `json:"operating_cash_flow" db:"operating_cash_flow"`
 	DebtToEquityRatio float64   `json:"debt_to_equity_ratio" db:"debt_to_equity_ratio"`
 	PERatio          float64   `json:"p_e_ratio" db:"p_e_ratio"`
 	PBRatio          float64   `json:"p_b_ratio" db:"p_b_ratio"`
+	PSRatio          float64   `json:"p_s_ratio" db:"p_s_ratio"`
+	EVToEBITDA       float64   `json:"ev_to_ebitda" db:"ev_to_ebitda"`
+	FCFYield         float64   `json:"fcf_yield" db:"fcf_yield"`
 	ROIC             float64   `json:"roic" db:"roic"`
+	ROE              float64   `json:"roe" db:"roe"`
+	GrossMargin      float64   `json:"gross_margin" db:"gross_margin"`
+	OperatingMargin  float64   `json:"operating_margin" db:"operating_margin"`
+	NetMargin        float64   `json:"net_margin" db:"net_margin"`
 	CreatedAt        time.Time `json:"created_at" db:"created_at"`
 	UpdatedAt        time.Time `json:"updated_at" db:"updated_at"`
 }
@@ -73,6 +122,27 @@ type AnalystTarget struct {
 	UpdatedAt             time.Time `json:"updated_at" db:"updated_at"`
 }
 
+// AnalystEstimate represents a snapshot of the consensus estimates for a future fiscal period
+type AnalystEstimate struct {
+	EstimateID      uuid.UUID `json:"estimate_id" db:"estimate_id"`
+	StockID         uuid.UUID `json:"stock_id" db:"stock_id"`
+	FiscalDate      time.Time `json:"fiscal_date" db:"fiscal_date"`
+	Period          string    `json:"period" db:"period"` // "annual", "quarterly"
+	AsOf            time.Time `json:"as_of" db:"as_of"`   // Date the consensus was captured
+	RevenueLow      float64   `json:"revenue_low" db:"revenue_low"`
+	RevenueAvg      float64   `json:"revenue_avg" db:"revenue_avg"`
+	RevenueHigh     float64   `json:"revenue_high" db:"revenue_high"`
+	EBITDAAvg       float64   `json:"ebitda_avg" db:"ebitda_avg"`
+	NetIncomeAvg    float64   `json:"net_income_avg" db:"net_income_avg"`
+	EPSLow          float64   `json:"eps_low" db:"eps_low"`
+	EPSAvg          float64   `json:"eps_avg" db:"eps_avg"`
+	EPSHigh         float64   `json:"eps_high" db:"eps_high"`
+	RevenueAnalysts int       `json:"revenue_analysts" db:"revenue_analysts"`
+	EPSAnalysts     int       `json:"eps_analysts" db:"eps_analysts"`
+	CreatedAt       time.Time `json:"created_at" db:"created_at"`
+	UpdatedAt       time.Time `json:"updated_at" db:"updated_at"`
+}
+
 // SentimentScore represents social media sentiment data
 type SentimentScore struct {
 	SentimentID      uuid.UUID `json:"sentiment_id" db:"sentiment_id"`
@@ -86,3 +156,53 @@ type SentimentScore struct {
 	CreatedAt        time.Time `json:"created_at" db:"created_at"`
 	UpdatedAt        time.Time `json:"updated_at" db:"updated_at"`
 }
+
+// SavedScreen is a screener query saved by a user
+type SavedScreen struct {
+	ScreenID    uuid.UUID `json:"screen_id" db:"screen_id"`
+	UserID      string    `json:"user_id" db:"user_id"`
+	Name        string    `json:"name" db:"name"`
+	Query       string    `json:"query" db:"query"`
+	SortBy      string    `json:"sort_by" db:"sort_by"`
+	SortOrder   string    `json:"order" db:"sort_order"`
+	ResultLimit int       `json:"limit" db:"result_limit"`
+	CreatedAt   time.Time `json:"created_at" db:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at" db:"updated_at"`
+}
+
+// FactorAnalysis is a cached factor analysis
+type FactorAnalysis struct {
+	AnalysisID uuid.UUID       `json:"analysis_id" db:"analysis_id"`
+	CacheKey   string          `json:"cache_key" db:"cache_key"` // Hash of the normalized request
+	Request    json.RawMessage `json:"request" db:"request"`
+	Result     json.RawMessage `json:"result,omitempty" db:"result"` // Omitted in listings
+	CreatedAt  time.Time       `json:"created_at" db:"created_at"`
+	UpdatedAt  time.Time       `json:"updated_at" db:"updated_at"`
+}
+
+// Backfill job statuses
+const (
+	BackfillPending = "pending"
+	BackfillRunning = "running"
+	BackfillDone    = "done"
+	BackfillFailed  = "failed"
+)
+
+// BackfillDailyPrices is the data type of backfills of historical_prices
+const BackfillDailyPrices = "daily_prices"
+
+// BackfillJob is a queued re-ingestion of data missing for a stock over a range of days
+type BackfillJob struct {
+	JobID        uuid.UUID `json:"job_id" db:"job_id"`
+	StockID      uuid.UUID `json:"stock_id" db:"stock_id"`
+	Symbol       string    `json:"symbol" db:"symbol"` // Joined from stocks
+	DataType     string    `json:"data_type" db:"data_type"`
+	RangeStart   time.Time `json:"range_start" db:"range_start"`
+	RangeEnd     time.Time `json:"range_end" db:"range_end"`
+	Status       string    `json:"status" db:"status"`
+	Attempts     int       `json:"attempts" db:"attempts"`
+	RowsIngested int       `json:"rows_ingested" db:"rows_ingested"`
+	LastError    string    `json:"last_error,omitempty" db:"last_error"`
+	CreatedAt    time.Time `json:"created_at" db:"created_at"`
+	UpdatedAt    time.Time `json:"updated_at" db:"updated_at"`
+}
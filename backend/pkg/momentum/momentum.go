@@ -0,0 +1,166 @@
+// Package momentum classifies the price trend of a stock from its daily closes so that
+// value rankings can penalize or exclude falling knives.
+package momentum
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+
+	"stockpick-backend/pkg/models"
+	"stockpick-backend/pkg/technicals"
+)
+
+// Filter modes
+const (
+	FilterOff      = "off"      // Trend is reported but does not affect the score
+	FilterPenalize = "penalize" // Downtrends lose part of their score
+	FilterExclude  = "exclude"  // Strong downtrends are dropped
+)
+
+// Trend states
+const (
+	TrendUp      = "up"
+	TrendDown    = "down"
+	TrendNeutral = "neutral"
+	TrendUnknown = "unknown" // Not enough price history
+)
+
+// MaxLookback is the longest lookback in months; scoring loads a year of prices
+const MaxLookback = 12
+
+// Config controls the trend overlay
+type Config struct {
+	Filter    string  `json:"filter"`
+	Lookbacks []int   `json:"lookbacks"` // Momentum lookbacks in months, ascending
+	Threshold float64 `json:"threshold"` // Longest-lookback return below which a downtrend is strong
+	Penalty   float64 `json:"penalty"`   // Share of the score a strong downtrend forfeits; a plain downtrend forfeits half
+}
+
+// DefaultConfig reports 3, 6 and 12 month momentum without affecting scores
+func DefaultConfig() Config {
+	return Config{Filter: FilterOff, Lookbacks: []int{3, 6, 12}, Threshold: -0.2, Penalty: 0.3}
+}
+
+// Validate checks the configuration
+func (c Config) Validate() error {
+	switch c.Filter {
+	case FilterOff, FilterPenalize, FilterExclude:
+	default:
+		return fmt.Errorf("trend filter must be off, penalize or exclude")
+	}
+	if len(c.Lookbacks) == 0 {
+		return fmt.Errorf("at least one trend lookback is required")
+	}
+	for _, l := range c.Lookbacks {
+		if l < 1 || l > MaxLookback {
+			return fmt.Errorf("trend lookbacks must be between 1 and %d months", MaxLookback)
+		}
+	}
+	if c.Threshold >= 0 || c.Threshold <= -1 {
+		return fmt.Errorf("trend threshold must be between -1 and 0")
+	}
+	if c.Penalty < 0 || c.Penalty > 1 {
+		return fmt.Errorf("trend penalty must be between 0 and 1")
+	}
+	return nil
+}
+
+// Signal is the trend state of a stock
+type Signal struct {
+	Momentum        map[string]*float64 `json:"momentum"`         // Price return per lookback, keyed like "3m"; nil without enough history
+	AboveSMA200     *bool               `json:"above_sma_200"`    // Close above its 200-day average
+	GoldenCross     *bool               `json:"golden_cross"`     // 50-day average above the 200-day average
+	Trend           string              `json:"trend"`            // up, down, neutral or unknown
+	StrongDowntrend bool                `json:"strong_downtrend"` // Falling on every lookback and beyond the threshold
+	Penalty         float64             `json:"penalty"`          // Share of the score removed by the overlay
+	Excluded        bool                `json:"excluded,omitempty"`
+}
+
+// Evaluate classifies the trend of daily prices (oldest first). A stock is in a downtrend
+// when every lookback return is negative and it trades below its 200-day average (when
+// known), and in a strong downtrend when the longest lookback return is also below the
+// threshold. The penalty and exclusion follow the configured filter.
+func Evaluate(prices []models.HistoricalPrice, cfg Config) Signal {
+	lookbacks := append([]int(nil), cfg.Lookbacks...)
+	sort.Ints(lookbacks)
+
+	s := Signal{Momentum: map[string]*float64{}, Trend: TrendUnknown}
+	if len(prices) == 0 {
+		return s
+	}
+	latest := prices[len(prices)-1]
+
+	up, down, known := 0, 0, 0
+	var longest *float64
+	for _, months := range lookbacks {
+		r := priceReturn(prices, latest, months)
+		s.Momentum[strconv.Itoa(months)+"m"] = r
+		if r == nil {
+			continue
+		}
+		known++
+		longest = r
+		if *r > 0 {
+			up++
+		} else if *r < 0 {
+			down++
+		}
+	}
+
+	if sma200, ok := technicals.SMA(prices, 200).Last(); ok {
+		above := latest.ClosePrice > sma200
+		s.AboveSMA200 = &above
+		if sma50, ok := technicals.SMA(prices, 50).Last(); ok {
+			cross := sma50 > sma200
+			s.GoldenCross = &cross
+		}
+	}
+
+	if known == 0 {
+		return s
+	}
+	switch {
+	case up == known && (s.AboveSMA200 == nil || *s.AboveSMA200):
+		s.Trend = TrendUp
+	case down == known && (s.AboveSMA200 == nil || !*s.AboveSMA200):
+		s.Trend = TrendDown
+		s.StrongDowntrend = *longest < cfg.Threshold
+	default:
+		s.Trend = TrendNeutral
+	}
+
+	switch cfg.Filter {
+	case FilterPenalize:
+		if s.StrongDowntrend {
+			s.Penalty = cfg.Penalty
+		} else if s.Trend == TrendDown {
+			s.Penalty = cfg.Penalty / 2
+		}
+	case FilterExclude:
+		s.Excluded = s.StrongDowntrend
+	}
+	return s
+}
+
+// priceReturn is the return from the last close on or before the given number of months
+// before the latest close, nil when the history does not reach that far back
+func priceReturn(prices []models.HistoricalPrice, latest models.HistoricalPrice, months int) *float64 {
+	since := latest.Time.AddDate(0, -months, 0)
+	// Allow a few days of slack for histories loaded exactly that far back
+	if prices[0].Time.After(since.AddDate(0, 0, 7)) {
+		return nil
+	}
+	base := prices[0].ClosePrice
+	for _, p := range prices {
+		if p.Time.After(since) {
+			break
+		}
+		base = p.ClosePrice
+	}
+	if base <= 0 || latest.ClosePrice <= 0 {
+		return nil
+	}
+	r := latest.ClosePrice/base - 1
+	return &r
+}
@@ -0,0 +1,171 @@
+package peers
+
+import (
+	"sort"
+
+	"stockpick-backend/pkg/ratios"
+	"stockpick-backend/pkg/undervaluation"
+)
+
+// DefaultMarketCapBand keeps peers between a quarter and four times the stock's market cap
+const DefaultMarketCapBand = 4.0
+
+// Metrics are the compared columns of a stock. A nil metric is unavailable.
+type Metrics struct {
+	MarketCap           *float64 `json:"market_cap"`
+	PE                  *float64 `json:"p_e_ratio"`
+	PB                  *float64 `json:"p_b_ratio"`
+	PS                  *float64 `json:"p_s_ratio"`
+	EVToEBITDA          *float64 `json:"ev_to_ebitda"`
+	FCFYield            *float64 `json:"fcf_yield"`
+	ROIC                *float64 `json:"roic"`
+	ROE                 *float64 `json:"roe"`
+	RevenueGrowth       *float64 `json:"revenue_growth"`
+	EPSGrowth           *float64 `json:"eps_growth"`
+	FScore              *float64 `json:"f_score"`
+	ZScore              *float64 `json:"z_score"`
+	UndervaluationScore *float64 `json:"undervaluation_score"`
+}
+
+// Row is one line of the comparison table
+type Row struct {
+	Symbol      string `json:"symbol"`
+	CompanyName string `json:"company_name"`
+	Sector      string `json:"sector"`
+	Industry    string `json:"industry"`
+	Metrics
+}
+
+// Comparison compares a stock with its peers
+type Comparison struct {
+	Stock       Row      `json:"stock"`
+	GroupedBy   string   `json:"grouped_by"` // "industry", "sector" or "fmp"
+	Peers       []Row    `json:"peers"`
+	PeerMedians Metrics  `json:"peer_medians"`
+	Untracked   []string `json:"untracked,omitempty"` // FMP peers without stored data
+}
+
+// RowFromInputs builds a comparison row from a stock's scoring inputs and its score
+func RowFromInputs(in undervaluation.Inputs, score *undervaluation.UndervaluationScore) Row {
+	row := Row{
+		Symbol:      in.Stock.Symbol,
+		CompanyName: in.Stock.CompanyName,
+		Sector:      in.Stock.Sector,
+		Industry:    in.Stock.Industry,
+	}
+
+	if len(in.FinancialStatements) > 0 {
+		r := ratios.Compute(in.FinancialStatements[0], in.LatestPrice)
+		row.MarketCap = positive(r.MarketCap)
+		row.PE = positive(r.PE)
+		row.PB = positive(r.PB)
+		row.PS = positive(r.PS)
+		row.EVToEBITDA = positive(r.EVToEBITDA)
+		row.FCFYield = nonZero(r.FCFYield)
+		row.ROIC = nonZero(r.ROIC)
+		row.ROE = nonZero(r.ROE)
+	}
+	if in.Growth != nil {
+		if g, ok := in.Growth.Revenue.Preferred(); ok {
+			row.RevenueGrowth = &g
+		}
+		if g, ok := in.Growth.EPSGrowth(); ok {
+			row.EPSGrowth = &g
+		}
+	}
+	if in.Quality != nil {
+		if in.Quality.FScore != nil {
+			f := float64(in.Quality.FScore.Score)
+			row.FScore = &f
+		}
+		if in.Quality.ZScore != nil {
+			z := in.Quality.ZScore.Score
+			row.ZScore = &z
+		}
+	}
+	if score != nil {
+		s := score.Score
+		row.UndervaluationScore = &s
+	}
+	return row
+}
+
+// WithinBand reports whether a candidate's market cap is within band times the
+// target's market cap in either direction. Unknown market caps never match, so callers
+// skip the band when the target's market cap is unknown.
+func WithinBand(target, candidate *float64, band float64) bool {
+	if target == nil || candidate == nil || *target <= 0 || *candidate <= 0 {
+		return false
+	}
+	ratio := *candidate / *target
+	return ratio >= 1/band && ratio <= band
+}
+
+// ClosestByMarketCap sorts rows by market cap distance from the target and keeps the first limit
+func ClosestByMarketCap(target *float64, rows []Row, limit int) []Row {
+	distance := func(r Row) float64 {
+		if target == nil || r.MarketCap == nil || *target <= 0 || *r.MarketCap <= 0 {
+			return 1e18
+		}
+		d := *r.MarketCap / *target
+		if d < 1 {
+			d = 1 / d
+		}
+		return d
+	}
+	sort.SliceStable(rows, func(i, j int) bool { return distance(rows[i]) < distance(rows[j]) })
+	if limit > 0 && len(rows) > limit {
+		rows = rows[:limit]
+	}
+	return rows
+}
+
+// Medians returns the median of every metric across the rows, ignoring unavailable values
+func Medians(rows []Row) Metrics {
+	var medians Metrics
+	targets := medians.fields()
+	for i := range targets {
+		var values []float64
+		for j := range rows {
+			if v := *rows[j].Metrics.fields()[i]; v != nil {
+				values = append(values, *v)
+			}
+		}
+		if len(values) > 0 {
+			m := median(values)
+			*targets[i] = &m
+		}
+	}
+	return medians
+}
+
+// fields lists the metric columns so they can be aggregated uniformly
+func (m *Metrics) fields() []**float64 {
+	return []**float64{
+		&m.MarketCap, &m.PE, &m.PB, &m.PS, &m.EVToEBITDA, &m.FCFYield, &m.ROIC, &m.ROE,
+		&m.RevenueGrowth, &m.EPSGrowth, &m.FScore, &m.ZScore, &m.UndervaluationScore,
+	}
+}
+
+func median(values []float64) float64 {
+	sort.Float64s(values)
+	n := len(values)
+	if n%2 == 1 {
+		return values[n/2]
+	}
+	return (values[n/2-1] + values[n/2]) / 2
+}
+
+func positive(v float64) *float64 {
+	if v <= 0 {
+		return nil
+	}
+	return &v
+}
+
+func nonZero(v float64) *float64 {
+	if v == 0 {
+		return nil
+	}
+	return &v
+}
@@ -0,0 +1,147 @@
+package quality
+
+import (
+	"stockpick-backend/pkg/models"
+	"stockpick-backend/pkg/ratios"
+)
+
+// Altman Z-Score zone boundaries for public manufacturers
+const (
+	ZScoreSafe     = 2.99
+	ZScoreDistress = 1.81
+)
+
+// FScore is the Piotroski F-Score: nine binary tests comparing a fiscal year with the prior one
+type FScore struct {
+	Score int `json:"score"`
+	// Profitability
+	PositiveROA               bool `json:"positive_roa"`
+	PositiveOperatingCashFlow bool `json:"positive_operating_cash_flow"`
+	ImprovingROA              bool `json:"improving_roa"`
+	CashFlowExceedsIncome     bool `json:"cash_flow_exceeds_income"`
+	// Leverage, liquidity and source of funds
+	LowerLeverage      bool `json:"lower_leverage"`
+	HigherCurrentRatio bool `json:"higher_current_ratio"`
+	NoDilution         bool `json:"no_dilution"`
+	// Operating efficiency
+	HigherGrossMargin   bool `json:"higher_gross_margin"`
+	HigherAssetTurnover bool `json:"higher_asset_turnover"`
+}
+
+// ZScore is the Altman Z-Score with its weighted components
+type ZScore struct {
+	Score                    float64 `json:"score"`
+	Zone                     string  `json:"zone"` // "safe", "grey" or "distress"
+	WorkingCapitalToAssets   float64 `json:"working_capital_to_assets"`
+	RetainedEarningsToAssets float64 `json:"retained_earnings_to_assets"`
+	EBITToAssets             float64 `json:"ebit_to_assets"`
+	MarketCapToLiabilities   float64 `json:"market_cap_to_liabilities"`
+	SalesToAssets            float64 `json:"sales_to_assets"`
+}
+
+// Scores bundles the quality scores of a stock. A nil score could not be computed.
+type Scores struct {
+	FScore *FScore `json:"piotroski_f_score"`
+	ZScore *ZScore `json:"altman_z_score"`
+}
+
+// Compute derives the quality scores from annual statements ordered newest first
+// and the current share price.
+func Compute(annual []models.FinancialStatement, price float64) Scores {
+	var s Scores
+	if len(annual) >= 2 {
+		if f, ok := PiotroskiFScore(annual[0], annual[1]); ok {
+			s.FScore = &f
+		}
+	}
+	if len(annual) >= 1 {
+		if z, ok := AltmanZScore(annual[0], price); ok {
+			s.ZScore = &z
+		}
+	}
+	return s
+}
+
+// PiotroskiFScore scores the current fiscal year against the prior one. It returns
+// false when either year lacks total assets. The leverage and dilution tests fail when
+// either year lacks long-term debt or a share count, rather than comparing zeros.
+func PiotroskiFScore(current, prior models.FinancialStatement) (FScore, bool) {
+	if current.TotalAssets <= 0 || prior.TotalAssets <= 0 {
+		return FScore{}, false
+	}
+
+	hasDebt := current.LongTermDebt > 0 && prior.LongTermDebt > 0
+	shares, priorShares := ratios.SharesOutstanding(current), ratios.SharesOutstanding(prior)
+	hasShares := shares > 0 && priorShares > 0
+	roa := current.NetIncome / current.TotalAssets
+	priorROA := prior.NetIncome / prior.TotalAssets
+
+	f := FScore{
+		PositiveROA:               roa > 0,
+		PositiveOperatingCashFlow: current.OperatingCashFlow > 0,
+		ImprovingROA:              roa > priorROA,
+		CashFlowExceedsIncome:     current.OperatingCashFlow/current.TotalAssets > roa,
+		LowerLeverage:             hasDebt && current.LongTermDebt/current.TotalAssets <= prior.LongTermDebt/prior.TotalAssets,
+		HigherCurrentRatio:        currentRatio(current) > currentRatio(prior),
+		NoDilution:                hasShares && shares <= priorShares,
+		HigherGrossMargin:         grossMargin(current) > grossMargin(prior),
+		HigherAssetTurnover:       current.Revenue/current.TotalAssets > prior.Revenue/prior.TotalAssets,
+	}
+
+	for _, passed := range []bool{
+		f.PositiveROA, f.PositiveOperatingCashFlow, f.ImprovingROA, f.CashFlowExceedsIncome,
+		f.LowerLeverage, f.HigherCurrentRatio, f.NoDilution, f.HigherGrossMargin, f.HigherAssetTurnover,
+	} {
+		if passed {
+			f.Score++
+		}
+	}
+	return f, true
+}
+
+// AltmanZScore computes the original Altman Z-Score, using operating income as EBIT
+// and price times shares outstanding as the market value of equity. It returns false
+// when total assets, total liabilities or the market value are unknown.
+func AltmanZScore(fs models.FinancialStatement, price float64) (ZScore, bool) {
+	marketCap := price * ratios.SharesOutstanding(fs)
+	if fs.TotalAssets <= 0 || fs.TotalLiabilities <= 0 || marketCap <= 0 {
+		return ZScore{}, false
+	}
+
+	z := ZScore{
+		WorkingCapitalToAssets:   (fs.CurrentAssets - fs.CurrentLiabilities) / fs.TotalAssets,
+		RetainedEarningsToAssets: fs.RetainedEarnings / fs.TotalAssets,
+		EBITToAssets:             fs.OperatingIncome / fs.TotalAssets,
+		MarketCapToLiabilities:   marketCap / fs.TotalLiabilities,
+		SalesToAssets:            fs.Revenue / fs.TotalAssets,
+	}
+	z.Score = 1.2*z.WorkingCapitalToAssets +
+		1.4*z.RetainedEarningsToAssets +
+		3.3*z.EBITToAssets +
+		0.6*z.MarketCapToLiabilities +
+		1.0*z.SalesToAssets
+
+	switch {
+	case z.Score > ZScoreSafe:
+		z.Zone = "safe"
+	case z.Score >= ZScoreDistress:
+		z.Zone = "grey"
+	default:
+		z.Zone = "distress"
+	}
+	return z, true
+}
+
+func currentRatio(fs models.FinancialStatement) float64 {
+	if fs.CurrentLiabilities <= 0 {
+		return 0
+	}
+	return fs.CurrentAssets / fs.CurrentLiabilities
+}
+
+func grossMargin(fs models.FinancialStatement) float64 {
+	if fs.Revenue <= 0 {
+		return 0
+	}
+	return fs.GrossProfit / fs.Revenue
+}
@@ -0,0 +1,125 @@
+package ratios
+
+import (
+	"math"
+
+	"stockpick-backend/pkg/models"
+)
+
+// DefaultTaxRate is used for NOPAT when a statement has no usable effective tax rate
+const DefaultTaxRate = 0.21
+
+// MaxRatio bounds the magnitude of a ratio; beyond it the denominator is close enough to
+// zero that the ratio carries no information (and would overflow the NUMERIC(10, 4)
+// columns), so it is reported as 0 like other ratios that cannot be computed
+const MaxRatio = 1e5
+
+// quartersPerYear annualizes the flows of quarterly statements
+const quartersPerYear = 4
+
+// Ratios holds the valuation, return and margin ratios derived from a financial statement
+// and a share price. A ratio that cannot be computed (missing input, non-positive
+// denominator) is reported as 0.
+type Ratios struct {
+	Price           float64 `json:"price"`
+	MarketCap       float64 `json:"market_cap"`
+	EnterpriseValue float64 `json:"enterprise_value"`
+	PE              float64 `json:"p_e_ratio"`
+	PB              float64 `json:"p_b_ratio"`
+	PS              float64 `json:"p_s_ratio"`
+	EVToEBITDA      float64 `json:"ev_to_ebitda"`
+	FCFYield        float64 `json:"fcf_yield"`
+	ROIC            float64 `json:"roic"`
+	ROE             float64 `json:"roe"`
+	DebtToEquity    float64 `json:"debt_to_equity_ratio"`
+	GrossMargin     float64 `json:"gross_margin"`
+	OperatingMargin float64 `json:"operating_margin"`
+	NetMargin       float64 `json:"net_margin"`
+}
+
+// Compute derives ratios from a statement at the given price. Price-based ratios
+// (P/E, P/B, P/S, EV/EBITDA, FCF yield) are left at 0 when price is 0. The flows of a
+// quarterly statement are annualized for the ratios against price or capital, so that
+// they compare with annual and TTM figures; margins need no annualization.
+func Compute(fs models.FinancialStatement, price float64) Ratios {
+	r := Ratios{Price: price}
+	annualize := 1.0
+	if fs.Period == "quarterly" {
+		annualize = quartersPerYear
+	}
+
+	shares := SharesOutstanding(fs)
+	if price > 0 {
+		r.MarketCap = price * shares
+		if fs.EPS > 0 {
+			r.PE = price / (fs.EPS * annualize)
+		}
+		if r.MarketCap > 0 {
+			r.EnterpriseValue = r.MarketCap + fs.TotalDebt - fs.CashAndEquivalents
+			r.PB = positiveDiv(r.MarketCap, fs.TotalEquity)
+			r.PS = positiveDiv(r.MarketCap, fs.Revenue*annualize)
+			r.EVToEBITDA = positiveDiv(r.EnterpriseValue, fs.EBITDA*annualize)
+			r.FCFYield = fs.FreeCashFlow * annualize / r.MarketCap
+		}
+	}
+
+	investedCapital := fs.TotalDebt + fs.TotalEquity - fs.CashAndEquivalents
+	r.ROIC = positiveDiv(fs.OperatingIncome*annualize*(1-TaxRate(fs)), investedCapital)
+	r.ROE = positiveDiv(fs.NetIncome*annualize, fs.TotalEquity)
+	r.DebtToEquity = positiveDiv(fs.TotalDebt, fs.TotalEquity)
+	r.GrossMargin = positiveDiv(fs.GrossProfit, fs.Revenue)
+	r.OperatingMargin = positiveDiv(fs.OperatingIncome, fs.Revenue)
+	r.NetMargin = positiveDiv(fs.NetIncome, fs.Revenue)
+
+	for _, v := range []*float64{&r.PE, &r.PB, &r.PS, &r.EVToEBITDA, &r.FCFYield, &r.ROIC, &r.ROE,
+		&r.DebtToEquity, &r.GrossMargin, &r.OperatingMargin, &r.NetMargin} {
+		if math.Abs(*v) >= MaxRatio {
+			*v = 0
+		}
+	}
+	return r
+}
+
+// ApplyTo copies the ratios onto the statement's ratio fields.
+func (r Ratios) ApplyTo(fs *models.FinancialStatement) {
+	fs.PERatio = r.PE
+	fs.PBRatio = r.PB
+	fs.PSRatio = r.PS
+	fs.EVToEBITDA = r.EVToEBITDA
+	fs.FCFYield = r.FCFYield
+	fs.ROIC = r.ROIC
+	fs.ROE = r.ROE
+	fs.DebtToEquityRatio = r.DebtToEquity
+	fs.GrossMargin = r.GrossMargin
+	fs.OperatingMargin = r.OperatingMargin
+	fs.NetMargin = r.NetMargin
+}
+
+// SharesOutstanding returns the statement's share count, falling back to
+// net income / EPS when the count was not reported.
+func SharesOutstanding(fs models.FinancialStatement) float64 {
+	if fs.SharesOutstanding > 0 {
+		return fs.SharesOutstanding
+	}
+	if fs.EPS != 0 && fs.NetIncome != 0 {
+		return math.Abs(fs.NetIncome / fs.EPS)
+	}
+	return 0
+}
+
+// TaxRate returns the effective tax rate of a statement, clamped to [0, 1],
+// or DefaultTaxRate when pre-tax income is not positive.
+func TaxRate(fs models.FinancialStatement) float64 {
+	if fs.IncomeBeforeTax <= 0 {
+		return DefaultTaxRate
+	}
+	return math.Max(0, math.Min(1, fs.IncomeTaxExpense/fs.IncomeBeforeTax))
+}
+
+// positiveDiv divides a by b, returning 0 unless b is positive
+func positiveDiv(a, b float64) float64 {
+	if b <= 0 {
+		return 0
+	}
+	return a / b
+}
@@ -0,0 +1,258 @@
+// Package risk computes return and risk statistics from daily closes: volatility, downside
+// deviation, drawdowns, beta and correlation against a benchmark, and risk-adjusted returns.
+package risk
+
+import (
+	"math"
+	"time"
+
+	"stockpick-backend/pkg/models"
+)
+
+// TradingDaysPerYear annualizes daily statistics
+const TradingDaysPerYear = 252
+
+// DefaultWindow is the length of rolling statistics, about three months of trading days
+const DefaultWindow = 63
+
+// minReturns is the fewest daily returns statistics are computed from
+const minReturns = 20
+
+// Metrics are the risk statistics of a price series. Annualized figures assume 252
+// trading days; pointers are nil when the statistic is undefined.
+type Metrics struct {
+	Start               time.Time `json:"start"`
+	End                 time.Time `json:"end"`
+	Observations        int       `json:"observations"` // Daily returns
+	AnnualReturn        *float64  `json:"annual_return"`
+	Volatility          *float64  `json:"volatility"`
+	DownsideDeviation   *float64  `json:"downside_deviation"`    // Annualized deviation of returns below the risk-free rate
+	MaxDrawdown         float64   `json:"max_drawdown"`          // Largest peak-to-trough decline, as a positive fraction
+	MaxDrawdownDuration int       `json:"max_drawdown_duration"` // Longest time below a previous peak, in calendar days
+	CurrentDrawdown     float64   `json:"current_drawdown"`
+	Beta                *float64  `json:"beta"`        // Nil without benchmark prices
+	Correlation         *float64  `json:"correlation"` // Of daily returns with the benchmark
+	Sharpe              *float64  `json:"sharpe"`
+	Sortino             *float64  `json:"sortino"`
+}
+
+// Point is the rolling risk statistics of the window ending on a date
+type Point struct {
+	Date              time.Time `json:"date"`
+	Volatility        *float64  `json:"volatility"`
+	DownsideDeviation *float64  `json:"downside_deviation"`
+	MaxDrawdown       float64   `json:"max_drawdown"`
+	Beta              *float64  `json:"beta"`
+	Correlation       *float64  `json:"correlation"`
+	Sharpe            *float64  `json:"sharpe"`
+	Sortino           *float64  `json:"sortino"`
+}
+
+// Compute derives the risk statistics of prices (oldest first). Benchmark prices are
+// optional and matched by trading day. It returns false with fewer than 20 daily returns.
+func Compute(prices, benchmark []models.HistoricalPrice, riskFreeRate float64) (Metrics, bool) {
+	return compute(priced(prices), closeMap(benchmark), riskFreeRate)
+}
+
+func compute(prices []models.HistoricalPrice, benchmark map[string]float64, riskFreeRate float64) (Metrics, bool) {
+	if len(prices)-1 < minReturns {
+		return Metrics{}, false
+	}
+	m := Metrics{Start: prices[0].Time, End: prices[len(prices)-1].Time, Observations: len(prices) - 1}
+
+	returns := dailyReturns(prices)
+	rf := riskFreeRate / TradingDaysPerYear
+	mean, std := MeanStd(returns)
+	if years := m.End.Sub(m.Start).Hours() / 24 / 365.25; years > 0 {
+		total := prices[len(prices)-1].ClosePrice / prices[0].ClosePrice
+		m.AnnualReturn = float(math.Pow(total, 1/years) - 1)
+	}
+	m.Volatility = float(std * math.Sqrt(TradingDaysPerYear))
+	downside := downsideDeviation(returns, rf)
+	m.DownsideDeviation = float(downside * math.Sqrt(TradingDaysPerYear))
+	if std > 0 {
+		m.Sharpe = float((mean - rf) / std * math.Sqrt(TradingDaysPerYear))
+	}
+	if downside > 0 {
+		m.Sortino = float((mean - rf) / downside * math.Sqrt(TradingDaysPerYear))
+	}
+
+	m.MaxDrawdown, m.MaxDrawdownDuration, m.CurrentDrawdown = drawdowns(prices)
+	m.Beta, m.Correlation = against(prices, benchmark)
+	return m, true
+}
+
+// Rolling computes the statistics of every window of the given number of daily returns,
+// one point per date from the first full window on
+func Rolling(prices, benchmark []models.HistoricalPrice, window int, riskFreeRate float64) []Point {
+	prices = priced(prices)
+	benchCloses := closeMap(benchmark)
+	if window < minReturns {
+		window = minReturns
+	}
+	var points []Point
+	for end := window; end < len(prices); end++ {
+		m, ok := compute(prices[end-window:end+1], benchCloses, riskFreeRate)
+		if !ok {
+			continue
+		}
+		points = append(points, Point{
+			Date:              prices[end].Time,
+			Volatility:        m.Volatility,
+			DownsideDeviation: m.DownsideDeviation,
+			MaxDrawdown:       m.MaxDrawdown,
+			Beta:              m.Beta,
+			Correlation:       m.Correlation,
+			Sharpe:            m.Sharpe,
+			Sortino:           m.Sortino,
+		})
+	}
+	return points
+}
+
+// DrawdownPenalty is the share of a valuation score forfeited for a deep drawdown, which
+// often marks a value trap rather than a bargain: nothing up to a 40% decline, rising to
+// 30% of the score at an 80% decline
+func DrawdownPenalty(maxDrawdown float64) float64 {
+	return math.Min(math.Max((maxDrawdown-0.4)/0.4*0.3, 0), 0.3)
+}
+
+// priced drops bars without a positive close
+func priced(prices []models.HistoricalPrice) []models.HistoricalPrice {
+	for _, p := range prices {
+		if p.ClosePrice <= 0 {
+			var out []models.HistoricalPrice
+			for _, q := range prices {
+				if q.ClosePrice > 0 {
+					out = append(out, q)
+				}
+			}
+			return out
+		}
+	}
+	return prices
+}
+
+func dailyReturns(prices []models.HistoricalPrice) []float64 {
+	returns := make([]float64, 0, len(prices)-1)
+	for i := 1; i < len(prices); i++ {
+		returns = append(returns, prices[i].ClosePrice/prices[i-1].ClosePrice-1)
+	}
+	return returns
+}
+
+// downsideDeviation is the daily root mean square of returns below the target
+func downsideDeviation(returns []float64, target float64) float64 {
+	ss := 0.0
+	for _, r := range returns {
+		if r < target {
+			ss += (r - target) * (r - target)
+		}
+	}
+	return math.Sqrt(ss / float64(len(returns)))
+}
+
+// drawdowns returns the deepest decline from a running peak, the longest time spent below
+// a peak in calendar days (up to the end when not recovered) and the decline at the end
+func drawdowns(prices []models.HistoricalPrice) (float64, int, float64) {
+	peak, peakTime := prices[0].ClosePrice, prices[0].Time
+	maxDrawdown, longest, current := 0.0, 0, 0.0
+	for _, p := range prices[1:] {
+		if p.ClosePrice >= peak {
+			if days := int(p.Time.Sub(peakTime).Hours() / 24); current > 0 && days > longest {
+				longest = days
+			}
+			peak, peakTime, current = p.ClosePrice, p.Time, 0
+			continue
+		}
+		current = 1 - p.ClosePrice/peak
+		maxDrawdown = math.Max(maxDrawdown, current)
+	}
+	if current > 0 {
+		if days := int(prices[len(prices)-1].Time.Sub(peakTime).Hours() / 24); days > longest {
+			longest = days
+		}
+	}
+	return maxDrawdown, longest, current
+}
+
+// closeMap indexes the positive closes of the benchmark by trading day
+func closeMap(benchmark []models.HistoricalPrice) map[string]float64 {
+	closes := make(map[string]float64, len(benchmark))
+	for _, b := range benchmark {
+		if b.ClosePrice > 0 {
+			closes[dayKey(b.Time)] = b.ClosePrice
+		}
+	}
+	return closes
+}
+
+// against computes beta and correlation of daily returns with the benchmark over the
+// trading days both have a close for
+func against(prices []models.HistoricalPrice, closes map[string]float64) (*float64, *float64) {
+	if len(closes) == 0 {
+		return nil, nil
+	}
+
+	var xs, ys []float64 // Benchmark and stock returns
+	prevStock, prevBench := 0.0, 0.0
+	for _, p := range prices {
+		b, ok := closes[dayKey(p.Time)]
+		if !ok {
+			continue
+		}
+		if prevBench > 0 {
+			xs = append(xs, b/prevBench-1)
+			ys = append(ys, p.ClosePrice/prevStock-1)
+		}
+		prevStock, prevBench = p.ClosePrice, b
+	}
+	if len(xs) < minReturns {
+		return nil, nil
+	}
+
+	mx, sx := MeanStd(xs)
+	my, sy := MeanStd(ys)
+	if sx == 0 {
+		return nil, nil
+	}
+	cov := 0.0
+	for i := range xs {
+		cov += (xs[i] - mx) * (ys[i] - my)
+	}
+	cov /= float64(len(xs) - 1)
+	beta := float(cov / (sx * sx))
+	if sy == 0 {
+		return beta, nil
+	}
+	return beta, float(cov / (sx * sy))
+}
+
+// dayKey identifies a trading day independently of the time's location
+func dayKey(t time.Time) string {
+	return t.Format("2006-01-02")
+}
+
+// MeanStd returns the mean and sample standard deviation
+func MeanStd(values []float64) (float64, float64) {
+	if len(values) < 2 {
+		return 0, 0
+	}
+	sum := 0.0
+	for _, v := range values {
+		sum += v
+	}
+	mean := sum / float64(len(values))
+	ss := 0.0
+	for _, v := range values {
+		ss += (v - mean) * (v - mean)
+	}
+	return mean, math.Sqrt(ss / float64(len(values)-1))
+}
+
+func float(v float64) *float64 {
+	if math.IsNaN(v) || math.IsInf(v, 0) {
+		return nil
+	}
+	return &v
+}
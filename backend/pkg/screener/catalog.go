@@ -0,0 +1,415 @@
+package screener
+
+import (
+	"sort"
+
+	"stockpick-backend/pkg/analyst"
+	"stockpick-backend/pkg/dividends"
+	"stockpick-backend/pkg/models"
+	"stockpick-backend/pkg/ratios"
+	"stockpick-backend/pkg/risk"
+	"stockpick-backend/pkg/technicals"
+	"stockpick-backend/pkg/undervaluation"
+)
+
+// Field is a metric of the catalog that screens can reference
+type Field struct {
+	Name        string `json:"name"`
+	Type        Type   `json:"-"`
+	TypeName    string `json:"type"`
+	Category    string `json:"category"`
+	Description string `json:"description"`
+	Column      string `json:"-"` // Column of the stocks table, empty for computed metrics
+
+	value func(s *source) Value
+}
+
+// source is the data a record's metrics are computed from
+type source struct {
+	in     undervaluation.Inputs
+	score  *undervaluation.UndervaluationScore
+	ratios *ratios.Ratios
+	risk   *risk.Metrics
+}
+
+// Metric categories
+const (
+	CategoryProfile      = "profile"
+	CategoryFundamentals = "fundamentals"
+	CategoryRatios       = "ratios"
+	CategoryGrowth       = "growth"
+	CategoryAnalyst      = "analyst"
+	CategoryScores       = "scores"
+	CategoryTechnicals   = "technicals"
+	CategoryRisk         = "risk"
+	CategoryDividends    = "dividends"
+)
+
+var catalog = []Field{
+	stockField("symbol", "symbol", "Ticker symbol", func(s *models.Stock) string { return s.Symbol }),
+	stockField("company_name", "company_name", "Company name", func(s *models.Stock) string { return s.CompanyName }),
+	stockField("exchange", "exchange", "Listing exchange", func(s *models.Stock) string { return s.Exchange }),
+	stockField("sector", "sector", "Sector", func(s *models.Stock) string { return s.Sector }),
+	stockField("industry", "industry", "Industry", func(s *models.Stock) string { return s.Industry }),
+	stockField("currency", "currency", "Trading currency", func(s *models.Stock) string { return s.Currency }),
+	{Name: "is_active", Type: TypeBool, Category: CategoryProfile, Description: "Whether the stock is actively trading", Column: "is_active",
+		value: func(s *source) Value { return Bool(s.in.Stock.IsActive) }},
+
+	statementField("revenue", "Revenue of the scoring period", func(fs models.FinancialStatement) float64 { return fs.Revenue }),
+	statementField("net_income", "Net income of the scoring period", func(fs models.FinancialStatement) float64 { return fs.NetIncome }),
+	statementField("eps", "Earnings per share of the scoring period", func(fs models.FinancialStatement) float64 { return fs.EPS }),
+	statementField("free_cash_flow", "Free cash flow of the scoring period", func(fs models.FinancialStatement) float64 { return fs.FreeCashFlow }),
+	statementField("total_debt", "Total debt", func(fs models.FinancialStatement) float64 { return fs.TotalDebt }),
+
+	{Name: "price", Type: TypeNumber, Category: CategoryRatios, Description: "Latest close",
+		value: func(s *source) Value { return positive(s.in.LatestPrice) }},
+	ratioField("market_cap", "Market capitalization", true, func(r *ratios.Ratios) float64 { return r.MarketCap }),
+	ratioField("enterprise_value", "Enterprise value", true, func(r *ratios.Ratios) float64 { return r.EnterpriseValue }),
+	ratioField("pe", "Price to earnings", true, func(r *ratios.Ratios) float64 { return r.PE }),
+	ratioField("pb", "Price to book", true, func(r *ratios.Ratios) float64 { return r.PB }),
+	ratioField("ps", "Price to sales", true, func(r *ratios.Ratios) float64 { return r.PS }),
+	ratioField("ev_ebitda", "Enterprise value to EBITDA", true, func(r *ratios.Ratios) float64 { return r.EVToEBITDA }),
+	ratioField("fcf_yield", "Free cash flow yield", false, func(r *ratios.Ratios) float64 { return r.FCFYield }),
+	ratioField("roic", "Return on invested capital", false, func(r *ratios.Ratios) float64 { return r.ROIC }),
+	ratioField("roe", "Return on equity", false, func(r *ratios.Ratios) float64 { return r.ROE }),
+	ratioField("debt_to_equity", "Debt to equity", false, func(r *ratios.Ratios) float64 { return r.DebtToEquity }),
+	ratioField("gross_margin", "Gross margin", false, func(r *ratios.Ratios) float64 { return r.GrossMargin }),
+	ratioField("operating_margin", "Operating margin", false, func(r *ratios.Ratios) float64 { return r.OperatingMargin }),
+	ratioField("net_margin", "Net margin", false, func(r *ratios.Ratios) float64 { return r.NetMargin }),
+
+	{Name: "revenue_growth", Type: TypeNumber, Category: CategoryGrowth, Description: "Preferred revenue growth rate (CAGR or YoY)",
+		value: func(s *source) Value {
+			if s.in.Growth == nil {
+				return Null(TypeNumber)
+			}
+			return optional(s.in.Growth.Revenue.Preferred())
+		}},
+	{Name: "eps_growth", Type: TypeNumber, Category: CategoryGrowth, Description: "Preferred EPS growth rate (CAGR or YoY)",
+		value: func(s *source) Value {
+			if s.in.Growth == nil {
+				return Null(TypeNumber)
+			}
+			return optional(s.in.Growth.EPSGrowth())
+		}},
+	{Name: "fcf_growth", Type: TypeNumber, Category: CategoryGrowth, Description: "Preferred free cash flow growth rate",
+		value: func(s *source) Value {
+			if s.in.Growth == nil {
+				return Null(TypeNumber)
+			}
+			return optional(s.in.Growth.FCF.Preferred())
+		}},
+	{Name: "peg", Type: TypeNumber, Category: CategoryGrowth, Description: "P/E to EPS growth",
+		value: func(s *source) Value {
+			if s.in.Growth == nil {
+				return Null(TypeNumber)
+			}
+			return pointer(s.in.Growth.PEG)
+		}},
+	{Name: "forward_pe", Type: TypeNumber, Category: CategoryGrowth, Description: "Price to next fiscal year consensus EPS",
+		value: func(s *source) Value {
+			if s.in.Estimates == nil {
+				return Null(TypeNumber)
+			}
+			return pointer(s.in.Estimates.ForwardPE)
+		}},
+	{Name: "expected_eps_growth", Type: TypeNumber, Category: CategoryGrowth, Description: "Consensus EPS growth for the next fiscal year",
+		value: func(s *source) Value {
+			if s.in.Estimates == nil {
+				return Null(TypeNumber)
+			}
+			return pointer(s.in.Estimates.ExpectedEPSGrowth)
+		}},
+
+	{Name: "upside", Type: TypeNumber, Category: CategoryAnalyst, Description: "Consensus price target upside",
+		value: func(s *source) Value {
+			if len(s.in.AnalystTargets) == 0 || s.in.AnalystTargets[0].ConsensusPriceTarget <= 0 {
+				return Null(TypeNumber)
+			}
+			return Number((s.in.AnalystTargets[0].ConsensusPriceTarget - s.in.LatestPrice) / s.in.LatestPrice)
+		}},
+	{Name: "rating", Type: TypeNumber, Category: CategoryAnalyst, Description: "Consensus rating value (1 strong sell to 5 strong buy)",
+		value: func(s *source) Value {
+			if len(s.in.AnalystTargets) == 0 {
+				return Null(TypeNumber)
+			}
+			return positive(s.in.AnalystTargets[0].ConsensusRatingValue)
+		}},
+	{Name: "target_revision", Type: TypeNumber, Category: CategoryAnalyst, Description: "Change of the consensus target over 90 (or 30) days",
+		value: func(s *source) Value {
+			f, ok := analyst.Compute(s.in.AnalystTargets)
+			if !ok {
+				return Null(TypeNumber)
+			}
+			return optional(f.TargetRevision())
+		}},
+	{Name: "analyst_count", Type: TypeNumber, Category: CategoryAnalyst, Description: "Analysts behind the consensus",
+		value: func(s *source) Value {
+			f, ok := analyst.Compute(s.in.AnalystTargets)
+			if !ok {
+				return Null(TypeNumber)
+			}
+			return positive(float64(f.AnalystCount))
+		}},
+	{Name: "eps_revision", Type: TypeNumber, Category: CategoryAnalyst, Description: "Revision of the forward EPS consensus over 90 (or 30) days",
+		value: func(s *source) Value {
+			if s.in.Estimates == nil {
+				return Null(TypeNumber)
+			}
+			return optional(s.in.Estimates.Revision())
+		}},
+
+	{Name: "f_score", Type: TypeNumber, Category: CategoryScores, Description: "Piotroski F-Score (0-9)",
+		value: func(s *source) Value {
+			if s.in.Quality == nil || s.in.Quality.FScore == nil {
+				return Null(TypeNumber)
+			}
+			return Number(float64(s.in.Quality.FScore.Score))
+		}},
+	{Name: "z_score", Type: TypeNumber, Category: CategoryScores, Description: "Altman Z-Score",
+		value: func(s *source) Value {
+			if s.in.Quality == nil || s.in.Quality.ZScore == nil {
+				return Null(TypeNumber)
+			}
+			return Number(s.in.Quality.ZScore.Score)
+		}},
+	scoreField("score", "Composite undervaluation score (0-100)", func(u *undervaluation.UndervaluationScore) float64 { return u.Score }),
+	scoreField("fundamental_score", "Fundamental component score (0-100)", func(u *undervaluation.UndervaluationScore) float64 { return u.FundamentalScore }),
+	scoreField("analyst_score", "Analyst component score (0-100)", func(u *undervaluation.UndervaluationScore) float64 { return u.AnalystScore }),
+	scoreField("sentiment_score", "Sentiment component score (0-100)", func(u *undervaluation.UndervaluationScore) float64 { return u.SentimentScore }),
+	scoreField("confidence", "Share of the score backed by fresh data (0-1)", func(u *undervaluation.UndervaluationScore) float64 { return u.Confidence }),
+	{Name: "sentiment", Type: TypeNumber, Category: CategoryScores, Description: "Time-decayed sentiment across sources (0-1)",
+		value: func(s *source) Value {
+			if s.score == nil || s.score.Sentiment == nil {
+				return Null(TypeNumber)
+			}
+			return Number(s.score.Sentiment.Score)
+		}},
+
+	returnField("return_1m", "Price return over 1 month", 0, 1),
+	returnField("return_3m", "Price return over 3 months", 0, 3),
+	returnField("return_6m", "Price return over 6 months", 0, 6),
+	returnField("return_1y", "Price return over 1 year", 1, 0),
+	{Name: "pct_from_52w_high", Type: TypeNumber, Category: CategoryTechnicals, Description: "Latest close relative to the 52-week high (0 at the high)",
+		value: func(s *source) Value {
+			high := 0.0
+			for _, p := range s.in.Prices {
+				if p.Time.After(s.in.LatestPriceTime.AddDate(-1, 0, 0)) && p.HighPrice > high {
+					high = p.HighPrice
+				}
+			}
+			if high <= 0 {
+				return Null(TypeNumber)
+			}
+			// The history may be adjusted, so compare it with its own last close
+			return Number(s.in.Prices[len(s.in.Prices)-1].ClosePrice/high - 1)
+		}},
+	technicalField("rsi_14", "14-day relative strength index (0-100)", func(prices []models.HistoricalPrice) (float64, bool) {
+		return technicals.RSI(prices, 14).Last()
+	}),
+	technicalField("pct_from_sma_200", "Latest close relative to its 200-day simple moving average", func(prices []models.HistoricalPrice) (float64, bool) {
+		sma, ok := technicals.SMA(prices, 200).Last()
+		if !ok || sma <= 0 {
+			return 0, false
+		}
+		return prices[len(prices)-1].ClosePrice/sma - 1, true
+	}),
+
+	riskField("volatility_1y", "Annualized volatility of daily returns over the last year", func(m *risk.Metrics) Value { return pointer(m.Volatility) }),
+	riskField("downside_deviation_1y", "Annualized downside deviation over the last year", func(m *risk.Metrics) Value { return pointer(m.DownsideDeviation) }),
+	riskField("max_drawdown_1y", "Largest peak-to-trough decline over the last year (0-1)", func(m *risk.Metrics) Value { return Number(m.MaxDrawdown) }),
+	riskField("beta_1y", "Beta of daily returns against the benchmark over the last year", func(m *risk.Metrics) Value { return pointer(m.Beta) }),
+	riskField("sharpe_1y", "Sharpe ratio over the last year", func(m *risk.Metrics) Value { return pointer(m.Sharpe) }),
+	riskField("sortino_1y", "Sortino ratio over the last year", func(m *risk.Metrics) Value { return pointer(m.Sortino) }),
+
+	dividendField("dividend_yield", "Dividends paid over the last twelve months to the latest close", func(m *dividends.Metrics) Value { return pointer(m.TrailingYield) }),
+	dividendField("forward_dividend_yield", "Latest dividend annualized, to the latest close", func(m *dividends.Metrics) Value { return pointer(m.ForwardYield) }),
+	dividendField("payout_ratio", "Dividends over the latest statement's year to its EPS", func(m *dividends.Metrics) Value { return pointer(m.PayoutRatio) }),
+	dividendField("fcf_payout_ratio", "Dividends paid over the latest statement's year to its free cash flow", func(m *dividends.Metrics) Value { return pointer(m.FCFPayoutRatio) }),
+	dividendField("dividend_cagr_3y", "Growth rate of calendar-year dividends over three years", func(m *dividends.Metrics) Value { return pointer(m.CAGR3) }),
+	dividendField("dividend_cagr_5y", "Growth rate of calendar-year dividends over five years", func(m *dividends.Metrics) Value { return pointer(m.CAGR5) }),
+	dividendField("dividend_cagr_10y", "Growth rate of calendar-year dividends over ten years", func(m *dividends.Metrics) Value { return pointer(m.CAGR10) }),
+	dividendField("dividend_growth_years", "Consecutive years of dividend increases", func(m *dividends.Metrics) Value { return Number(float64(m.GrowthYears)) }),
+	dividendField("dividend_years_paid", "Consecutive years with a dividend", func(m *dividends.Metrics) Value { return Number(float64(m.YearsPaid)) }),
+}
+
+var catalogByName = func() map[string]*Field {
+	m := make(map[string]*Field, len(catalog))
+	for i := range catalog {
+		catalog[i].TypeName = catalog[i].Type.String()
+		m[catalog[i].Name] = &catalog[i]
+	}
+	return m
+}()
+
+// Lookup returns the catalog field of a metric name
+func Lookup(name string) (Field, bool) {
+	f, ok := catalogByName[name]
+	if !ok {
+		return Field{}, false
+	}
+	return *f, true
+}
+
+// Catalog lists every metric, ordered by category and name
+func Catalog() []Field {
+	fields := append([]Field(nil), catalog...)
+	sort.SliceStable(fields, func(i, j int) bool {
+		if fields[i].Category != fields[j].Category {
+			return fields[i].Category < fields[j].Category
+		}
+		return fields[i].Name < fields[j].Name
+	})
+	return fields
+}
+
+// NewRecord computes every catalog metric of a stock from its scoring inputs and score
+func NewRecord(in undervaluation.Inputs, score *undervaluation.UndervaluationScore) Record {
+	s := &source{in: in, score: score}
+	if len(in.FinancialStatements) > 0 {
+		r := ratios.Compute(in.FinancialStatements[0], in.LatestPrice)
+		s.ratios = &r
+	}
+	if s.score != nil && s.score.Risk != nil {
+		s.risk = s.score.Risk
+	} else if m, ok := risk.Compute(in.Prices, in.Benchmark, 0); ok {
+		s.risk = &m
+	}
+	record := make(Record, len(catalog))
+	for _, f := range catalog {
+		record[f.Name] = f.value(s)
+	}
+	return record
+}
+
+func stockField(name, column, description string, get func(*models.Stock) string) Field {
+	return Field{Name: name, Type: TypeString, Category: CategoryProfile, Description: description, Column: column,
+		value: func(s *source) Value {
+			v := get(s.in.Stock)
+			if v == "" {
+				return Null(TypeString)
+			}
+			return String(v)
+		}}
+}
+
+func statementField(name, description string, get func(models.FinancialStatement) float64) Field {
+	return Field{Name: name, Type: TypeNumber, Category: CategoryFundamentals, Description: description,
+		value: func(s *source) Value {
+			if len(s.in.FinancialStatements) == 0 {
+				return Null(TypeNumber)
+			}
+			return nonZero(get(s.in.FinancialStatements[0]))
+		}}
+}
+
+// ratioField reads a ratio at the latest price; multiples are only meaningful when positive
+func ratioField(name, description string, positiveOnly bool, get func(*ratios.Ratios) float64) Field {
+	return Field{Name: name, Type: TypeNumber, Category: CategoryRatios, Description: description,
+		value: func(s *source) Value {
+			if s.ratios == nil {
+				return Null(TypeNumber)
+			}
+			if positiveOnly {
+				return positive(get(s.ratios))
+			}
+			return nonZero(get(s.ratios))
+		}}
+}
+
+func scoreField(name, description string, get func(*undervaluation.UndervaluationScore) float64) Field {
+	return Field{Name: name, Type: TypeNumber, Category: CategoryScores, Description: description,
+		value: func(s *source) Value {
+			if s.score == nil {
+				return Null(TypeNumber)
+			}
+			return Number(get(s.score))
+		}}
+}
+
+// returnField is the price return since the last close on or before the lookback date
+func returnField(name, description string, years, months int) Field {
+	return Field{Name: name, Type: TypeNumber, Category: CategoryTechnicals, Description: description,
+		value: func(s *source) Value {
+			if len(s.in.Prices) == 0 {
+				return Null(TypeNumber)
+			}
+			latest := s.in.Prices[len(s.in.Prices)-1].ClosePrice
+			since := s.in.LatestPriceTime.AddDate(-years, -months, 0)
+			// Allow a few days of slack, like the momentum overlay, for histories loaded
+			// exactly that far back
+			if s.in.Prices[0].Time.After(since.AddDate(0, 0, 7)) {
+				return Null(TypeNumber)
+			}
+			base := s.in.Prices[0].ClosePrice
+			for _, p := range s.in.Prices {
+				if p.Time.After(since) {
+					break
+				}
+				base = p.ClosePrice
+			}
+			if base <= 0 || latest <= 0 {
+				return Null(TypeNumber)
+			}
+			return Number(latest/base - 1)
+		}}
+}
+
+func riskField(name, description string, get func(*risk.Metrics) Value) Field {
+	return Field{Name: name, Type: TypeNumber, Category: CategoryRisk, Description: description,
+		value: func(s *source) Value {
+			if s.risk == nil {
+				return Null(TypeNumber)
+			}
+			return get(s.risk)
+		}}
+}
+
+// dividendField reads a dividend metric; stocks without a dividend history have none
+func dividendField(name, description string, get func(*dividends.Metrics) Value) Field {
+	return Field{Name: name, Type: TypeNumber, Category: CategoryDividends, Description: description,
+		value: func(s *source) Value {
+			if s.in.Dividends == nil {
+				return Null(TypeNumber)
+			}
+			return get(s.in.Dividends)
+		}}
+}
+
+// technicalField evaluates an indicator on the daily history up to the latest close
+func technicalField(name, description string, get func([]models.HistoricalPrice) (float64, bool)) Field {
+	return Field{Name: name, Type: TypeNumber, Category: CategoryTechnicals, Description: description,
+		value: func(s *source) Value {
+			if len(s.in.Prices) == 0 {
+				return Null(TypeNumber)
+			}
+			return optional(get(s.in.Prices))
+		}}
+}
+
+func optional(v float64, ok bool) Value {
+	if !ok {
+		return Null(TypeNumber)
+	}
+	return Number(v)
+}
+
+func pointer(v *float64) Value {
+	if v == nil {
+		return Null(TypeNumber)
+	}
+	return Number(*v)
+}
+
+func positive(v float64) Value {
+	if v <= 0 {
+		return Null(TypeNumber)
+	}
+	return Number(v)
+}
+
+func nonZero(v float64) Value {
+	if v == 0 {
+		return Null(TypeNumber)
+	}
+	return Number(v)
+}
@@ -0,0 +1,57 @@
+package screener
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"stockpick-backend/pkg/models"
+	"stockpick-backend/pkg/undervaluation"
+)
+
+// dailyPrices returns a close per calendar day from start to end, rising by one a day
+func dailyPrices(start, end time.Time) []models.HistoricalPrice {
+	var prices []models.HistoricalPrice
+	for d, close := start, 100.0; !d.After(end); d, close = d.AddDate(0, 0, 1), close+1 {
+		prices = append(prices, models.HistoricalPrice{Time: d, ClosePrice: close, HighPrice: close})
+	}
+	return prices
+}
+
+func TestReturnFields(t *testing.T) {
+	latest := time.Date(2024, time.June, 14, 0, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name  string
+		start time.Time
+		field string
+		base  time.Time // Day of the base close, zero when the return is missing
+	}{
+		// Histories are loaded from a year before now, which lands a little after the
+		// latest close's anniversary
+		{"one year loaded", latest.AddDate(-1, 0, 2), "return_1y", latest.AddDate(-1, 0, 2)},
+		{"extra history", latest.AddDate(-1, 0, -3), "return_1y", latest.AddDate(-1, 0, 0)},
+		{"too short", latest.AddDate(-1, 0, 10), "return_1y", time.Time{}},
+		{"one month", latest.AddDate(-1, 0, 0), "return_1m", latest.AddDate(0, -1, 0)},
+	}
+	for _, tt := range tests {
+		prices := dailyPrices(tt.start, latest)
+		in := undervaluation.Inputs{
+			Stock:           &models.Stock{Symbol: "TEST"},
+			LatestPrice:     prices[len(prices)-1].ClosePrice,
+			LatestPriceTime: latest,
+			Prices:          prices,
+		}
+		got := NewRecord(in, nil)[tt.field]
+		if tt.base.IsZero() {
+			if got.Valid {
+				t.Errorf("%s: %s = %v, want missing", tt.name, tt.field, got.Num)
+			}
+			continue
+		}
+		base := 100 + tt.base.Sub(tt.start).Hours()/24
+		want := in.LatestPrice/base - 1
+		if !got.Valid || math.Abs(got.Num-want) > 1e-9 {
+			t.Errorf("%s: %s = %+v, want %v", tt.name, tt.field, got, want)
+		}
+	}
+}
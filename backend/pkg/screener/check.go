@@ -0,0 +1,79 @@
+package screener
+
+// Check type-checks an expression against the catalog and returns its type
+func Check(e Expr) (Type, error) {
+	switch e := e.(type) {
+	case *Literal:
+		return e.Value.Type, nil
+
+	case *Ident:
+		f, ok := Lookup(e.Name)
+		if !ok {
+			return 0, errorf(e.pos, "unknown metric %q", e.Name)
+		}
+		return f.Type, nil
+
+	case *Unary:
+		x, err := Check(e.X)
+		if err != nil {
+			return 0, err
+		}
+		want := TypeNumber
+		if e.Op == "NOT" {
+			want = TypeBool
+		}
+		if x != want {
+			return 0, errorf(e.pos, "%s needs a %s operand, got %s", e.Op, want, x)
+		}
+		return want, nil
+
+	case *In:
+		x, err := Check(e.X)
+		if err != nil {
+			return 0, err
+		}
+		for _, item := range e.List {
+			t, err := Check(item)
+			if err != nil {
+				return 0, err
+			}
+			if t != x {
+				return 0, errorf(item.Pos(), "IN list item is %s, expected %s", t, x)
+			}
+		}
+		return TypeBool, nil
+
+	case *Binary:
+		l, err := Check(e.L)
+		if err != nil {
+			return 0, err
+		}
+		r, err := Check(e.R)
+		if err != nil {
+			return 0, err
+		}
+		switch e.Op {
+		case "AND", "OR":
+			if l != TypeBool || r != TypeBool {
+				return 0, errorf(e.pos, "%s needs bool operands, got %s and %s", e.Op, l, r)
+			}
+			return TypeBool, nil
+		case "=", "!=":
+			if l != r {
+				return 0, errorf(e.pos, "cannot compare %s with %s", l, r)
+			}
+			return TypeBool, nil
+		case "<", "<=", ">", ">=":
+			if l != TypeNumber || r != TypeNumber {
+				return 0, errorf(e.pos, "%s needs number operands, got %s and %s", e.Op, l, r)
+			}
+			return TypeBool, nil
+		default: // Arithmetic
+			if l != TypeNumber || r != TypeNumber {
+				return 0, errorf(e.pos, "%s needs number operands, got %s and %s", e.Op, l, r)
+			}
+			return TypeNumber, nil
+		}
+	}
+	return 0, errorf(e.Pos(), "unsupported expression")
+}
@@ -0,0 +1,175 @@
+package screener
+
+import (
+	"encoding/json"
+	"strings"
+)
+
+// Type is the type of a metric or expression
+type Type int
+
+const (
+	TypeNumber Type = iota
+	TypeString
+	TypeBool
+)
+
+func (t Type) String() string {
+	switch t {
+	case TypeNumber:
+		return "number"
+	case TypeString:
+		return "string"
+	case TypeBool:
+		return "bool"
+	}
+	return "unknown"
+}
+
+// Value is a typed metric value. An invalid value is missing data, which compares as
+// unknown like SQL NULL: a screen only matches stocks for which it is known to be true.
+type Value struct {
+	Type  Type
+	Valid bool
+	Num   float64
+	Str   string
+	Bool  bool
+}
+
+// Number returns a valid number value
+func Number(v float64) Value { return Value{Type: TypeNumber, Valid: true, Num: v} }
+
+// String returns a valid string value
+func String(v string) Value { return Value{Type: TypeString, Valid: true, Str: v} }
+
+// Bool returns a valid boolean value
+func Bool(v bool) Value { return Value{Type: TypeBool, Valid: true, Bool: v} }
+
+// Null returns a missing value of the type
+func Null(t Type) Value { return Value{Type: t} }
+
+// MarshalJSON encodes the plain value, or null when missing
+func (v Value) MarshalJSON() ([]byte, error) {
+	if !v.Valid {
+		return []byte("null"), nil
+	}
+	switch v.Type {
+	case TypeString:
+		return json.Marshal(v.Str)
+	case TypeBool:
+		return json.Marshal(v.Bool)
+	}
+	return json.Marshal(v.Num)
+}
+
+// Record holds the catalog metrics of one stock
+type Record map[string]Value
+
+// Eval evaluates a type-checked expression against a record
+func Eval(e Expr, r Record) Value {
+	switch e := e.(type) {
+	case *Literal:
+		return e.Value
+	case *Ident:
+		return r[e.Name]
+
+	case *Unary:
+		x := Eval(e.X, r)
+		if !x.Valid {
+			return x
+		}
+		if e.Op == "NOT" {
+			return Bool(!x.Bool)
+		}
+		return Number(-x.Num)
+
+	case *In:
+		x := Eval(e.X, r)
+		if !x.Valid {
+			return Null(TypeBool)
+		}
+		found, unknown := false, false
+		for _, item := range e.List {
+			v := Eval(item, r)
+			if !v.Valid {
+				unknown = true
+				continue
+			}
+			if equal(x, v) {
+				found = true
+				break
+			}
+		}
+		if !found && unknown {
+			return Null(TypeBool)
+		}
+		return Bool(found != e.Not)
+
+	case *Binary:
+		switch e.Op {
+		case "AND":
+			l, rv := Eval(e.L, r), Eval(e.R, r)
+			if (l.Valid && !l.Bool) || (rv.Valid && !rv.Bool) {
+				return Bool(false)
+			}
+			if !l.Valid || !rv.Valid {
+				return Null(TypeBool)
+			}
+			return Bool(true)
+		case "OR":
+			l, rv := Eval(e.L, r), Eval(e.R, r)
+			if (l.Valid && l.Bool) || (rv.Valid && rv.Bool) {
+				return Bool(true)
+			}
+			if !l.Valid || !rv.Valid {
+				return Null(TypeBool)
+			}
+			return Bool(false)
+		}
+
+		l, rv := Eval(e.L, r), Eval(e.R, r)
+		if !l.Valid || !rv.Valid {
+			if comparisonOps[e.Op] {
+				return Null(TypeBool)
+			}
+			return Null(TypeNumber)
+		}
+		switch e.Op {
+		case "+":
+			return Number(l.Num + rv.Num)
+		case "-":
+			return Number(l.Num - rv.Num)
+		case "*":
+			return Number(l.Num * rv.Num)
+		case "/":
+			if rv.Num == 0 {
+				return Null(TypeNumber)
+			}
+			return Number(l.Num / rv.Num)
+		case "=":
+			return Bool(equal(l, rv))
+		case "!=":
+			return Bool(!equal(l, rv))
+		case "<":
+			return Bool(l.Num < rv.Num)
+		case "<=":
+			return Bool(l.Num <= rv.Num)
+		case ">":
+			return Bool(l.Num > rv.Num)
+		case ">=":
+			return Bool(l.Num >= rv.Num)
+		}
+	}
+	return Value{}
+}
+
+// equal compares two valid values of the same type; strings compare case-insensitively
+func equal(a, b Value) bool {
+	switch a.Type {
+	case TypeString:
+		return strings.EqualFold(a.Str, b.Str)
+	case TypeBool:
+		return a.Bool == b.Bool
+	}
+	return a.Num == b.Num
+}
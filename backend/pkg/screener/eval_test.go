@@ -0,0 +1,78 @@
+package screener
+
+import (
+	"testing"
+)
+
+func TestEval(t *testing.T) {
+	record := Record{
+		"pe":        Number(12),
+		"roic":      Number(0.15),
+		"sector":    String("Technology"),
+		"is_active": Bool(true),
+		"peg":       Null(TypeNumber),
+		"industry":  Null(TypeString),
+	}
+	tests := []struct {
+		query string
+		want  Value
+	}{
+		{"pe < 15", Bool(true)},
+		{"pe < 15 AND roic > 20%", Bool(false)},
+		{"pe * 2 = 24", Bool(true)},
+		{"pe / 0 > 1", Null(TypeBool)},
+		{"sector = 'technology'", Bool(true)},
+		{"sector IN ('Energy', 'TECHNOLOGY')", Bool(true)},
+		{"sector NOT IN ('Energy')", Bool(true)},
+		{"NOT is_active", Bool(false)},
+
+		// Missing data is unknown, like SQL NULL
+		{"peg < 1", Null(TypeBool)},
+		{"NOT peg < 1", Null(TypeBool)},
+		{"peg < 1 AND pe > 20", Bool(false)},
+		{"peg < 1 AND pe < 20", Null(TypeBool)},
+		{"peg < 1 OR pe < 20", Bool(true)},
+		{"peg < 1 OR pe > 20", Null(TypeBool)},
+		{"industry IN ('Software')", Null(TypeBool)},
+		{"sector IN (industry, 'Technology')", Bool(true)},
+		{"sector IN (industry, 'Energy')", Null(TypeBool)},
+		{"-peg < 0", Null(TypeBool)},
+	}
+	for _, tt := range tests {
+		e, err := Parse(tt.query)
+		if err != nil {
+			t.Fatalf("Parse(%q): %v", tt.query, err)
+		}
+		if got := Eval(e, record); got != tt.want {
+			t.Errorf("Eval(%q) = %+v, want %+v", tt.query, got, tt.want)
+		}
+	}
+}
+
+func TestMatchAndRank(t *testing.T) {
+	c, err := Compile(Screen{Query: "pe < 20 OR peg < 1", SortBy: "pe", Order: "asc", Limit: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	records := []Record{
+		{"symbol": String("A"), "pe": Number(18), "peg": Null(TypeNumber)},
+		{"symbol": String("B"), "pe": Null(TypeNumber), "peg": Number(0.5)},
+		{"symbol": String("C"), "pe": Number(25), "peg": Null(TypeNumber)},
+		{"symbol": String("D"), "pe": Number(9), "peg": Number(2)},
+	}
+	var matches []Record
+	for _, r := range records {
+		if c.Match(r) {
+			matches = append(matches, r)
+		}
+	}
+	if len(matches) != 3 {
+		t.Fatalf("matched %d records, want 3", len(matches))
+	}
+
+	// Missing sort values go last, whatever the order
+	ranked := c.Rank(matches)
+	if len(ranked) != 2 || ranked[0]["symbol"].Str != "D" || ranked[1]["symbol"].Str != "A" {
+		t.Errorf("ranked %v", ranked)
+	}
+}
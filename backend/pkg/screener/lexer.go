@@ -0,0 +1,167 @@
+package screener
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// tokenKind classifies a lexical token
+type tokenKind int
+
+const (
+	tokEOF tokenKind = iota
+	tokIdent
+	tokNumber
+	tokString
+	tokOp // Comparison and arithmetic operators
+	tokLParen
+	tokRParen
+	tokComma
+	tokAnd
+	tokOr
+	tokNot
+	tokIn
+	tokTrue
+	tokFalse
+)
+
+// token is a lexical token with its byte offset in the query
+type token struct {
+	kind tokenKind
+	text string
+	num  float64
+	pos  int
+}
+
+var keywords = map[string]tokenKind{
+	"AND":   tokAnd,
+	"OR":    tokOr,
+	"NOT":   tokNot,
+	"IN":    tokIn,
+	"TRUE":  tokTrue,
+	"FALSE": tokFalse,
+}
+
+// Error is a syntax or type error at a byte offset of the query
+type Error struct {
+	Pos int
+	Msg string
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("at position %d: %s", e.Pos, e.Msg)
+}
+
+func errorf(pos int, format string, args ...interface{}) *Error {
+	return &Error{Pos: pos, Msg: fmt.Sprintf(format, args...)}
+}
+
+// lex splits a query into tokens, ending with tokEOF
+func lex(src string) ([]token, error) {
+	var tokens []token
+	i := 0
+	for i < len(src) {
+		c := src[i]
+		switch {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r':
+			i++
+
+		case isIdentStart(c):
+			start := i
+			for i < len(src) && isIdentPart(src[i]) {
+				i++
+			}
+			word := src[start:i]
+			if kind, ok := keywords[strings.ToUpper(word)]; ok {
+				tokens = append(tokens, token{kind: kind, text: word, pos: start})
+			} else {
+				tokens = append(tokens, token{kind: tokIdent, text: strings.ToLower(word), pos: start})
+			}
+
+		case unicode.IsDigit(rune(c)) || (c == '.' && i+1 < len(src) && unicode.IsDigit(rune(src[i+1]))):
+			start := i
+			for i < len(src) && (unicode.IsDigit(rune(src[i])) || src[i] == '.') {
+				i++
+			}
+			if i < len(src) && (src[i] == 'e' || src[i] == 'E') {
+				i++
+				if i < len(src) && (src[i] == '+' || src[i] == '-') {
+					i++
+				}
+				for i < len(src) && unicode.IsDigit(rune(src[i])) {
+					i++
+				}
+			}
+			num, err := strconv.ParseFloat(src[start:i], 64)
+			if err != nil {
+				return nil, errorf(start, "invalid number %q", src[start:i])
+			}
+			// A trailing percent sign scales the number, so 20% is 0.2
+			if i < len(src) && src[i] == '%' {
+				num /= 100
+				i++
+			}
+			tokens = append(tokens, token{kind: tokNumber, text: src[start:i], num: num, pos: start})
+
+		case c == '"' || c == '\'':
+			start := i
+			var sb strings.Builder
+			i++
+			for i < len(src) && src[i] != c {
+				if src[i] == '\\' && i+1 < len(src) {
+					i++
+				}
+				sb.WriteByte(src[i])
+				i++
+			}
+			if i >= len(src) {
+				return nil, errorf(start, "unterminated string")
+			}
+			i++
+			tokens = append(tokens, token{kind: tokString, text: sb.String(), pos: start})
+
+		case c == '(':
+			tokens = append(tokens, token{kind: tokLParen, text: "(", pos: i})
+			i++
+		case c == ')':
+			tokens = append(tokens, token{kind: tokRParen, text: ")", pos: i})
+			i++
+		case c == ',':
+			tokens = append(tokens, token{kind: tokComma, text: ",", pos: i})
+			i++
+
+		default:
+			op := ""
+			for _, candidate := range []string{"<=", ">=", "!=", "<>", "==", "=", "<", ">", "+", "-", "*", "/"} {
+				if strings.HasPrefix(src[i:], candidate) {
+					op = candidate
+					break
+				}
+			}
+			if op == "" {
+				return nil, errorf(i, "unexpected character %q", c)
+			}
+			// Normalize the equality spellings
+			text := op
+			switch op {
+			case "==":
+				text = "="
+			case "<>":
+				text = "!="
+			}
+			tokens = append(tokens, token{kind: tokOp, text: text, pos: i})
+			i += len(op)
+		}
+	}
+	return append(tokens, token{kind: tokEOF, pos: len(src)}), nil
+}
+
+func isIdentStart(c byte) bool {
+	return c == '_' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z')
+}
+
+func isIdentPart(c byte) bool {
+	return isIdentStart(c) || (c >= '0' && c <= '9')
+}
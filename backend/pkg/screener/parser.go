@@ -0,0 +1,255 @@
+package screener
+
+// Expr is a node of a parsed screen expression
+type Expr interface {
+	Pos() int
+}
+
+// Ident references a metric of the catalog
+type Ident struct {
+	Name string
+	pos  int
+}
+
+// Literal is a number, string or boolean constant
+type Literal struct {
+	Value Value
+	pos   int
+}
+
+// Unary is negation ("-") or logical NOT ("NOT")
+type Unary struct {
+	Op  string
+	X   Expr
+	pos int
+}
+
+// Binary is an arithmetic, comparison or logical ("AND", "OR") operation
+type Binary struct {
+	Op   string
+	L, R Expr
+	pos  int
+}
+
+// In tests membership of X in a list of values
+type In struct {
+	X    Expr
+	List []Expr
+	Not  bool
+	pos  int
+}
+
+func (e *Ident) Pos() int   { return e.pos }
+func (e *Literal) Pos() int { return e.pos }
+func (e *Unary) Pos() int   { return e.pos }
+func (e *Binary) Pos() int  { return e.pos }
+func (e *In) Pos() int      { return e.pos }
+
+// Parse parses a screen expression. The grammar, loosest binding first:
+//
+//	expr    = and { OR and }
+//	and     = not { AND not }
+//	not     = NOT not | compare
+//	compare = sum [ ( "=" | "!=" | "<" | "<=" | ">" | ">=" ) sum | [ NOT ] IN "(" sum { "," sum } ")" ]
+//	sum     = term { ( "+" | "-" ) term }
+//	term    = unary { ( "*" | "/" ) unary }
+//	unary   = "-" unary | primary
+//	primary = number | string | TRUE | FALSE | metric | "(" expr ")"
+func Parse(src string) (Expr, error) {
+	tokens, err := lex(src)
+	if err != nil {
+		return nil, err
+	}
+	p := &parser{tokens: tokens}
+	if p.peek().kind == tokEOF {
+		return nil, errorf(0, "empty expression")
+	}
+	e, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if t := p.peek(); t.kind != tokEOF {
+		return nil, errorf(t.pos, "unexpected %q", t.text)
+	}
+	return e, nil
+}
+
+type parser struct {
+	tokens []token
+	i      int
+}
+
+func (p *parser) peek() token {
+	return p.tokens[p.i]
+}
+
+func (p *parser) next() token {
+	t := p.tokens[p.i]
+	if t.kind != tokEOF {
+		p.i++
+	}
+	return t
+}
+
+func (p *parser) parseOr() (Expr, error) {
+	left, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	for p.peek().kind == tokOr {
+		t := p.next()
+		right, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		left = &Binary{Op: "OR", L: left, R: right, pos: t.pos}
+	}
+	return left, nil
+}
+
+func (p *parser) parseAnd() (Expr, error) {
+	left, err := p.parseNot()
+	if err != nil {
+		return nil, err
+	}
+	for p.peek().kind == tokAnd {
+		t := p.next()
+		right, err := p.parseNot()
+		if err != nil {
+			return nil, err
+		}
+		left = &Binary{Op: "AND", L: left, R: right, pos: t.pos}
+	}
+	return left, nil
+}
+
+func (p *parser) parseNot() (Expr, error) {
+	if p.peek().kind == tokNot {
+		t := p.next()
+		x, err := p.parseNot()
+		if err != nil {
+			return nil, err
+		}
+		return &Unary{Op: "NOT", X: x, pos: t.pos}, nil
+	}
+	return p.parseCompare()
+}
+
+var comparisonOps = map[string]bool{"=": true, "!=": true, "<": true, "<=": true, ">": true, ">=": true}
+
+func (p *parser) parseCompare() (Expr, error) {
+	left, err := p.parseSum()
+	if err != nil {
+		return nil, err
+	}
+
+	t := p.peek()
+	switch {
+	case t.kind == tokOp && comparisonOps[t.text]:
+		p.next()
+		right, err := p.parseSum()
+		if err != nil {
+			return nil, err
+		}
+		return &Binary{Op: t.text, L: left, R: right, pos: t.pos}, nil
+
+	case t.kind == tokIn || (t.kind == tokNot && p.tokens[p.i+1].kind == tokIn):
+		in := &In{X: left, pos: t.pos}
+		if t.kind == tokNot {
+			p.next()
+			in.Not = true
+		}
+		p.next()
+		if lp := p.next(); lp.kind != tokLParen {
+			return nil, errorf(lp.pos, "expected ( after IN")
+		}
+		for {
+			item, err := p.parseSum()
+			if err != nil {
+				return nil, err
+			}
+			in.List = append(in.List, item)
+			sep := p.next()
+			if sep.kind == tokRParen {
+				break
+			}
+			if sep.kind != tokComma {
+				return nil, errorf(sep.pos, "expected , or ) in IN list")
+			}
+		}
+		return in, nil
+	}
+	return left, nil
+}
+
+func (p *parser) parseSum() (Expr, error) {
+	left, err := p.parseTerm()
+	if err != nil {
+		return nil, err
+	}
+	for t := p.peek(); t.kind == tokOp && (t.text == "+" || t.text == "-"); t = p.peek() {
+		p.next()
+		right, err := p.parseTerm()
+		if err != nil {
+			return nil, err
+		}
+		left = &Binary{Op: t.text, L: left, R: right, pos: t.pos}
+	}
+	return left, nil
+}
+
+func (p *parser) parseTerm() (Expr, error) {
+	left, err := p.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+	for t := p.peek(); t.kind == tokOp && (t.text == "*" || t.text == "/"); t = p.peek() {
+		p.next()
+		right, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		left = &Binary{Op: t.text, L: left, R: right, pos: t.pos}
+	}
+	return left, nil
+}
+
+func (p *parser) parseUnary() (Expr, error) {
+	if t := p.peek(); t.kind == tokOp && t.text == "-" {
+		p.next()
+		x, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		return &Unary{Op: "-", X: x, pos: t.pos}, nil
+	}
+	return p.parsePrimary()
+}
+
+func (p *parser) parsePrimary() (Expr, error) {
+	t := p.next()
+	switch t.kind {
+	case tokNumber:
+		return &Literal{Value: Number(t.num), pos: t.pos}, nil
+	case tokString:
+		return &Literal{Value: String(t.text), pos: t.pos}, nil
+	case tokTrue:
+		return &Literal{Value: Bool(true), pos: t.pos}, nil
+	case tokFalse:
+		return &Literal{Value: Bool(false), pos: t.pos}, nil
+	case tokIdent:
+		return &Ident{Name: t.text, pos: t.pos}, nil
+	case tokLParen:
+		e, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if rp := p.next(); rp.kind != tokRParen {
+			return nil, errorf(rp.pos, "expected )")
+		}
+		return e, nil
+	case tokEOF:
+		return nil, errorf(t.pos, "unexpected end of expression")
+	}
+	return nil, errorf(t.pos, "unexpected %q", t.text)
+}
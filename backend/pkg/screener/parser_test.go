@@ -0,0 +1,147 @@
+package screener
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+// render prints an expression fully parenthesized, to make precedence visible
+func render(e Expr) string {
+	switch e := e.(type) {
+	case *Ident:
+		return e.Name
+	case *Literal:
+		switch e.Value.Type {
+		case TypeString:
+			return fmt.Sprintf("%q", e.Value.Str)
+		case TypeBool:
+			return fmt.Sprint(e.Value.Bool)
+		}
+		return fmt.Sprint(e.Value.Num)
+	case *Unary:
+		return "(" + e.Op + " " + render(e.X) + ")"
+	case *Binary:
+		return "(" + render(e.L) + " " + e.Op + " " + render(e.R) + ")"
+	case *In:
+		items := make([]string, len(e.List))
+		for i, item := range e.List {
+			items[i] = render(item)
+		}
+		op := " IN "
+		if e.Not {
+			op = " NOT IN "
+		}
+		return "(" + render(e.X) + op + "[" + strings.Join(items, ", ") + "])"
+	}
+	return "?"
+}
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		query string
+		want  string
+	}{
+		{"pe < 15", "(pe < 15)"},
+		{"PE < 15 and ROIC > 12%", "((pe < 15) AND (roic > 0.12))"},
+		{"a OR b AND c", "(a OR (b AND c))"},
+		{"NOT a AND b", "((NOT a) AND b)"},
+		{"NOT NOT a", "(NOT (NOT a))"},
+		{"1 + 2 * 3 - 4", "((1 + (2 * 3)) - 4)"},
+		{"-pe / 2", "((- pe) / 2)"},
+		{"(a OR b) AND c", "((a OR b) AND c)"},
+		{"pe == 1 OR pe <> 2", "((pe = 1) OR (pe != 2))"},
+		{"sector IN ('Technology', \"Energy\")", `(sector IN ["Technology", "Energy"])`},
+		{"sector NOT IN ('Utilities')", `(sector NOT IN ["Utilities"])`},
+		{"is_active = TRUE", "(is_active = true)"},
+		{"market_cap > 1e9", "(market_cap > 1e+09)"},
+		{"name = 'it\\'s'", `(name = "it's")`},
+	}
+	for _, tt := range tests {
+		e, err := Parse(tt.query)
+		if err != nil {
+			t.Errorf("Parse(%q): %v", tt.query, err)
+			continue
+		}
+		if got := render(e); got != tt.want {
+			t.Errorf("Parse(%q) = %s, want %s", tt.query, got, tt.want)
+		}
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	tests := []struct {
+		query string
+		pos   int
+	}{
+		{"", 0},
+		{"   ", 0},
+		{"pe <", 4},
+		{"pe < 15 roic", 8},
+		{"(pe < 15", 8},
+		{"sector IN 'Energy'", 10},
+		{"sector IN ('Energy' 'Utilities')", 20},
+		{"pe # 3", 3},
+		{"name = 'open", 7},
+		{"1.2.3 > 0", 0},
+	}
+	for _, tt := range tests {
+		_, err := Parse(tt.query)
+		var perr *Error
+		if !errors.As(err, &perr) {
+			t.Errorf("Parse(%q) error = %v, want a positioned error", tt.query, err)
+			continue
+		}
+		if perr.Pos != tt.pos {
+			t.Errorf("Parse(%q) error at %d, want %d (%v)", tt.query, perr.Pos, tt.pos, err)
+		}
+	}
+}
+
+func TestCompileTypeErrors(t *testing.T) {
+	tests := []struct {
+		query string
+		want  string
+	}{
+		{"pe", "must be a condition"},
+		{"unknown_metric > 1", "unknown metric"},
+		{"sector > 1", "needs number operands"},
+		{"sector = 1", "cannot compare"},
+		{"pe AND roic", "needs bool operands"},
+		{"NOT pe", "needs a bool operand"},
+		{"-sector = 'x'", "needs a number operand"},
+		{"sector IN ('Energy', 1)", "IN list item is number"},
+	}
+	for _, tt := range tests {
+		_, err := Compile(Screen{Query: tt.query})
+		if err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("Compile(%q) error = %v, want %q", tt.query, err, tt.want)
+		}
+	}
+}
+
+func TestCompileDefaults(t *testing.T) {
+	c, err := Compile(Screen{Query: "pe < 15", SortBy: "PE", Order: "ASC", Limit: MaxLimit + 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.SortBy != "pe" || c.Order != OrderAsc || c.Limit != MaxLimit {
+		t.Errorf("got sort %q %q limit %d", c.SortBy, c.Order, c.Limit)
+	}
+
+	c, err = Compile(Screen{Query: "pe < 15"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.SortBy != "score" || c.Order != OrderDesc || c.Limit != DefaultLimit {
+		t.Errorf("got defaults %q %q %d", c.SortBy, c.Order, c.Limit)
+	}
+
+	if _, err := Compile(Screen{Query: "pe < 15", SortBy: "nope"}); err == nil {
+		t.Error("unknown sort metric accepted")
+	}
+	if _, err := Compile(Screen{Query: "pe < 15", Order: "up"}); err == nil {
+		t.Error("invalid order accepted")
+	}
+}
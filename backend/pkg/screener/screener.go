@@ -0,0 +1,109 @@
+package screener
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Result limits of a screen
+const (
+	DefaultLimit = 50
+	MaxLimit     = 500
+)
+
+// Sort orders
+const (
+	OrderAsc  = "asc"
+	OrderDesc = "desc"
+)
+
+// Screen is a screen expression with its result ordering
+type Screen struct {
+	Query  string `json:"query"`
+	SortBy string `json:"sort_by,omitempty"` // Metric to sort by, defaults to score
+	Order  string `json:"order,omitempty"`   // "asc" or "desc", defaults to desc
+	Limit  int    `json:"limit,omitempty"`   // Defaults to DefaultLimit, at most MaxLimit
+}
+
+// Compiled is a parsed and type-checked screen
+type Compiled struct {
+	Screen
+	expr Expr
+}
+
+// Compile parses and type-checks a screen, filling in the ordering defaults
+func Compile(s Screen) (*Compiled, error) {
+	expr, err := Parse(s.Query)
+	if err != nil {
+		return nil, err
+	}
+	t, err := Check(expr)
+	if err != nil {
+		return nil, err
+	}
+	if t != TypeBool {
+		return nil, errorf(0, "screen must be a condition, got a %s expression", t)
+	}
+
+	if s.SortBy == "" {
+		s.SortBy = "score"
+	}
+	s.SortBy = strings.ToLower(s.SortBy)
+	if _, ok := Lookup(s.SortBy); !ok {
+		return nil, fmt.Errorf("unknown sort metric %q", s.SortBy)
+	}
+	s.Order = strings.ToLower(s.Order)
+	if s.Order == "" {
+		s.Order = OrderDesc
+	}
+	if s.Order != OrderAsc && s.Order != OrderDesc {
+		return nil, fmt.Errorf("order must be asc or desc")
+	}
+	if s.Limit <= 0 {
+		s.Limit = DefaultLimit
+	}
+	if s.Limit > MaxLimit {
+		s.Limit = MaxLimit
+	}
+	return &Compiled{Screen: s, expr: expr}, nil
+}
+
+// Match reports whether the screen is known to be true for the record
+func (c *Compiled) Match(r Record) bool {
+	v := Eval(c.expr, r)
+	return v.Valid && v.Bool
+}
+
+// SQLFilter returns the SQL condition narrowing the stocks table, see SQLFilter
+func (c *Compiled) SQLFilter() (string, []interface{}, bool) {
+	return SQLFilter(c.expr)
+}
+
+// Rank sorts matching records by the sort metric, missing values last, and applies the limit
+func (c *Compiled) Rank(records []Record) []Record {
+	sort.SliceStable(records, func(i, j int) bool {
+		a, b := records[i][c.SortBy], records[j][c.SortBy]
+		if !a.Valid || !b.Valid {
+			return a.Valid
+		}
+		if c.Order == OrderAsc {
+			return less(a, b)
+		}
+		return less(b, a)
+	})
+	if len(records) > c.Limit {
+		records = records[:c.Limit]
+	}
+	return records
+}
+
+func less(a, b Value) bool {
+	switch a.Type {
+	case TypeString:
+		return strings.ToLower(a.Str) < strings.ToLower(b.Str)
+	case TypeBool:
+		return !a.Bool && b.Bool
+	}
+	return a.Num < b.Num
+}
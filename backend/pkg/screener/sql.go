@@ -0,0 +1,112 @@
+package screener
+
+import (
+	"fmt"
+	"strings"
+)
+
+// SQLFilter translates the top-level AND terms of an expression that only reference
+// stored stock columns into a SQL condition with positional arguments, so the universe
+// can be narrowed in the database before the remaining metrics are computed. The full
+// expression must still be evaluated on the narrowed universe. It returns false when no
+// term can be translated.
+func SQLFilter(e Expr) (string, []interface{}, bool) {
+	var conds []string
+	var args []interface{}
+	for _, term := range conjuncts(e) {
+		w := &sqlWriter{args: args}
+		if cond, ok := w.write(term); ok {
+			conds = append(conds, cond)
+			args = w.args
+		}
+	}
+	if len(conds) == 0 {
+		return "", nil, false
+	}
+	return strings.Join(conds, " AND "), args, true
+}
+
+// conjuncts splits an expression on its top-level ANDs
+func conjuncts(e Expr) []Expr {
+	if b, ok := e.(*Binary); ok && b.Op == "AND" {
+		return append(conjuncts(b.L), conjuncts(b.R)...)
+	}
+	return []Expr{e}
+}
+
+type sqlWriter struct {
+	args []interface{}
+}
+
+func (w *sqlWriter) arg(v interface{}) string {
+	w.args = append(w.args, v)
+	return fmt.Sprintf("$%d", len(w.args))
+}
+
+// write renders an expression as SQL, failing on metrics without a column
+func (w *sqlWriter) write(e Expr) (string, bool) {
+	switch e := e.(type) {
+	case *Literal:
+		switch e.Value.Type {
+		case TypeString:
+			return "LOWER(" + w.arg(e.Value.Str) + ")", true
+		case TypeBool:
+			return w.arg(e.Value.Bool), true
+		}
+		return w.arg(e.Value.Num), true
+
+	case *Ident:
+		f, ok := Lookup(e.Name)
+		if !ok || f.Column == "" {
+			return "", false
+		}
+		if f.Type == TypeString {
+			return "LOWER(" + f.Column + ")", true
+		}
+		return f.Column, true
+
+	case *Unary:
+		x, ok := w.write(e.X)
+		if !ok {
+			return "", false
+		}
+		if e.Op == "NOT" {
+			return "(NOT " + x + ")", true
+		}
+		return "(-" + x + ")", true
+
+	case *In:
+		x, ok := w.write(e.X)
+		if !ok {
+			return "", false
+		}
+		items := make([]string, len(e.List))
+		for i, item := range e.List {
+			if items[i], ok = w.write(item); !ok {
+				return "", false
+			}
+		}
+		op := " IN "
+		if e.Not {
+			op = " NOT IN "
+		}
+		return "(" + x + op + "(" + strings.Join(items, ", ") + "))", true
+
+	case *Binary:
+		l, ok := w.write(e.L)
+		if !ok {
+			return "", false
+		}
+		r, ok := w.write(e.R)
+		if !ok {
+			return "", false
+		}
+		op := e.Op
+		if op == "/" {
+			// Division by zero is missing data, as in Eval
+			r = "NULLIF(" + r + ", 0)"
+		}
+		return "(" + l + " " + op + " " + r + ")", true
+	}
+	return "", false
+}
@@ -0,0 +1,49 @@
+package screener
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestSQLFilter(t *testing.T) {
+	tests := []struct {
+		query string
+		cond  string
+		args  []interface{}
+		ok    bool
+	}{
+		{"sector = 'Technology'", "(LOWER(sector) = LOWER($1))", []interface{}{"Technology"}, true},
+		{"pe < 15", "", nil, false},
+		{
+			"sector IN ('Energy', 'Utilities') AND pe < 15 AND is_active = TRUE",
+			"(LOWER(sector) IN (LOWER($1), LOWER($2))) AND (is_active = $3)",
+			[]interface{}{"Energy", "Utilities", true}, true,
+		},
+		// OR over a computed metric cannot narrow the universe
+		{"sector = 'Energy' OR pe < 15", "", nil, false},
+		{"NOT exchange = 'NYSE' AND pe < 15", "(NOT (LOWER(exchange) = LOWER($1)))", []interface{}{"NYSE"}, true},
+		{"industry NOT IN ('Banks')", "(LOWER(industry) NOT IN (LOWER($1)))", []interface{}{"Banks"}, true},
+	}
+	for _, tt := range tests {
+		c, err := Compile(Screen{Query: tt.query})
+		if err != nil {
+			t.Fatalf("Compile(%q): %v", tt.query, err)
+		}
+		cond, args, ok := c.SQLFilter()
+		if ok != tt.ok || cond != tt.cond || !reflect.DeepEqual(args, tt.args) {
+			t.Errorf("SQLFilter(%q) = %q %v %v, want %q %v %v", tt.query, cond, args, ok, tt.cond, tt.args, tt.ok)
+		}
+	}
+}
+
+func TestSQLFilterArithmetic(t *testing.T) {
+	e, err := Parse("1 / 0 > -2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	cond, args, ok := SQLFilter(e)
+	want := "(($1 / NULLIF($2, 0)) > (-$3))"
+	if !ok || cond != want || !reflect.DeepEqual(args, []interface{}{1.0, 0.0, 2.0}) {
+		t.Errorf("got %q %v %v, want %q", cond, args, ok, want)
+	}
+}
@@ -0,0 +1,177 @@
+package sentiment
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"stockpick-backend/pkg/models"
+)
+
+// Config tunes the sentiment aggregation
+type Config struct {
+	// HalfLife is the age at which an observation's weight halves
+	HalfLife time.Duration
+	// SourceWeights weights each source's decayed score. When any configured source has
+	// observations, unconfigured sources (such as the pre-aggregated "Overall") are ignored;
+	// otherwise all sources count equally.
+	SourceWeights map[string]float64
+	// MomentumWindow splits observations into recent and earlier ones for momentum
+	MomentumWindow time.Duration
+	// AbnormalVolume is the RelativeIndex (discussion volume relative to the previous day)
+	// above which discussion counts as abnormal
+	AbnormalVolume float64
+}
+
+// DefaultConfig returns the default aggregation settings
+func DefaultConfig() Config {
+	return Config{
+		HalfLife: 48 * time.Hour,
+		SourceWeights: map[string]float64{
+			"Reddit":     0.4,
+			"Twitter":    0.35,
+			"StockTwits": 0.25,
+		},
+		MomentumWindow: 72 * time.Hour,
+		AbnormalVolume: 2.0,
+	}
+}
+
+// SourceSummary is the aggregate of a single source
+type SourceSummary struct {
+	Score          float64 `json:"score"`
+	Weight         float64 `json:"weight"` // Share of the combined score
+	Observations   int     `json:"observations"`
+	RelativeVolume float64 `json:"relative_volume"` // Latest RelativeIndex
+}
+
+// Summary is the time-decayed, multi-source sentiment of a stock
+type Summary struct {
+	Score          float64                  `json:"score"`    // 0-1, higher is more positive
+	Momentum       *float64                 `json:"momentum"` // Recent minus earlier decayed score, nil without both
+	RelativeVolume float64                  `json:"relative_volume"`
+	AbnormalVolume bool                     `json:"abnormal_volume"`
+	Observations   int                      `json:"observations"`
+	Latest         time.Time                `json:"latest"`
+	Sources        map[string]SourceSummary `json:"sources"`
+}
+
+// Aggregate combines sentiment observations as of the given time. Observations after
+// asOf are ignored. It returns false when no observation qualifies.
+func Aggregate(scores []models.SentimentScore, asOf time.Time, cfg Config) (Summary, bool) {
+	bySource := make(map[string][]models.SentimentScore)
+	for _, s := range scores {
+		if s.Timestamp.After(asOf) {
+			continue
+		}
+		bySource[s.Source] = append(bySource[s.Source], s)
+	}
+
+	weights := make(map[string]float64)
+	for source := range bySource {
+		if w, ok := cfg.SourceWeights[source]; ok && w > 0 {
+			weights[source] = w
+		}
+	}
+	if len(weights) == 0 {
+		for source := range bySource {
+			weights[source] = 1
+		}
+	}
+	totalWeight := 0.0
+	for _, w := range weights {
+		totalWeight += w
+	}
+	if totalWeight == 0 {
+		return Summary{}, false
+	}
+
+	summary := Summary{Sources: make(map[string]SourceSummary)}
+	var recentSum, recentWeight, earlierSum, earlierWeight float64
+	for source, w := range weights {
+		observations := bySource[source]
+		share := w / totalWeight
+
+		var sum, decayed float64
+		var latest models.SentimentScore
+		for _, o := range observations {
+			age := asOf.Sub(o.Timestamp)
+			d := decay(age, cfg.HalfLife)
+			v := Normalize(o.SentimentScore)
+			sum += v * d
+			decayed += d
+			if o.Timestamp.After(latest.Timestamp) {
+				latest = o
+			}
+
+			// Both windows are decay-weighted means as of their own end, so that momentum
+			// compares like with like
+			if age <= cfg.MomentumWindow {
+				recentSum += v * d * share
+				recentWeight += d * share
+			} else {
+				earlier := decay(age-cfg.MomentumWindow, cfg.HalfLife)
+				earlierSum += v * earlier * share
+				earlierWeight += earlier * share
+			}
+		}
+
+		src := SourceSummary{
+			Weight:         share,
+			Observations:   len(observations),
+			RelativeVolume: latest.RelativeIndex,
+		}
+		if decayed > 0 {
+			src.Score = sum / decayed
+		}
+		summary.Sources[source] = src
+		summary.Score += src.Score * share
+		summary.RelativeVolume += latest.RelativeIndex * share
+		summary.Observations += len(observations)
+		if latest.Timestamp.After(summary.Latest) {
+			summary.Latest = latest.Timestamp
+		}
+	}
+
+	if recentWeight > 0 && earlierWeight > 0 {
+		momentum := recentSum/recentWeight - earlierSum/earlierWeight
+		summary.Momentum = &momentum
+	}
+	summary.AbnormalVolume = cfg.AbnormalVolume > 0 && summary.RelativeVolume > cfg.AbnormalVolume
+	return summary, true
+}
+
+// Normalize maps a sentiment score to 0-1. Scores above 1 are taken as percentages.
+func Normalize(score float64) float64 {
+	if score > 1 {
+		return score / 100.0
+	}
+	return score
+}
+
+// ParseSourceWeights parses "Reddit:0.5,Twitter:0.3" into source weights
+func ParseSourceWeights(raw string) (map[string]float64, error) {
+	weights := make(map[string]float64)
+	for _, part := range strings.Split(raw, ",") {
+		source, value, ok := strings.Cut(strings.TrimSpace(part), ":")
+		if !ok || source == "" {
+			return nil, fmt.Errorf("invalid source weight %q, expected source:weight", part)
+		}
+		w, err := strconv.ParseFloat(value, 64)
+		if err != nil || w < 0 {
+			return nil, fmt.Errorf("invalid weight for source %s: %q", source, value)
+		}
+		weights[source] = w
+	}
+	return weights, nil
+}
+
+// decay is the exponential weight of an observation of the given age
+func decay(age, halfLife time.Duration) float64 {
+	if halfLife <= 0 || age <= 0 {
+		return 1
+	}
+	return math.Pow(0.5, float64(age)/float64(halfLife))
+}
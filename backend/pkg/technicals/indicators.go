@@ -0,0 +1,124 @@
+package technicals
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"stockpick-backend/pkg/models"
+	"stockpick-backend/pkg/risk"
+)
+
+// Indicator is a parsed indicator name such as "sma_50", "rsi" or "macd_12_26_9". Omitted
+// parameters take the indicator's defaults.
+type Indicator struct {
+	Name   string    // Canonical name with every parameter, e.g. "rsi_14"
+	Kind   string    // One of Kinds
+	Params []float64 // Parameters in the order of the kind's definition
+}
+
+type kind struct {
+	params   []string  // Parameter names, for error messages
+	defaults []float64 // Default of every parameter
+	integer  []bool    // Whether each parameter is a bar count
+}
+
+var kinds = map[string]kind{
+	"sma":      {[]string{"period"}, []float64{20}, []bool{true}},
+	"ema":      {[]string{"period"}, []float64{20}, []bool{true}},
+	"rsi":      {[]string{"period"}, []float64{14}, []bool{true}},
+	"macd":     {[]string{"fast", "slow", "signal"}, []float64{12, 26, 9}, []bool{true, true, true}},
+	"bbands":   {[]string{"period", "k"}, []float64{20, 2}, []bool{true, false}},
+	"atr":      {[]string{"period"}, []float64{14}, []bool{true}},
+	"obv":      {nil, nil, nil},
+	"high_low": {[]string{"window"}, []float64{risk.TradingDaysPerYear}, []bool{true}},
+}
+
+// Kinds lists the supported indicator kinds
+var Kinds = []string{"sma", "ema", "rsi", "macd", "bbands", "atr", "obv", "high_low"}
+
+// maxPeriod bounds bar-count parameters
+const maxPeriod = 1000
+
+// ParseIndicator parses an indicator name: the kind optionally followed by its parameters,
+// separated by underscores
+func ParseIndicator(name string) (Indicator, error) {
+	name = strings.ToLower(strings.TrimSpace(name))
+	for _, k := range Kinds {
+		if name != k && !strings.HasPrefix(name, k+"_") {
+			continue
+		}
+		def := kinds[k]
+		var raw []string
+		if name != k {
+			raw = strings.Split(strings.TrimPrefix(name, k+"_"), "_")
+		}
+		if len(raw) > len(def.params) {
+			return Indicator{}, fmt.Errorf("%s takes at most %d parameter(s): %s", k, len(def.params), strings.Join(def.params, ", "))
+		}
+
+		ind := Indicator{Kind: k, Params: append([]float64(nil), def.defaults...)}
+		for i, r := range raw {
+			v, err := strconv.ParseFloat(r, 64)
+			if err != nil || v <= 0 || (def.integer[i] && (v != float64(int(v)) || v > maxPeriod)) {
+				return Indicator{}, fmt.Errorf("invalid %s %s %q", k, def.params[i], r)
+			}
+			ind.Params[i] = v
+		}
+		if k == "macd" && ind.Params[0] >= ind.Params[1] {
+			return Indicator{}, fmt.Errorf("macd fast period must be shorter than the slow period")
+		}
+
+		parts := []string{k}
+		for _, p := range ind.Params {
+			parts = append(parts, strconv.FormatFloat(p, 'f', -1, 64))
+		}
+		ind.Name = strings.Join(parts, "_")
+		return ind, nil
+	}
+	return Indicator{}, fmt.Errorf("unknown indicator %q, expected one of %s", name, strings.Join(Kinds, ", "))
+}
+
+// Lookback is the number of bars of history to load before the first value that should
+// be defined. Exponentially smoothed indicators get extra bars to converge.
+func (ind Indicator) Lookback() int {
+	p := func(i int) int { return int(ind.Params[i]) }
+	switch ind.Kind {
+	case "ema", "rsi", "atr":
+		return 3 * p(0)
+	case "macd":
+		return 3 * (p(1) + p(2))
+	case "sma", "bbands", "high_low":
+		return p(0)
+	}
+	return 0
+}
+
+// Compute evaluates the indicator and returns its lines by name: "value" for single-line
+// indicators, "macd", "signal" and "histogram" for MACD, "upper", "middle" and "lower" for
+// Bollinger Bands and "from_high" and "from_low" for the high/low distance
+func (ind Indicator) Compute(prices []models.HistoricalPrice) map[string]Series {
+	p := func(i int) int { return int(ind.Params[i]) }
+	switch ind.Kind {
+	case "sma":
+		return map[string]Series{"value": SMA(prices, p(0))}
+	case "ema":
+		return map[string]Series{"value": EMA(prices, p(0))}
+	case "rsi":
+		return map[string]Series{"value": RSI(prices, p(0))}
+	case "macd":
+		m := MACD(prices, p(0), p(1), p(2))
+		return map[string]Series{"macd": m.MACD, "signal": m.Signal, "histogram": m.Histogram}
+	case "bbands":
+		b := Bollinger(prices, p(0), ind.Params[1])
+		return map[string]Series{"upper": b.Upper, "middle": b.Middle, "lower": b.Lower}
+	case "atr":
+		return map[string]Series{"value": ATR(prices, p(0))}
+	case "obv":
+		return map[string]Series{"value": OBV(prices)}
+	case "high_low":
+		d := HighLow(prices, p(0))
+		return map[string]Series{"from_high": d.FromHigh, "from_low": d.FromLow}
+	}
+	return nil
+}
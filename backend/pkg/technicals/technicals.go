@@ -0,0 +1,274 @@
+// Package technicals computes technical indicators over daily OHLCV prices. Every indicator
+// returns a Series aligned with its input prices (oldest first) that is nil until enough
+// history is available.
+package technicals
+
+import (
+	"math"
+
+	"stockpick-backend/pkg/models"
+)
+
+// Series holds one indicator value per price bar, nil where the indicator is undefined
+type Series []*float64
+
+// Last returns the most recent value of the series, if defined
+func (s Series) Last() (float64, bool) {
+	if len(s) == 0 || s[len(s)-1] == nil {
+		return 0, false
+	}
+	return *s[len(s)-1], true
+}
+
+// MACDLines are the lines of the MACD indicator
+type MACDLines struct {
+	MACD      Series // Fast minus slow EMA
+	Signal    Series // EMA of the MACD line
+	Histogram Series // MACD minus signal
+}
+
+// BollingerBands are a moving average with bands k standard deviations above and below
+type BollingerBands struct {
+	Upper  Series
+	Middle Series
+	Lower  Series
+}
+
+// HighLowDistance is the distance of the close from the rolling high and low
+type HighLowDistance struct {
+	FromHigh Series // Close relative to the highest high, 0 at the high and negative below it
+	FromLow  Series // Close relative to the lowest low, 0 at the low and positive above it
+}
+
+// SMA is the simple moving average of the close over period bars
+func SMA(prices []models.HistoricalPrice, period int) Series {
+	return toSeries(sma(closes(prices), period))
+}
+
+// EMA is the exponential moving average of the close over period bars, seeded with the
+// simple average of the first period closes
+func EMA(prices []models.HistoricalPrice, period int) Series {
+	return toSeries(ema(closes(prices), period))
+}
+
+// RSI is the relative strength index of the close with Wilder's smoothing, from 0 to 100
+func RSI(prices []models.HistoricalPrice, period int) Series {
+	out := nanSeries(len(prices))
+	if period <= 0 || len(prices) <= period {
+		return toSeries(out)
+	}
+	var gain, loss float64
+	for i := 1; i <= period; i++ {
+		change := prices[i].ClosePrice - prices[i-1].ClosePrice
+		gain += math.Max(change, 0)
+		loss += math.Max(-change, 0)
+	}
+	gain /= float64(period)
+	loss /= float64(period)
+	out[period] = rsi(gain, loss)
+	for i := period + 1; i < len(prices); i++ {
+		change := prices[i].ClosePrice - prices[i-1].ClosePrice
+		gain = (gain*float64(period-1) + math.Max(change, 0)) / float64(period)
+		loss = (loss*float64(period-1) + math.Max(-change, 0)) / float64(period)
+		out[i] = rsi(gain, loss)
+	}
+	return toSeries(out)
+}
+
+func rsi(gain, loss float64) float64 {
+	if loss == 0 {
+		if gain == 0 {
+			return 50
+		}
+		return 100
+	}
+	return 100 - 100/(1+gain/loss)
+}
+
+// MACD is the moving average convergence divergence of the close, typically 12, 26 and 9
+func MACD(prices []models.HistoricalPrice, fast, slow, signal int) MACDLines {
+	c := closes(prices)
+	fastEMA, slowEMA := ema(c, fast), ema(c, slow)
+	line := nanSeries(len(c))
+	for i := range c {
+		line[i] = fastEMA[i] - slowEMA[i] // NaN while either is undefined
+	}
+
+	// The signal line averages the defined part of the MACD line
+	signalLine := nanSeries(len(c))
+	first := len(c)
+	for i, v := range line {
+		if !math.IsNaN(v) {
+			first = i
+			break
+		}
+	}
+	if first < len(c) {
+		copy(signalLine[first:], ema(line[first:], signal))
+	}
+	histogram := nanSeries(len(c))
+	for i := range c {
+		histogram[i] = line[i] - signalLine[i]
+	}
+	return MACDLines{MACD: toSeries(line), Signal: toSeries(signalLine), Histogram: toSeries(histogram)}
+}
+
+// Bollinger returns Bollinger Bands of the close over period bars, k population standard
+// deviations wide
+func Bollinger(prices []models.HistoricalPrice, period int, k float64) BollingerBands {
+	c := closes(prices)
+	middle := sma(c, period)
+	upper, lower := nanSeries(len(c)), nanSeries(len(c))
+	for i := period - 1; i >= 0 && i < len(c); i++ {
+		ss := 0.0
+		for _, v := range c[i-period+1 : i+1] {
+			ss += (v - middle[i]) * (v - middle[i])
+		}
+		sd := math.Sqrt(ss / float64(period))
+		upper[i] = middle[i] + k*sd
+		lower[i] = middle[i] - k*sd
+	}
+	return BollingerBands{Upper: toSeries(upper), Middle: toSeries(middle), Lower: toSeries(lower)}
+}
+
+// ATR is the average true range over period bars with Wilder's smoothing
+func ATR(prices []models.HistoricalPrice, period int) Series {
+	out := nanSeries(len(prices))
+	if period <= 0 || len(prices) < period {
+		return toSeries(out)
+	}
+	tr := make([]float64, len(prices))
+	for i, p := range prices {
+		high, low := highLow(p)
+		tr[i] = high - low
+		if i > 0 {
+			prev := prices[i-1].ClosePrice
+			tr[i] = math.Max(tr[i], math.Max(math.Abs(high-prev), math.Abs(low-prev)))
+		}
+	}
+	atr := 0.0
+	for _, v := range tr[:period] {
+		atr += v
+	}
+	atr /= float64(period)
+	out[period-1] = atr
+	for i := period; i < len(prices); i++ {
+		atr = (atr*float64(period-1) + tr[i]) / float64(period)
+		out[i] = atr
+	}
+	return toSeries(out)
+}
+
+// OBV is the on-balance volume: volume added on up closes and subtracted on down closes,
+// starting at 0 on the first bar
+func OBV(prices []models.HistoricalPrice) Series {
+	out := nanSeries(len(prices))
+	obv := 0.0
+	for i, p := range prices {
+		if i > 0 {
+			switch prev := prices[i-1].ClosePrice; {
+			case p.ClosePrice > prev:
+				obv += float64(p.Volume)
+			case p.ClosePrice < prev:
+				obv -= float64(p.Volume)
+			}
+		}
+		out[i] = obv
+	}
+	return toSeries(out)
+}
+
+// HighLow is the distance of the close from the highest high and lowest low of the last
+// window bars, including the current one. It is defined once a full window is available.
+func HighLow(prices []models.HistoricalPrice, window int) HighLowDistance {
+	fromHigh, fromLow := nanSeries(len(prices)), nanSeries(len(prices))
+	for i := window - 1; i >= 0 && i < len(prices); i++ {
+		high, low := highLow(prices[i])
+		for _, p := range prices[i-window+1 : i] {
+			h, l := highLow(p)
+			high, low = math.Max(high, h), math.Min(low, l)
+		}
+		if high > 0 {
+			fromHigh[i] = prices[i].ClosePrice/high - 1
+		}
+		if low > 0 {
+			fromLow[i] = prices[i].ClosePrice/low - 1
+		}
+	}
+	return HighLowDistance{FromHigh: toSeries(fromHigh), FromLow: toSeries(fromLow)}
+}
+
+// highLow returns the range of a bar, falling back to the close when it was not recorded
+func highLow(p models.HistoricalPrice) (float64, float64) {
+	high, low := p.HighPrice, p.LowPrice
+	if high <= 0 {
+		high = p.ClosePrice
+	}
+	if low <= 0 {
+		low = p.ClosePrice
+	}
+	return high, low
+}
+
+func closes(prices []models.HistoricalPrice) []float64 {
+	c := make([]float64, len(prices))
+	for i, p := range prices {
+		c[i] = p.ClosePrice
+	}
+	return c
+}
+
+func sma(values []float64, period int) []float64 {
+	out := nanSeries(len(values))
+	if period <= 0 {
+		return out
+	}
+	sum := 0.0
+	for i, v := range values {
+		sum += v
+		if i >= period {
+			sum -= values[i-period]
+		}
+		if i >= period-1 {
+			out[i] = sum / float64(period)
+		}
+	}
+	return out
+}
+
+func ema(values []float64, period int) []float64 {
+	out := nanSeries(len(values))
+	if period <= 0 || len(values) < period {
+		return out
+	}
+	seed := 0.0
+	for _, v := range values[:period] {
+		seed += v
+	}
+	out[period-1] = seed / float64(period)
+	alpha := 2 / float64(period+1)
+	for i := period; i < len(values); i++ {
+		out[i] = alpha*values[i] + (1-alpha)*out[i-1]
+	}
+	return out
+}
+
+func nanSeries(n int) []float64 {
+	out := make([]float64, n)
+	for i := range out {
+		out[i] = math.NaN()
+	}
+	return out
+}
+
+// toSeries converts NaN-padded values into a Series
+func toSeries(values []float64) Series {
+	s := make(Series, len(values))
+	for i, v := range values {
+		if !math.IsNaN(v) && !math.IsInf(v, 0) {
+			v := v
+			s[i] = &v
+		}
+	}
+	return s
+}
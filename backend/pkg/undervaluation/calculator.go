@@ -3,20 +3,69 @@ package undervaluation
 import (
 	"fmt"
 	"math"
+	"time"
+
+	"stockpick-backend/pkg/analyst"
+	"stockpick-backend/pkg/dividends"
+	"stockpick-backend/pkg/estimates"
+	"stockpick-backend/pkg/growth"
 	"stockpick-backend/pkg/models"
+	"stockpick-backend/pkg/momentum"
+	"stockpick-backend/pkg/quality"
+	"stockpick-backend/pkg/risk"
+	"stockpick-backend/pkg/sentiment"
+)
+
+// Component weights of the composite score (example weights).
+// Weights are illustrative and can be fine-tuned
+const (
+	FundamentalWeight = 0.50
+	AnalystWeight     = 0.30
+	SentimentWeight   = 0.20
 )
 
 // UndervaluationScore represents the calculated undervaluation score for a stock
 type UndervaluationScore struct {
-	StockID          string  `json:"stock_id"`
-	Symbol           string  `json:"symbol"`
-	Score            float64 `json:"score"`
-	FundamentalScore float64 `json:"fundamental_score"`
-	AnalystScore     float64 `json:"analyst_score"`
-	SentimentScore   float64 `json:"sentiment_score"`
+	StockID          string                 `json:"stock_id"`
+	Symbol           string                 `json:"symbol"`
+	Score            float64                `json:"score"`
+	FundamentalScore float64                `json:"fundamental_score"`
+	AnalystScore     float64                `json:"analyst_score"`
+	SentimentScore   float64                `json:"sentiment_score"`
+	FScore           *int                   `json:"f_score,omitempty"` // Piotroski F-Score, informational
+	ZScore           *float64               `json:"z_score,omitempty"` // Altman Z-Score, informational
+	Confidence       float64                `json:"confidence"`        // 0-1 share of the composite backed by fresh data
+	Coverage         map[string]InputStatus `json:"coverage"`
+	PeerGroup        string                 `json:"peer_group,omitempty"`       // Relative mode: sector or industry ranked within, "universe" when too small for every metric
+	UniverseMetrics  []string               `json:"universe_metrics,omitempty"` // Relative mode: metrics ranked against the whole universe for want of peers
+	Percentiles      map[string]float64     `json:"percentiles,omitempty"`      // Relative mode: per-metric percentile, 1 is best
+	Analyst          *analyst.Factors       `json:"analyst,omitempty"`          // Revision, dispersion and coverage factors of the analyst score
+	Sentiment        *sentiment.Summary     `json:"sentiment,omitempty"`        // Aggregated sentiment behind the sentiment score
+	Risk             *risk.Metrics          `json:"risk,omitempty"`             // Risk statistics of the price history
+	Trend            *momentum.Signal       `json:"trend,omitempty"`            // Momentum overlay, when requested
+	Dividends        *dividends.Metrics     `json:"dividends,omitempty"`        // Yield, payout and growth of the dividend history
 	// Add more detailed breakdown if needed
 }
 
+// Inputs bundles the data a stock is scored on
+type Inputs struct {
+	Stock               *models.Stock
+	LatestPrice         float64
+	LatestPriceTime     time.Time
+	Prices              []models.HistoricalPrice    // Optional daily history adjusted for splits and dividends, oldest first
+	Benchmark           []models.HistoricalPrice    // Optional benchmark history over the same period, for beta
+	AsOf                time.Time                   // Reference time for staleness, defaults to now
+	FinancialStatements []models.FinancialStatement // Newest first
+	AnalystTargets      []models.AnalystTarget      // Newest first
+	SentimentScores     []models.SentimentScore     // Any source, any order
+	SentimentConfig     *sentiment.Config           // Optional, defaults to sentiment.DefaultConfig
+	Growth              *growth.Metrics             // Optional, derived from annual statements
+	Quality             *quality.Scores             // Optional, reported alongside the score
+	Estimates           *estimates.Metrics          // Optional, forward metrics from consensus estimates
+	Dividends           *dividends.Metrics          // Optional, reported alongside the score; nil for non-payers
+	DrawdownPenalty     bool                        // Scale the fundamental score down for a deep drawdown, off by default
+}
+
 // CalculateUndervaluation calculates a composite undervaluation score for a stock.
 // This is a simplified model based on the provided specs.
 func CalculateUndervaluation(
@@ -26,6 +75,21 @@ func CalculateUndervaluation(
 	analystTargets []models.AnalystTarget,
 	sentimentScores []models.SentimentScore,
 ) (*UndervaluationScore, error) {
+	return Calculate(Inputs{
+		Stock:               stock,
+		LatestPrice:         latestPrice,
+		FinancialStatements: financialStatements,
+		AnalystTargets:      analystTargets,
+		SentimentScores:     sentimentScores,
+	})
+}
+
+// Calculate calculates a composite undervaluation score from the given inputs.
+func Calculate(in Inputs) (*UndervaluationScore, error) {
+	stock := in.Stock
+	latestPrice := in.LatestPrice
+	financialStatements := in.FinancialStatements
+	analystTargets := in.AnalystTargets
 
 	if stock == nil || latestPrice == 0 {
 		return nil, fmt.Errorf("invalid input: stock or latest price is missing")
@@ -47,10 +111,21 @@ func CalculateUndervaluation(
 		}
 
 		// EPS Growth (higher is better)
-		// This requires historical EPS, which we don't have directly in latestFS. For simplicity,
-		// we'll assume a positive EPS is good for now. In a real scenario, compare current EPS to previous.
-		if latestFS.EPS > 0 {
-			fundamentalScore += 0.2
+		// Without enough history for a growth rate, the consensus expectation is used
+		// and failing that a positive EPS earns partial credit.
+		if epsGrowth, ok := epsGrowth(in); ok {
+			if epsGrowth > 0.10 { // > 10% a year
+				fundamentalScore += 0.2
+			} else if epsGrowth > 0 {
+				fundamentalScore += 0.1
+			}
+		} else if latestFS.EPS > 0 {
+			fundamentalScore += 0.1
+		}
+
+		// PEG Ratio (below 1 means the P/E is cheap relative to earnings growth)
+		if in.Growth != nil && in.Growth.PEG != nil && *in.Growth.PEG < 1 {
+			fundamentalScore += 0.15
 		}
 
 		// ROIC (higher is better, e.g., > 15%)
@@ -64,9 +139,18 @@ func CalculateUndervaluation(
 		}
 	}
 
+	// A deep drawdown over the price history often marks a value trap rather than a bargain.
+	// The risk statistics are always reported; the penalty only applies on request.
+	riskStats, hasRisk := risk.Compute(in.Prices, in.Benchmark, 0)
+	if hasRisk && in.DrawdownPenalty {
+		fundamentalScore *= 1 - risk.DrawdownPenalty(riskStats.MaxDrawdown)
+	}
+
 	// --- Analyst Consensus Score (Simplified) ---
 	analystScore := 0.0
-	if len(analystTargets) > 0 {
+	targetRevisionUp := false
+	analystFactors, hasAnalyst := analyst.Compute(analystTargets)
+	if hasAnalyst {
 		latestAT := analystTargets[0] // Assuming latest is first
 
 		// Price Target Upside
@@ -85,55 +169,142 @@ func CalculateUndervaluation(
 		} else if latestAT.ConsensusRatingValue >= 3.0 { // Hold
 			analystScore += 0.15
 		}
-	}
 
-	// --- Sentiment Analysis Score (Simplified) ---
-	sentimentScore := 0.0
-	if len(sentimentScores) > 0 {
-		latestSS := sentimentScores[0] // Assuming latest is first
+		// Revision momentum: rising consensus target
+		if revision, ok := analystFactors.TargetRevision(); ok && revision > 0.05 {
+			analystScore += 0.15
+			targetRevisionUp = true
+		}
 
-		// Sentiment Score (e.g., 0 to 100, higher is better)
-		// Normalize to 0-1 scale if it's 0-100
-	normalizedSentiment := latestSS.SentimentScore
-	if normalizedSentiment > 1 {
-		normalizedSentiment /= 100.0
+		// More upgrades than downgrades over the last 90 days
+		if analystFactors.Upgrades > analystFactors.Downgrades ||
+			(analystFactors.RatingChange90D != nil && *analystFactors.RatingChange90D >= 0.1) {
+			analystScore += 0.15
+		}
+
+		// Wide disagreement between targets makes the consensus less reliable
+		analystScore *= 1 - analystFactors.UncertaintyPenalty()
+	}
+
+	// Upward EPS estimate revisions stand in for a rising target, with or without targets
+	hasRevisions := in.Estimates != nil && in.Estimates.RevisionTrend != ""
+	if !targetRevisionUp && hasRevisions && in.Estimates.RevisionTrend == estimates.TrendUp {
+		analystScore += 0.15
+	}
+	analystWeight := analystFactors.CountWeight
+	if !hasAnalyst && hasRevisions {
+		analystWeight = analyst.CountWeight(in.Estimates.Analysts)
 	}
 
-		if normalizedSentiment > 0.7 { // High positive sentiment
+	// --- Sentiment Analysis Score ---
+	// Time-decayed blend of all sources, rewarding positive sentiment that is improving
+	// or drawing unusually heavy discussion
+	sentimentScore := 0.0
+	summary, hasSentiment := sentimentSummary(in)
+	if hasSentiment {
+		if summary.Score > 0.7 { // High positive sentiment
+			sentimentScore += 0.4
+		} else if summary.Score > 0.5 { // Neutral to slightly positive
 			sentimentScore += 0.2
-		} else if normalizedSentiment > 0.5 { // Neutral to slightly positive
-			sentimentScore += 0.1
 		}
 
-		// Absolute Index (discussion volume) - higher indicates more interest
-		if latestSS.AbsoluteIndex > 100000 { // Arbitrary high volume threshold
-			sentimentScore += 0.1
+		// Momentum: recent sentiment above the earlier baseline
+		if summary.Momentum != nil && *summary.Momentum > 0.05 {
+			sentimentScore += 0.3
 		}
-	}
 
-	// --- Combine Scores with Weights (Example Weights) ---
-	// Weights are illustrative and can be fine-tuned
-	const ( 
-		FundamentalWeight = 0.50
-		AnalystWeight     = 0.30
-		SentimentWeight   = 0.20
-	)
+		// Abnormal discussion volume only helps when the crowd is positive
+		if summary.AbnormalVolume && summary.Score > 0.5 {
+			sentimentScore += 0.3
+		}
+	}
 
-	compositeScore := (
-		fundamentalScore*FundamentalWeight + 
-		analystScore*AnalystWeight + 
-		sentimentScore*SentimentWeight
-	) * 100 // Scale to 0-100 for easier interpretation
+	// --- Combine Scores with Weights ---
+	// Components without data are left out and the remaining weights renormalized
+	composite, confidence, coverage := combine(in, componentScores{
+		fundamental:    fundamentalScore,
+		analyst:        analystScore,
+		sentiment:      sentimentScore,
+		hasFundamental: len(financialStatements) > 0,
+		hasAnalyst:     hasAnalyst || hasRevisions,
+		hasSentiment:   hasSentiment,
+		analystWeight:  analystWeight,
+	})
+	compositeScore := composite * 100 // Scale to 0-100 for easier interpretation
 
 	// Cap the score at 100
 	compositeScore = math.Min(compositeScore, 100.0)
 
-	return &UndervaluationScore{
+	result := &UndervaluationScore{
 		StockID:          stock.StockID.String(),
 		Symbol:           stock.Symbol,
 		Score:            compositeScore,
 		FundamentalScore: fundamentalScore * 100, // Scaled for output
 		AnalystScore:     analystScore * 100,
 		SentimentScore:   sentimentScore * 100,
-	}, nil
+		Confidence:       confidence,
+		Coverage:         coverage,
+	}
+	if hasAnalyst {
+		result.Analyst = &analystFactors
+	}
+	if hasSentiment {
+		result.Sentiment = &summary
+	}
+	if hasRisk {
+		result.Risk = &riskStats
+	}
+	result.setQuality(in.Quality)
+	result.Dividends = in.Dividends
+	return result, nil
+}
+
+// setQuality reports the quality scores alongside the undervaluation score
+func (s *UndervaluationScore) setQuality(q *quality.Scores) {
+	if q == nil {
+		return
+	}
+	if q.FScore != nil {
+		s.FScore = &q.FScore.Score
+	}
+	if q.ZScore != nil {
+		s.ZScore = &q.ZScore.Score
+	}
+}
+
+// epsGrowth returns the preferred historical EPS growth rate, falling back to the growth
+// the consensus estimates expect for the next fiscal year
+func epsGrowth(in Inputs) (float64, bool) {
+	if in.Growth != nil {
+		if g, ok := in.Growth.EPSGrowth(); ok {
+			return g, true
+		}
+	}
+	if in.Estimates != nil && in.Estimates.ExpectedEPSGrowth != nil {
+		return *in.Estimates.ExpectedEPSGrowth, true
+	}
+	return 0, false
+}
+
+// sentimentSummary aggregates the sentiment observations as of the scoring time
+func sentimentSummary(in Inputs) (sentiment.Summary, bool) {
+	cfg := sentiment.DefaultConfig()
+	if in.SentimentConfig != nil {
+		cfg = *in.SentimentConfig
+	}
+	asOf := in.AsOf
+	if asOf.IsZero() {
+		asOf = time.Now()
+	}
+	return sentiment.Aggregate(in.SentimentScores, asOf, cfg)
+}
+
+// ApplyTrend evaluates the momentum overlay on the stock's price history and records it on
+// the score. In penalize mode the composite score is reduced by the trend penalty; it
+// returns false when the exclude mode drops the stock.
+func ApplyTrend(s *UndervaluationScore, in Inputs, cfg momentum.Config) bool {
+	signal := momentum.Evaluate(in.Prices, cfg)
+	s.Trend = &signal
+	s.Score *= 1 - signal.Penalty
+	return !signal.Excluded
 }
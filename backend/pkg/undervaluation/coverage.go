@@ -0,0 +1,138 @@
+package undervaluation
+
+import (
+	"time"
+)
+
+// Inputs older than these ages are stale and count progressively less towards confidence
+const (
+	PriceStaleAfter        = 7 * 24 * time.Hour
+	FundamentalsStaleAfter = 450 * 24 * time.Hour // Period end of the latest statement
+	AnalystStaleAfter      = 120 * 24 * time.Hour
+	SentimentStaleAfter    = 14 * 24 * time.Hour
+)
+
+// Coverage keys of the scoring inputs
+const (
+	InputPrice        = "price"
+	InputFundamentals = "fundamentals"
+	InputAnalyst      = "analyst"
+	InputSentiment    = "sentiment"
+)
+
+// InputStatus reports whether a scoring input was available and how fresh it was
+type InputStatus struct {
+	Available bool       `json:"available"`
+	AsOf      *time.Time `json:"as_of,omitempty"`
+	AgeDays   *float64   `json:"age_days,omitempty"`
+	Stale     bool       `json:"stale"`
+	Freshness float64    `json:"freshness"` // 1 while fresh, decaying as staleAfter/age once stale
+	Weight    float64    `json:"weight"`    // Weight in the composite after renormalization
+}
+
+// componentScores are the 0-1 component scores with their availability
+type componentScores struct {
+	fundamental, analyst, sentiment          float64
+	hasFundamental, hasAnalyst, hasSentiment bool
+	analystWeight                            float64 // 0-1 scale of AnalystWeight by analyst count
+}
+
+// combine weights the available components, renormalizing the weights so that missing
+// data does not count as a bad score, and reports how complete and fresh the inputs were.
+// Confidence is the share of composite weight backed by data, discounted for staleness.
+// A thinly covered stock's analyst consensus carries proportionally less weight.
+func combine(in Inputs, c componentScores) (composite, confidence float64, coverage map[string]InputStatus) {
+	asOf := in.AsOf
+	if asOf.IsZero() {
+		asOf = time.Now()
+	}
+
+	coverage = map[string]InputStatus{
+		InputPrice:        inputStatus(in.LatestPrice > 0, in.LatestPriceTime, asOf, PriceStaleAfter),
+		InputFundamentals: inputStatus(c.hasFundamental, latestStatementDate(in), asOf, FundamentalsStaleAfter),
+		InputAnalyst:      inputStatus(c.hasAnalyst, latestTargetDate(in), asOf, AnalystStaleAfter),
+		InputSentiment:    inputStatus(c.hasSentiment, latestSentimentTime(in), asOf, SentimentStaleAfter),
+	}
+
+	components := []struct {
+		key       string
+		score     float64
+		weight    float64
+		available bool
+	}{
+		{InputFundamentals, c.fundamental, FundamentalWeight, c.hasFundamental},
+		{InputAnalyst, c.analyst, AnalystWeight * c.analystWeight, c.hasAnalyst},
+		{InputSentiment, c.sentiment, SentimentWeight, c.hasSentiment},
+	}
+
+	availableWeight := 0.0
+	for _, comp := range components {
+		if comp.available {
+			availableWeight += comp.weight
+		}
+	}
+	if availableWeight == 0 {
+		return 0, 0, coverage
+	}
+
+	for _, comp := range components {
+		if !comp.available {
+			continue
+		}
+		s := coverage[comp.key]
+		s.Weight = comp.weight / availableWeight
+		coverage[comp.key] = s
+
+		composite += comp.score * s.Weight
+		confidence += comp.weight * s.Freshness
+	}
+	confidence *= coverage[InputPrice].Freshness
+	return composite, confidence, coverage
+}
+
+// inputStatus builds the coverage entry of one input. Inputs without a known date are
+// treated as fresh.
+func inputStatus(available bool, at, asOf time.Time, staleAfter time.Duration) InputStatus {
+	s := InputStatus{Available: available}
+	if !available {
+		return s
+	}
+	s.Freshness = 1
+	if at.IsZero() {
+		return s
+	}
+
+	age := asOf.Sub(at)
+	days := age.Hours() / 24
+	s.AsOf = &at
+	s.AgeDays = &days
+	if age > staleAfter {
+		s.Stale = true
+		s.Freshness = float64(staleAfter) / float64(age)
+	}
+	return s
+}
+
+func latestStatementDate(in Inputs) time.Time {
+	if len(in.FinancialStatements) == 0 {
+		return time.Time{}
+	}
+	return in.FinancialStatements[0].Date
+}
+
+func latestTargetDate(in Inputs) time.Time {
+	if len(in.AnalystTargets) == 0 {
+		return time.Time{}
+	}
+	return in.AnalystTargets[0].Date
+}
+
+func latestSentimentTime(in Inputs) time.Time {
+	var latest time.Time
+	for _, s := range in.SentimentScores {
+		if s.Timestamp.After(latest) {
+			latest = s.Timestamp
+		}
+	}
+	return latest
+}
@@ -0,0 +1,275 @@
+package undervaluation
+
+import (
+	"fmt"
+	"math"
+	"sort"
+
+	"stockpick-backend/pkg/analyst"
+	"stockpick-backend/pkg/models"
+	"stockpick-backend/pkg/risk"
+)
+
+// Peer groupings for relative scoring
+const (
+	GroupSector   = "sector"
+	GroupIndustry = "industry"
+)
+
+// PeerGroupUniverse is reported as the peer group of stocks ranked against the whole universe
+const PeerGroupUniverse = "universe"
+
+// MinGroupSize is the fewest peers with a metric needed to rank within the group;
+// smaller groups are ranked against the whole universe for that metric.
+const MinGroupSize = 5
+
+// Score components
+const (
+	componentFundamental = "fundamental"
+	componentAnalyst     = "analyst"
+	componentSentiment   = "sentiment"
+)
+
+// relativeMetric is a metric ranked within a peer group
+type relativeMetric struct {
+	name           string
+	component      string
+	higherIsBetter bool
+	value          func(in Inputs) (float64, bool)
+}
+
+var relativeMetrics = []relativeMetric{
+	{"pe", componentFundamental, false, func(in Inputs) (float64, bool) {
+		return positiveRatio(in, func(fs models.FinancialStatement) float64 { return fs.PERatio })
+	}},
+	{"pb", componentFundamental, false, func(in Inputs) (float64, bool) {
+		return positiveRatio(in, func(fs models.FinancialStatement) float64 { return fs.PBRatio })
+	}},
+	{"ps", componentFundamental, false, func(in Inputs) (float64, bool) {
+		return positiveRatio(in, func(fs models.FinancialStatement) float64 { return fs.PSRatio })
+	}},
+	{"ev_ebitda", componentFundamental, false, func(in Inputs) (float64, bool) {
+		return positiveRatio(in, func(fs models.FinancialStatement) float64 { return fs.EVToEBITDA })
+	}},
+	{"fcf_yield", componentFundamental, true, func(in Inputs) (float64, bool) {
+		if len(in.FinancialStatements) == 0 || in.FinancialStatements[0].FCFYield == 0 {
+			return 0, false
+		}
+		return in.FinancialStatements[0].FCFYield, true
+	}},
+	{"roic", componentFundamental, true, func(in Inputs) (float64, bool) {
+		if len(in.FinancialStatements) == 0 || in.FinancialStatements[0].ROIC == 0 {
+			return 0, false
+		}
+		return in.FinancialStatements[0].ROIC, true
+	}},
+	{"eps_growth", componentFundamental, true, func(in Inputs) (float64, bool) {
+		return epsGrowth(in)
+	}},
+	{"forward_pe", componentFundamental, false, func(in Inputs) (float64, bool) {
+		if in.Estimates == nil || in.Estimates.ForwardPE == nil {
+			return 0, false
+		}
+		return *in.Estimates.ForwardPE, true
+	}},
+	{"upside", componentAnalyst, true, func(in Inputs) (float64, bool) {
+		if len(in.AnalystTargets) == 0 || in.AnalystTargets[0].ConsensusPriceTarget <= 0 {
+			return 0, false
+		}
+		return (in.AnalystTargets[0].ConsensusPriceTarget - in.LatestPrice) / in.LatestPrice, true
+	}},
+	{"rating", componentAnalyst, true, func(in Inputs) (float64, bool) {
+		if len(in.AnalystTargets) == 0 || in.AnalystTargets[0].ConsensusRatingValue <= 0 {
+			return 0, false
+		}
+		return in.AnalystTargets[0].ConsensusRatingValue, true
+	}},
+	{"target_revision", componentAnalyst, true, func(in Inputs) (float64, bool) {
+		f, ok := analyst.Compute(in.AnalystTargets)
+		if !ok {
+			return 0, false
+		}
+		return f.TargetRevision()
+	}},
+	{"target_dispersion", componentAnalyst, false, func(in Inputs) (float64, bool) {
+		f, ok := analyst.Compute(in.AnalystTargets)
+		if !ok || f.Dispersion == nil {
+			return 0, false
+		}
+		return *f.Dispersion, true
+	}},
+	{"eps_revision", componentAnalyst, true, func(in Inputs) (float64, bool) {
+		if in.Estimates == nil {
+			return 0, false
+		}
+		return in.Estimates.Revision()
+	}},
+	{"sentiment", componentSentiment, true, func(in Inputs) (float64, bool) {
+		summary, ok := sentimentSummary(in)
+		return summary.Score, ok
+	}},
+}
+
+// positiveRatio reads a valuation ratio of the scoring statement, which is only
+// meaningful when positive
+func positiveRatio(in Inputs, get func(models.FinancialStatement) float64) (float64, bool) {
+	if len(in.FinancialStatements) == 0 {
+		return 0, false
+	}
+	v := get(in.FinancialStatements[0])
+	return v, v > 0
+}
+
+// CalculateRelative scores every stock of the universe by ranking its metrics as
+// percentiles within its sector or industry, so that "cheap" means cheap relative to
+// peers. Component scores are the mean percentile of their available metrics and are
+// combined with the same (renormalized) weights as the absolute model. Scores are in
+// universe order; stocks without a stock or latest price are left out of the ranking and
+// get a nil score.
+func CalculateRelative(universe []Inputs, groupBy string) ([]*UndervaluationScore, error) {
+	if groupBy != GroupSector && groupBy != GroupIndustry {
+		return nil, fmt.Errorf("unknown peer grouping %q", groupBy)
+	}
+
+	groups := make([]string, len(universe))
+	skip := make([]bool, len(universe))
+	for i, in := range universe {
+		if in.Stock == nil || in.LatestPrice == 0 {
+			skip[i] = true
+			continue
+		}
+		groups[i] = in.Stock.Sector
+		if groupBy == GroupIndustry {
+			groups[i] = in.Stock.Industry
+		}
+	}
+
+	percentiles := make([]map[string]float64, len(universe))
+	for i := range percentiles {
+		percentiles[i] = make(map[string]float64)
+	}
+	// Metrics of each stock ranked within its group and against the whole universe
+	inGroup := make([]int, len(universe))
+	fallbacks := make([][]string, len(universe))
+
+	for _, m := range relativeMetrics {
+		values := make([]float64, len(universe))
+		available := make([]bool, len(universe))
+		var all []float64
+		byGroup := make(map[string][]float64)
+		for i, in := range universe {
+			if skip[i] {
+				continue
+			}
+			values[i], available[i] = m.value(in)
+			if available[i] {
+				all = append(all, values[i])
+				byGroup[groups[i]] = append(byGroup[groups[i]], values[i])
+			}
+		}
+		sort.Float64s(all)
+		for _, peers := range byGroup {
+			sort.Float64s(peers)
+		}
+
+		for i := range universe {
+			if !available[i] {
+				continue
+			}
+			// Rank against peers when the group is large enough, otherwise against everyone
+			peers := all
+			if groups[i] != "" && len(byGroup[groups[i]]) >= MinGroupSize {
+				peers = byGroup[groups[i]]
+				inGroup[i]++
+			} else {
+				fallbacks[i] = append(fallbacks[i], m.name)
+			}
+			p := percentileRank(values[i], peers)
+			if !m.higherIsBetter {
+				p = 1 - p
+			}
+			percentiles[i][m.name] = p
+		}
+	}
+
+	scores := make([]*UndervaluationScore, len(universe))
+	for i, in := range universe {
+		if skip[i] {
+			continue
+		}
+		components := map[string][]float64{}
+		for _, m := range relativeMetrics {
+			if p, ok := percentiles[i][m.name]; ok {
+				components[m.component] = append(components[m.component], p)
+			}
+		}
+		analystFactors, hasAnalyst := analyst.Compute(in.AnalystTargets)
+		c := componentScores{
+			fundamental:    mean(components[componentFundamental]),
+			analyst:        mean(components[componentAnalyst]),
+			sentiment:      mean(components[componentSentiment]),
+			hasFundamental: len(components[componentFundamental]) > 0,
+			hasAnalyst:     len(components[componentAnalyst]) > 0,
+			hasSentiment:   len(components[componentSentiment]) > 0,
+			analystWeight:  analystFactors.CountWeight,
+		}
+		riskStats, hasRisk := risk.Compute(in.Prices, in.Benchmark, 0)
+		if hasRisk && in.DrawdownPenalty {
+			c.fundamental *= 1 - risk.DrawdownPenalty(riskStats.MaxDrawdown)
+		}
+		composite, confidence, coverage := combine(in, c)
+
+		score := UndervaluationScore{
+			StockID:          in.Stock.StockID.String(),
+			Symbol:           in.Stock.Symbol,
+			Score:            math.Min(composite*100, 100.0),
+			FundamentalScore: c.fundamental * 100,
+			AnalystScore:     c.analyst * 100,
+			SentimentScore:   c.sentiment * 100,
+			Confidence:       confidence,
+			Coverage:         coverage,
+			PeerGroup:        groups[i],
+			UniverseMetrics:  fallbacks[i],
+			Percentiles:      percentiles[i],
+		}
+		if inGroup[i] == 0 && len(fallbacks[i]) > 0 {
+			score.PeerGroup = PeerGroupUniverse
+		}
+		if hasAnalyst {
+			score.Analyst = &analystFactors
+		}
+		if summary, ok := sentimentSummary(in); ok {
+			score.Sentiment = &summary
+		}
+		if hasRisk {
+			score.Risk = &riskStats
+		}
+		score.setQuality(in.Quality)
+		score.Dividends = in.Dividends
+		scores[i] = &score
+	}
+	return scores, nil
+}
+
+// percentileRank returns the rank of v within sorted peer values, counting ties as half,
+// scaled so the lowest value is 0 and the highest is 1.
+func percentileRank(v float64, sorted []float64) float64 {
+	if len(sorted) <= 1 {
+		return 0.5
+	}
+	below := sort.SearchFloat64s(sorted, v)
+	equal := sort.SearchFloat64s(sorted, math.Nextafter(v, math.Inf(1))) - below
+	rank := float64(below) + float64(equal-1)/2
+	return rank / float64(len(sorted)-1)
+}
+
+func mean(values []float64) float64 {
+	if len(values) == 0 {
+		return 0
+	}
+	sum := 0.0
+	for _, v := range values {
+		sum += v
+	}
+	return sum / float64(len(values))
+}
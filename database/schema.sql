@@ -30,12 +30,61 @@ CREATE TABLE historical_prices (
 -- Convert to TimescaleDB hypertable, partitioned by time and symbol for performance
 SELECT create_hypertable('historical_prices', 'time', 'stock_id', number_partitions => 4);
 
+-- Create the intraday_prices table (Hypertable) for bars shorter than a day
+CREATE TABLE intraday_prices (
+    time TIMESTAMPTZ NOT NULL,                            -- Start of the bar
+    stock_id UUID NOT NULL REFERENCES stocks(stock_id),   -- Foreign key to stocks table
+    bar_interval TEXT NOT NULL,                           -- Bar length: '1m', '5m', '15m' or '1h'
+    open_price DOUBLE PRECISION,                          -- Opening price
+    high_price DOUBLE PRECISION,                          -- Highest price
+    low_price DOUBLE PRECISION,                           -- Lowest price
+    close_price DOUBLE PRECISION,                         -- Closing price
+    volume BIGINT,                                        -- Trading volume
+    PRIMARY KEY (time, stock_id, bar_interval)
+);
+
+-- One-day chunks keep a day of minute bars per chunk
+SELECT create_hypertable('intraday_prices', 'time', 'stock_id', number_partitions => 4, chunk_time_interval => INTERVAL '1 day');
+CREATE INDEX idx_intraday_prices_stock_interval ON intraday_prices (stock_id, bar_interval, time DESC);
+
+-- Continuous aggregates, compression and retention policies are created by the backend's
+-- migrations (backend/pkg/database/migrations), which run at startup
+
+-- Create the stock_splits table (prices are stored unadjusted and adjusted on read)
+CREATE TABLE stock_splits (
+    stock_id UUID NOT NULL REFERENCES stocks(stock_id),   -- Foreign key to stocks table
+    date DATE NOT NULL,                                   -- First trading day at the new share count
+    numerator DOUBLE PRECISION NOT NULL,                  -- New shares ...
+    denominator DOUBLE PRECISION NOT NULL,                -- ... for every this many old shares (e.g., 4 for 1)
+    created_at TIMESTAMPTZ DEFAULT NOW(),                 -- Timestamp of record creation
+    updated_at TIMESTAMPTZ DEFAULT NOW(),                 -- Timestamp of last record update
+    PRIMARY KEY (stock_id, date)
+);
+
+-- Create the dividends table
+CREATE TABLE dividends (
+    stock_id UUID NOT NULL REFERENCES stocks(stock_id),   -- Foreign key to stocks table
+    ex_date DATE NOT NULL,                                -- Ex-dividend date
+    amount DOUBLE PRECISION NOT NULL,                     -- Cash dividend per share as paid
+    adjusted_amount DOUBLE PRECISION,                     -- Dividend per share restated for later splits
+    record_date DATE,                                     -- Record date
+    payment_date DATE,                                    -- Payment date
+    declaration_date DATE,                                -- Declaration date
+    created_at TIMESTAMPTZ DEFAULT NOW(),                 -- Timestamp of record creation
+    updated_at TIMESTAMPTZ DEFAULT NOW(),                 -- Timestamp of last record update
+    PRIMARY KEY (stock_id, ex_date)
+);
+
 -- Create the financial_statements table
 CREATE TABLE financial_statements (
     statement_id UUID PRIMARY KEY DEFAULT gen_random_uuid(), -- Unique identifier for the statement record
     stock_id UUID NOT NULL REFERENCES stocks(stock_id),      -- Foreign key to stocks table
     date DATE NOT NULL,                                      -- Date of the financial statement (e.g., end of quarter/year)
     period TEXT NOT NULL,                                    -- 'annual' or 'quarterly'
+    filing_date DATE,                                        -- Date the report was filed with the SEC
+    accepted_date TIMESTAMPTZ,                               -- Time the filing was accepted by the SEC
+    revision INTEGER NOT NULL DEFAULT 1,                     -- 1 for the original report, incremented on each restatement
+    known_since TIMESTAMPTZ NOT NULL,                        -- When this revision became public (point-in-time queries)
     revenue NUMERIC(20, 2),                                  -- Total revenue
     net_income NUMERIC(20, 2),                               -- Net income
     eps NUMERIC(10, 4),                                      -- Earnings Per Share
@@ -43,15 +92,38 @@ CREATE TABLE financial_statements (
     total_liabilities NUMERIC(20, 2),                        -- Total liabilities
     total_equity NUMERIC(20, 2),                             -- Total equity
     free_cash_flow NUMERIC(20, 2),                           -- Free Cash Flow
+    gross_profit NUMERIC(20, 2),                             -- Gross profit
+    operating_income NUMERIC(20, 2),                         -- Operating income (EBIT)
+    ebitda NUMERIC(20, 2),                                   -- Earnings before interest, taxes, depreciation and amortization
+    income_before_tax NUMERIC(20, 2),                        -- Pre-tax income
+    income_tax_expense NUMERIC(20, 2),                       -- Income tax expense
+    total_debt NUMERIC(20, 2),                               -- Short- plus long-term debt
+    cash_and_equivalents NUMERIC(20, 2),                     -- Cash and cash equivalents
+    shares_outstanding NUMERIC(20, 2),                       -- Weighted average shares outstanding
+    current_assets NUMERIC(20, 2),                           -- Total current assets
+    current_liabilities NUMERIC(20, 2),                      -- Total current liabilities
+    long_term_debt NUMERIC(20, 2),                           -- Long-term debt
+    retained_earnings NUMERIC(20, 2),                        -- Retained earnings
+    operating_cash_flow NUMERIC(20, 2),                      -- Cash flow from operating activities
     debt_to_equity_ratio NUMERIC(10, 4),                     -- Debt-to-Equity Ratio
     p_e_ratio NUMERIC(10, 4),                                -- Price-to-Earnings Ratio
     p_b_ratio NUMERIC(10, 4),                                -- Price-to-Book Ratio
+    p_s_ratio NUMERIC(10, 4),                                -- Price-to-Sales Ratio
+    ev_to_ebitda NUMERIC(10, 4),                             -- Enterprise Value to EBITDA
+    fcf_yield NUMERIC(10, 4),                                -- Free cash flow yield (FCF / market cap)
     roic NUMERIC(10, 4),                                     -- Return on Invested Capital
+    roe NUMERIC(10, 4),                                      -- Return on Equity
+    gross_margin NUMERIC(10, 4),                             -- Gross profit / revenue
+    operating_margin NUMERIC(10, 4),                         -- Operating income / revenue
+    net_margin NUMERIC(10, 4),                               -- Net income / revenue
     created_at TIMESTAMPTZ DEFAULT NOW(),                    -- Timestamp of record creation
     updated_at TIMESTAMPTZ DEFAULT NOW(),                    -- Timestamp of last record update
-    UNIQUE (stock_id, date, period)                          -- Ensure unique statement per stock per period
+    UNIQUE (stock_id, date, period, revision)                -- Restatements are kept as separate revisions
 );
 
+-- Index for point-in-time ("as of") lookups of financial statements
+CREATE INDEX idx_financial_statements_known_since ON financial_statements (stock_id, period, known_since);
+
 -- Create the analyst_targets table
 CREATE TABLE analyst_targets (
     target_id UUID PRIMARY KEY DEFAULT gen_random_uuid(),    -- Unique identifier for the analyst target record
@@ -71,6 +143,28 @@ CREATE TABLE analyst_targets (
     UNIQUE (stock_id, date)                                  -- Ensure unique consensus per stock per date
 );
 
+-- Create the analyst_estimates table; every ingestion stores a snapshot so estimate revisions can be tracked
+CREATE TABLE analyst_estimates (
+    estimate_id UUID PRIMARY KEY DEFAULT gen_random_uuid(),  -- Unique identifier for the estimate record
+    stock_id UUID NOT NULL REFERENCES stocks(stock_id),      -- Foreign key to stocks table
+    fiscal_date DATE NOT NULL,                               -- End date of the estimated fiscal period
+    period TEXT NOT NULL,                                    -- 'annual', 'quarterly'
+    as_of DATE NOT NULL,                                     -- Date the consensus was captured
+    revenue_low NUMERIC(20, 2),                              -- Lowest revenue estimate
+    revenue_avg NUMERIC(20, 2),                              -- Consensus revenue estimate
+    revenue_high NUMERIC(20, 2),                             -- Highest revenue estimate
+    ebitda_avg NUMERIC(20, 2),                               -- Consensus EBITDA estimate
+    net_income_avg NUMERIC(20, 2),                           -- Consensus net income estimate
+    eps_low NUMERIC(10, 4),                                  -- Lowest EPS estimate
+    eps_avg NUMERIC(10, 4),                                  -- Consensus EPS estimate
+    eps_high NUMERIC(10, 4),                                 -- Highest EPS estimate
+    revenue_analysts INTEGER,                                -- Analysts contributing revenue estimates
+    eps_analysts INTEGER,                                    -- Analysts contributing EPS estimates
+    created_at TIMESTAMPTZ DEFAULT NOW(),                    -- Timestamp of record creation
+    updated_at TIMESTAMPTZ DEFAULT NOW(),                    -- Timestamp of last record update
+    UNIQUE (stock_id, fiscal_date, period, as_of)            -- One snapshot per estimated period per day
+);
+
 -- Create the sentiment_scores table
 CREATE TABLE sentiment_scores (
     sentiment_id UUID PRIMARY KEY DEFAULT gen_random_uuid(), -- Unique identifier for the sentiment record
@@ -85,3 +179,44 @@ CREATE TABLE sentiment_scores (
     updated_at TIMESTAMPTZ DEFAULT NOW(),                    -- Timestamp of last record update
     UNIQUE (stock_id, timestamp, source)                     -- Ensure unique sentiment per stock per timestamp per source
 );
+
+-- Create the saved_screens table
+CREATE TABLE saved_screens (
+    screen_id UUID PRIMARY KEY DEFAULT gen_random_uuid(),    -- Unique identifier for the saved screen
+    user_id TEXT NOT NULL,                                   -- Owner of the screen
+    name TEXT NOT NULL,                                      -- Name of the screen, unique per user
+    query TEXT NOT NULL,                                     -- Screener expression (e.g., 'pe < 15 AND roic > 0.12')
+    sort_by TEXT NOT NULL,                                   -- Metric the results are sorted by
+    sort_order TEXT NOT NULL,                                -- 'asc' or 'desc'
+    result_limit INTEGER NOT NULL,                           -- Maximum number of results
+    created_at TIMESTAMPTZ DEFAULT NOW(),                    -- Timestamp of record creation
+    updated_at TIMESTAMPTZ DEFAULT NOW(),                    -- Timestamp of last record update
+    UNIQUE (user_id, name)                                   -- Ensure unique screen names per user
+);
+
+-- Create the factor_analyses table (cache of factor IC analyses)
+CREATE TABLE factor_analyses (
+    analysis_id UUID PRIMARY KEY DEFAULT gen_random_uuid(),  -- Unique identifier for the analysis
+    cache_key TEXT NOT NULL UNIQUE,                          -- Hash of the normalized request the result was computed for
+    request JSONB NOT NULL,                                  -- Normalized request (configuration and symbols)
+    result JSONB NOT NULL,                                   -- Information coefficients, quantile spreads and decay per metric
+    created_at TIMESTAMPTZ DEFAULT NOW(),                    -- Timestamp of record creation
+    updated_at TIMESTAMPTZ DEFAULT NOW()                     -- Timestamp of the last recomputation
+);
+
+-- Create the backfill_jobs table (queue of targeted re-ingestions for missing price data)
+CREATE TABLE backfill_jobs (
+    job_id UUID PRIMARY KEY DEFAULT gen_random_uuid(),       -- Unique identifier for the job
+    stock_id UUID NOT NULL REFERENCES stocks(stock_id),      -- Foreign key to stocks table
+    data_type TEXT NOT NULL,                                 -- Data to re-ingest (e.g., 'daily_prices')
+    range_start DATE NOT NULL,                               -- First missing session
+    range_end DATE NOT NULL,                                 -- Last missing session
+    status TEXT NOT NULL DEFAULT 'pending',                  -- 'pending', 'running', 'done' or 'failed'
+    attempts INTEGER NOT NULL DEFAULT 0,                     -- Number of times the job was started
+    rows_ingested INTEGER NOT NULL DEFAULT 0,                -- Rows stored by the last attempt
+    last_error TEXT,                                         -- Error of the last failed attempt
+    created_at TIMESTAMPTZ DEFAULT NOW(),                    -- Timestamp of record creation
+    updated_at TIMESTAMPTZ DEFAULT NOW(),                    -- Timestamp of last status change
+    UNIQUE (stock_id, data_type, range_start, range_end)     -- A gap is queued once
+);
+CREATE INDEX idx_backfill_jobs_status ON backfill_jobs (status, created_at);